@@ -15,7 +15,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
-	_ "time"
+	"time"
 )
 
 func main() {
@@ -40,27 +40,84 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
-	err = db.Migrate(database)
-	if err != nil {
-		log.Printf("Failed to migrate database: %v", err)
-	}
 	defer db.Close(database)
 
+	// "migrate up|down|status" manages the schema and exits without starting the service
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrateCommand(database, os.Args[2:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
+	// The service refuses to start on a schema it could not migrate or that a newer build migrated
+	if err := db.Migrate(database); err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
+
 	// Initialize repositories
 	repoRepo := repository.NewRepositoryRepo(database)
 	commitRepo := repository.NewCommitRepo(database)
+	releaseRepo := repository.NewReleaseRepo(database)
+	pullRequestRepo := repository.NewPullRequestRepo(database)
+	issueRepo := repository.NewIssueRepo(database)
+	contributorRepo := repository.NewContributorRepo(database)
 
-	// Initialize fetcher
-	fetch := fetcher.NewGitHubFetcher()
+	// Match commits stored before contributors were tracked, then merge identities from the mailmap
+	if assigned, err := contributorRepo.AssignMissing(ctx); err != nil {
+		log.Printf("Failed to assign contributors: %v", err)
+	} else if assigned > 0 {
+		log.Printf("Assigned contributors to %d commits", assigned)
+	}
+	if cfg.MailmapFile != "" {
+		if err := applyMailmapFile(ctx, contributorRepo, cfg.MailmapFile); err != nil {
+			log.Printf("Failed to apply mailmap: %v", err)
+		}
+	}
 
 	//Initialize Cache
 	newCache := cache.NewCache(ctx, cfg.RedisHost, cfg.RedisPassword)
 
+	// Initialize fetcher
+	fetch := fetcher.NewGitHubFetcher()
+	fetch.BaseURL = cfg.GitHubAPIURL
+	fetch.MaxPages = cfg.MaxCommitPages
+	// Validators are cached along with the page they validate for a day, one entry per listing page of at most 1 MiB
+	fetch.Validators = fetcher.NewCacheValidatorStore(newCache, 24*time.Hour)
+
+	// Initialize source providers
+	providers := fetcher.NewRegistry(fetch)
+	providers.LocalRoot = cfg.LocalRoot
+	providers.CommitFiles = cfg.CommitFiles
+	providers.MaxPages = cfg.MaxCommitPages
+	// GitHub App authentication replaces the configured tokens, which then must not be pooled either
+	if cfg.GitHubAppID != 0 {
+		appAuth, err := newAppTokenSource(cfg)
+		if err != nil {
+			log.Fatalf("Failed to configure GitHub App authentication: %v", err)
+		}
+		providers.SetTokenSource(fetcher.ProviderGitHub, "", appAuth)
+	} else if len(cfg.GitHubTokens) > 1 {
+		pool := fetcher.NewTokenPool(cfg.GitHubTokens)
+		fetch.Client.Pool = pool
+		providers.SetTokenSource(fetcher.ProviderGitHub, "", pool)
+	} else if len(cfg.GitHubTokens) == 1 {
+		providers.SetToken(fetcher.ProviderGitHub, "", cfg.GitHubTokens[0])
+	}
+	providers.SetToken(fetcher.ProviderGitLab, "", cfg.GitLabToken)
+	providers.SetToken(fetcher.ProviderGitea, "", cfg.GiteaToken)
+	if cfg.GHESHost != "" {
+		providers.SetToken(fetcher.ProviderGitHub, cfg.GHESHost, cfg.GHESToken)
+	}
+
 	// Start HTTP server
-	go server.StartServer(ctx, *cfg, repoRepo, commitRepo, *fetch, newCache)
+	go server.StartServer(ctx, *cfg, repoRepo, commitRepo, releaseRepo, pullRequestRepo, issueRepo, contributorRepo, providers, newCache)
 
 	// Start monitoring worker
-	mon := monitor.NewMonitor(database, cfg.GitHubToken, cfg.PollInterval, *repoRepo, *commitRepo, *fetch)
+	mon := monitor.NewMonitor(database, cfg.PollInterval, *repoRepo, *commitRepo, *releaseRepo, *pullRequestRepo, *issueRepo, providers)
+	mon.ReconcileInterval = cfg.ReconcileInterval
+	mon.ReleaseInterval = cfg.ReleaseInterval
+	mon.MetadataInterval = cfg.MetadataInterval
 	scheduler := monitor.NewWorker(mon, *repoRepo)
 	go scheduler.Start(ctx)
 
@@ -75,3 +132,31 @@ func main() {
 	<-ctx.Done()
 	log.Println("Service shutting down...")
 }
+
+// applyMailmapFile merges the contributor identities listed in a .mailmap file
+func applyMailmapFile(ctx context.Context, contributorRepo *repository.ContributorRepo, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	entries, err := repository.ParseMailmap(file)
+	if err != nil {
+		return err
+	}
+	return contributorRepo.ApplyMailmap(ctx, entries)
+}
+
+// newAppTokenSource loads the GitHub App private key from the environment or a file
+func newAppTokenSource(cfg *config.Config) (*fetcher.AppTokenSource, error) {
+	privateKey := []byte(cfg.GitHubAppPrivateKey)
+	if cfg.GitHubAppPrivateKeyPath != "" {
+		data, err := os.ReadFile(cfg.GitHubAppPrivateKeyPath)
+		if err != nil {
+			return nil, err
+		}
+		privateKey = data
+	}
+	return fetcher.NewAppTokenSource(cfg.GitHubAppID, privateKey, cfg.GitHubAPIURL)
+}
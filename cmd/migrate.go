@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"gmonitor/internal/db"
+	"gorm.io/gorm"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// runMigrateCommand handles "migrate up", "migrate down [steps] [--drop-schema]" and "migrate status"
+func runMigrateCommand(database *gorm.DB, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: migrate up | down [steps] [--drop-schema] | status")
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := db.MigrateUp(database)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Applied %d migrations\n", applied)
+		return nil
+	case "down":
+		// Rolling back the initial schema drops every table, it has to be asked for explicitly
+		steps, dropSchema := 1, false
+		for _, arg := range args[1:] {
+			if arg == "--drop-schema" {
+				dropSchema = true
+				continue
+			}
+			parsed, err := strconv.Atoi(arg)
+			if err != nil || parsed <= 0 {
+				return fmt.Errorf("invalid number of steps: %s", arg)
+			}
+			steps = parsed
+		}
+		reverted, err := db.Rollback(database, steps, dropSchema)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Rolled back %d migrations\n", reverted)
+		return nil
+	case "status":
+		statuses, err := db.Status(database)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = status.AppliedAt.Format(time.RFC3339)
+			}
+			if !status.Known {
+				applied += " (unknown to this build)"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, applied)
+		}
+		return w.Flush()
+	}
+	return fmt.Errorf("unknown migrate command %q, use up, down or status", args[0])
+}
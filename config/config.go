@@ -3,28 +3,80 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
 // Config holds all configuration settings for the application
 type Config struct {
-	GitHubToken   string
+	GitHubTokens  []string
+	GitHubAPIURL  string
+	GHESHost      string
+	GHESToken     string
+	GitLabToken   string
+	GiteaToken    string
+	LocalRoot     string
 	DatabaseURL   string
 	PollInterval  time.Duration
+	CommitFiles   bool
 	PORT          string
 	RedisHost     string
 	RedisPassword string
+
+	// Webhook receiver, the commits of repositories with a working webhook are only polled every ReconcileInterval
+	WebhookSecret     string
+	ReconcileInterval time.Duration
+
+	// MaxCommitPages caps the commit pages fetched per repository and poll, 0 means no limit
+	MaxCommitPages int
+
+	// ReleaseInterval is how often the tags and releases of each repository are fetched
+	ReleaseInterval time.Duration
+
+	// MetadataInterval is how often the metadata of each repository is fetched and its counters recorded
+	MetadataInterval time.Duration
+
+	// MailmapFile is a .mailmap file merging contributor identities, applied at startup
+	MailmapFile string
+
+	// GitHub App authentication, used instead of GitHubTokens when an app ID is set
+	GitHubAppID             int64
+	GitHubAppPrivateKey     string
+	GitHubAppPrivateKeyPath string
 }
 
 // LoadConfig initializes the configuration from environment variables
 func LoadConfig() *Config {
 	return &Config{
-		GitHubToken:   getEnv("GITHUB_TOKEN", ""),
+		GitHubTokens:  getEnvAsList("GITHUB_TOKEN"), // Several tokens may be given, separated by commas
+		GitHubAPIURL:  getEnv("GITHUB_API_URL", "https://api.github.com"),
+		GHESHost:      getEnv("GHES_HOST", ""),
+		GHESToken:     getEnv("GHES_TOKEN", ""),
+		GitLabToken:   getEnv("GITLAB_TOKEN", ""),
+		GiteaToken:    getEnv("GITEA_TOKEN", ""),
+		LocalRoot:     getEnv("LOCAL_REPOS_ROOT", ""), // Directory of local clones, empty disables the local provider
 		DatabaseURL:   getEnv("DB_DSN", "gmonitor.db"),
 		PollInterval:  getEnvAsDuration("POLL_INTERVAL", time.Minute), // Default: 1 Minute
+		CommitFiles:   getEnvAsBool("FETCH_COMMIT_FILES", false),      // One extra request per commit
 		PORT:          getEnv("SERVER_PORT", "8000"),
 		RedisHost:     getEnv("REDIS_HOST", "localhost:6379"),
 		RedisPassword: getEnv("REDIS_PASSWORD", ""),
+
+		WebhookSecret:     getEnv("WEBHOOK_SECRET", ""),
+		ReconcileInterval: getEnvAsDuration("RECONCILE_INTERVAL", time.Hour), // Default: 1 Hour
+
+		MaxCommitPages: int(getEnvAsInt64("MAX_COMMIT_PAGES", 0)), // Default: no limit
+
+		ReleaseInterval: getEnvAsDuration("RELEASE_SYNC_INTERVAL", time.Hour), // Default: 1 Hour
+
+		MetadataInterval: getEnvAsDuration("METADATA_SYNC_INTERVAL", time.Hour), // Default: 1 Hour
+
+		MailmapFile: getEnv("MAILMAP_FILE", ""),
+
+		GitHubAppID:             getEnvAsInt64("GITHUB_APP_ID", 0),
+		GitHubAppPrivateKey:     getEnv("GITHUB_APP_PRIVATE_KEY", ""),
+		GitHubAppPrivateKeyPath: getEnv("GITHUB_APP_PRIVATE_KEY_PATH", ""),
 	}
 }
 
@@ -50,3 +102,42 @@ func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
 	}
 	return value
 }
+
+// getEnvAsInt64 retrieves an environment variable as an int64 or uses a default
+func getEnvAsInt64(key string, defaultValue int64) int64 {
+	valueStr := getEnv(key, "")
+	if valueStr == "" {
+		return defaultValue
+	}
+	value, err := strconv.ParseInt(valueStr, 10, 64)
+	if err != nil {
+		log.Printf("Invalid integer format for %s: %s, using default: %v", key, valueStr, defaultValue)
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvAsBool retrieves an environment variable as a bool or uses a default
+func getEnvAsBool(key string, defaultValue bool) bool {
+	valueStr := getEnv(key, "")
+	if valueStr == "" {
+		return defaultValue
+	}
+	value, err := strconv.ParseBool(valueStr)
+	if err != nil {
+		log.Printf("Invalid boolean format for %s: %s, using default: %v", key, valueStr, defaultValue)
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvAsList retrieves a comma separated environment variable as a list of non-empty values
+func getEnvAsList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(getEnv(key, ""), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -3,9 +3,13 @@ package db
 import (
 	"fmt"
 	"github.com/glebarez/sqlite"
-	"gmonitor/internal/models"
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"log"
+	"net/url"
+	"strings"
 	"time"
 )
 
@@ -29,7 +33,12 @@ func NewConfig(dsn string) Config {
 
 // Connect initializes the database connection with optimized settings
 func Connect(config Config) (*gorm.DB, error) {
-	db, err := gorm.Open(sqlite.Open(config.DSN), &gorm.Config{
+	dialector, err := dialectorFor(config.DSN)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{
 		SkipDefaultTransaction: true,
 		PrepareStmt:            true,
 	})
@@ -51,10 +60,60 @@ func Connect(config Config) (*gorm.DB, error) {
 		return nil, fmt.Errorf("failed to ping database: %v", err)
 	}
 
-	log.Println("Database connected successfully")
+	log.Printf("Database connected successfully (%s)", db.Dialector.Name())
 	return db, nil
 }
 
+// dialectorFor picks the database driver from the scheme of a DSN: sqlite://, postgres:// or mysql://.
+// A DSN without scheme is a SQLite file path, as it was before other databases were supported.
+func dialectorFor(dsn string) (gorm.Dialector, error) {
+	scheme, rest, found := strings.Cut(dsn, "://")
+	if !found {
+		return sqlite.Open(dsn), nil
+	}
+
+	switch scheme {
+	case "sqlite":
+		return sqlite.Open(rest), nil
+	case "postgres", "postgresql":
+		return postgres.Open(dsn), nil
+	case "mysql":
+		mysqlDSN, err := mysqlDSN(dsn)
+		if err != nil {
+			return nil, err
+		}
+		return mysql.Open(mysqlDSN), nil
+	}
+	return nil, fmt.Errorf("unsupported database scheme %q, use sqlite://, postgres:// or mysql://", scheme)
+}
+
+// mysqlDSN converts a mysql:// URL to the DSN format of the MySQL driver.
+// Times are always parsed into time.Time and read as UTC unless the URL asks otherwise.
+func mysqlDSN(dsn string) (string, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "", fmt.Errorf("invalid mysql DSN: %w", err)
+	}
+
+	config := mysqldriver.NewConfig()
+	config.Net = "tcp"
+	config.Addr = u.Host
+	config.DBName = strings.TrimPrefix(u.Path, "/")
+	if u.User != nil {
+		config.User = u.User.Username()
+		config.Passwd, _ = u.User.Password()
+	}
+
+	// The query parameters go through the driver's own parser, which knows their types
+	params := u.Query()
+	params.Set("parseTime", "true")
+	parsed, err := mysqldriver.ParseDSN(config.FormatDSN() + "?" + params.Encode())
+	if err != nil {
+		return "", fmt.Errorf("invalid mysql DSN: %w", err)
+	}
+	return parsed.FormatDSN(), nil
+}
+
 // Close closes the database connection
 func Close(db *gorm.DB) error {
 	if db == nil {
@@ -68,15 +127,3 @@ func Close(db *gorm.DB) error {
 
 	return sqlDB.Close()
 }
-
-// Migrate applies database migrations with optimized settings
-func Migrate(db *gorm.DB) error {
-	if err := db.AutoMigrate(
-		&models.Repository{},
-		&models.Commit{},
-	); err != nil {
-		return fmt.Errorf("failed to apply migrations: %v", err)
-	}
-	log.Println("Database migrations applied successfully")
-	return nil
-}
@@ -0,0 +1,39 @@
+package db
+
+import "testing"
+
+func TestDialectorFor(t *testing.T) {
+	cases := map[string]string{
+		"gmonitor.db":                            "sqlite",
+		"sqlite://gmonitor.db":                   "sqlite",
+		"sqlite:///var/lib/gmonitor/gmonitor.db": "sqlite",
+		"postgres://gmonitor:secret@db:5432/gmonitor?sslmode=disable": "postgres",
+		"postgresql://db/gmonitor":                                    "postgres",
+		"mysql://gmonitor:secret@db:3306/gmonitor":                    "mysql",
+	}
+	for dsn, want := range cases {
+		dialector, err := dialectorFor(dsn)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", dsn, err)
+			continue
+		}
+		if dialector.Name() != want {
+			t.Errorf("%s: expected %s, got %s", dsn, want, dialector.Name())
+		}
+	}
+
+	if _, err := dialectorFor("oracle://db/gmonitor"); err == nil {
+		t.Error("expected an error for an unsupported scheme")
+	}
+}
+
+func TestMySQLDSN(t *testing.T) {
+	dsn, err := mysqlDSN("mysql://gmonitor:s%40cret@db:3306/gmonitor?charset=utf8mb4&loc=Local")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "gmonitor:s@cret@tcp(db:3306)/gmonitor?loc=Local&parseTime=true&charset=utf8mb4"
+	if dsn != want {
+		t.Errorf("expected %s, got %s", want, dsn)
+	}
+}
@@ -0,0 +1,96 @@
+package db
+
+import (
+	"context"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// innoDBMaxKeyBytes is the largest index key InnoDB accepts with the DYNAMIC row format
+const innoDBMaxKeyBytes = 3072
+
+// statementRecorder is a GORM logger collecting the statements of a dry run
+type statementRecorder struct {
+	logger.Interface
+	statements []string
+}
+
+func (r *statementRecorder) Trace(_ context.Context, _ time.Time, fc func() (string, int64), _ error) {
+	sql, _ := fc()
+	r.statements = append(r.statements, sql)
+}
+
+// mysqlDDL returns the CREATE TABLE statements MySQL would run for the given models, without a server
+func mysqlDDL(t *testing.T, tables ...interface{}) []string {
+	recorder := &statementRecorder{Interface: logger.Discard}
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "gmonitor@tcp(127.0.0.1:1)/gmonitor", SkipInitializeWithVersion: true}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true, Logger: recorder})
+	if err != nil {
+		t.Fatalf("failed to open dry-run mysql: %v", err)
+	}
+	for _, table := range tables {
+		if err := db.Migrator().CreateTable(table); err != nil {
+			t.Fatalf("failed to build DDL for %T: %v", table, err)
+		}
+	}
+	return recorder.statements
+}
+
+var (
+	ddlColumn = regexp.MustCompile("`(\\w+)` (\\w+)(?:\\((\\d+)\\))?")
+	ddlIndex  = regexp.MustCompile("(?:INDEX|UNIQUE) `?(\\w*)`? ?\\(((?:[^()]|\\(\\d+\\))*)\\)")
+	ddlKey    = regexp.MustCompile("`(\\w+)`(?:\\((\\d+)\\))?")
+)
+
+// keyBytes is the size of a column in an index key, utf8mb4 takes up to four bytes per character
+// and numbers and dates take at most eight bytes
+func keyBytes(dataType string, length int) int {
+	if dataType == "varchar" || dataType == "char" {
+		return length * 4
+	}
+	return 8
+}
+
+// TestMySQLIndexKeysFitInnoDB checks the tables of the initial schema and of the current models, whose indexes
+// later migrations create
+func TestMySQLIndexKeysFitInnoDB(t *testing.T) {
+	statements := append(mysqlDDL(t, v1Tables...), mysqlDDL(t, modelTables...)...)
+	for _, statement := range statements {
+		if !strings.HasPrefix(statement, "CREATE TABLE") {
+			continue
+		}
+		columns := make(map[string][2]string)
+		for _, match := range ddlColumn.FindAllStringSubmatch(statement, -1) {
+			if _, found := columns[match[1]]; !found {
+				columns[match[1]] = [2]string{strings.ToLower(match[2]), match[3]}
+			}
+		}
+
+		for _, index := range ddlIndex.FindAllStringSubmatch(statement, -1) {
+			total := 0
+			for _, key := range ddlKey.FindAllStringSubmatch(index[2], -1) {
+				column := columns[key[1]]
+				length, _ := strconv.Atoi(column[1])
+				if key[2] != "" {
+					length, _ = strconv.Atoi(key[2])
+				}
+				if column[0] == "longtext" || column[0] == "text" {
+					if key[2] == "" {
+						t.Errorf("index %s indexes TEXT column %s without a prefix length", index[1], key[1])
+					}
+					column[0] = "varchar"
+				}
+				total += keyBytes(column[0], length)
+			}
+			if total > innoDBMaxKeyBytes {
+				t.Errorf("index %s is %d bytes, over InnoDB's %d byte limit: %s", index[1], total, innoDBMaxKeyBytes, index[0])
+			}
+		}
+	}
+}
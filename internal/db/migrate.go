@@ -0,0 +1,181 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"log"
+	"sort"
+	"time"
+)
+
+// ErrSchemaTooNew is returned when the database was migrated by a newer build than this one
+var ErrSchemaTooNew = errors.New("database schema is newer than this build")
+
+// ErrDropsSchema is returned when a rollback would revert the initial schema, dropping every table, without being told to
+var ErrDropsSchema = errors.New("rolling back the initial schema drops every table")
+
+// Migration is a versioned schema change along with the change reverting it
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// MigrationStatus tells whether a migration is applied, AppliedAt is nil for pending migrations.
+// Known is false for migrations found in the database that this build does not ship.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+	Known     bool
+}
+
+// schemaMigration records an applied migration in the schema_migrations table
+type schemaMigration struct {
+	Version   int    `gorm:"primaryKey;autoIncrement:false"`
+	Name      string `gorm:"size:255"`
+	AppliedAt time.Time
+}
+
+// TableName overrides the table name used by GORM
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Migrate applies the pending migrations after checking that the schema is not newer than this build
+func Migrate(db *gorm.DB) error {
+	applied, err := MigrateUp(db)
+	if err != nil {
+		return err
+	}
+	log.Printf("Database migrations applied successfully (%d new)", applied)
+	return nil
+}
+
+// MigrateUp applies the pending migrations in version order and returns how many were applied.
+// Each migration is recorded in the same transaction as its changes, so a failed migration leaves no record.
+// On SQLite and PostgreSQL it leaves no change either. MySQL commits every schema statement on its own, so a migration
+// that fails there may leave its earlier statements applied, which has to be undone by hand before it is retried.
+func MigrateUp(db *gorm.DB) (int, error) {
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return 0, err
+	}
+	if err := checkSchema(applied); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, migration := range migrations {
+		if _, done := applied[migration.Version]; done {
+			continue
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := migration.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&schemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now().UTC()}).Error
+		})
+		if err != nil {
+			return count, fmt.Errorf("failed to apply migration %d (%s): %w", migration.Version, migration.Name, err)
+		}
+		log.Printf("Applied migration %d (%s)", migration.Version, migration.Name)
+		count++
+	}
+	return count, nil
+}
+
+// Rollback reverts the latest applied migrations, at most steps of them, and returns how many were reverted.
+// Reverting the initial schema drops every table along with its data, so it is refused unless dropSchema is set.
+// Like MigrateUp, a failed rollback on MySQL may leave part of the migration reverted.
+func Rollback(db *gorm.DB, steps int, dropSchema bool) (int, error) {
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return 0, err
+	}
+	if err := checkSchema(applied); err != nil {
+		return 0, err
+	}
+
+	var pending []Migration
+	for i := len(migrations) - 1; i >= 0 && len(pending) < steps; i-- {
+		if _, done := applied[migrations[i].Version]; done {
+			pending = append(pending, migrations[i])
+		}
+	}
+	if !dropSchema && len(pending) > 0 && pending[len(pending)-1].Version == migrations[0].Version {
+		return 0, fmt.Errorf("%w, %d steps would reach it", ErrDropsSchema, len(pending))
+	}
+
+	count := 0
+	for _, migration := range pending {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := migration.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&schemaMigration{}, migration.Version).Error
+		})
+		if err != nil {
+			return count, fmt.Errorf("failed to roll back migration %d (%s): %w", migration.Version, migration.Name, err)
+		}
+		log.Printf("Rolled back migration %d (%s)", migration.Version, migration.Name)
+		count++
+	}
+	return count, nil
+}
+
+// Status lists the migrations of this build and any unknown migration applied to the database, by version
+func Status(db *gorm.DB) ([]MigrationStatus, error) {
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, migration := range migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name, Known: true}
+		if record, done := applied[migration.Version]; done {
+			status.AppliedAt = &record.AppliedAt
+			delete(applied, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, record := range applied {
+		statuses = append(statuses, MigrationStatus{Version: record.Version, Name: record.Name, AppliedAt: &record.AppliedAt})
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+	return statuses, nil
+}
+
+// appliedMigrations reads the schema_migrations table by version, creating it on first use
+func appliedMigrations(db *gorm.DB) (map[int]schemaMigration, error) {
+	if err := db.AutoMigrate(&schemaMigration{}); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	var records []schemaMigration
+	if err := db.Order("version").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+
+	applied := make(map[int]schemaMigration, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+// checkSchema refuses databases holding migrations this build does not know of, which a newer build applied
+func checkSchema(applied map[int]schemaMigration) error {
+	latest := migrations[len(migrations)-1].Version
+	for version := range applied {
+		if version > latest {
+			return fmt.Errorf("%w: database is at version %d, this build knows up to %d", ErrSchemaTooNew, version, latest)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package db
+
+import (
+	"errors"
+	"github.com/glebarez/sqlite"
+	"gmonitor/internal/models"
+	"gorm.io/gorm"
+	"testing"
+	"time"
+)
+
+// modelTables are the current models of every table the migrations create
+var modelTables = []interface{}{
+	&models.Repository{}, &models.Commit{}, &models.Contributor{}, &models.ContributorAlias{},
+	&models.CommitFile{}, &models.Branch{}, &models.CommitBranch{}, &models.HistoryRewrite{},
+	&models.Tag{}, &models.Release{}, &models.PullRequest{}, &models.Issue{}, &models.RepositorySnapshot{},
+}
+
+func setupMigrateTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to connect test db: %v", err)
+	}
+	return db
+}
+
+func TestMigrateUpAndRollback(t *testing.T) {
+	db := setupMigrateTestDB(t)
+
+	applied, err := MigrateUp(db)
+	if err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	if applied != len(migrations) {
+		t.Errorf("expected %d applied migrations, got %d", len(migrations), applied)
+	}
+	if !db.Migrator().HasTable(&models.Commit{}) || !db.Migrator().HasTable("commits_fts") {
+		t.Fatal("expected the commits table and its search index")
+	}
+
+	// Applying again is a no-op
+	if applied, err := MigrateUp(db); err != nil || applied != 0 {
+		t.Errorf("expected nothing to apply, got %d (%v)", applied, err)
+	}
+
+	statuses, err := Status(db)
+	if err != nil {
+		t.Fatalf("failed to get status: %v", err)
+	}
+	if len(statuses) != len(migrations) || statuses[0].AppliedAt == nil || !statuses[0].Known {
+		t.Errorf("unexpected status: %+v", statuses)
+	}
+
+	// The initial schema is only dropped when asked for
+	if _, err := Rollback(db, len(migrations), false); !errors.Is(err, ErrDropsSchema) {
+		t.Fatalf("expected ErrDropsSchema, got %v", err)
+	}
+	if statuses, _ := Status(db); statuses[len(statuses)-1].AppliedAt == nil {
+		t.Fatal("expected a refused rollback to revert nothing")
+	}
+
+	reverted, err := Rollback(db, len(migrations), true)
+	if err != nil {
+		t.Fatalf("failed to roll back: %v", err)
+	}
+	if reverted != len(migrations) {
+		t.Errorf("expected %d reverted migrations, got %d", len(migrations), reverted)
+	}
+	if db.Migrator().HasTable(&models.Commit{}) || db.Migrator().HasTable("commits_fts") {
+		t.Error("expected the tables to be dropped")
+	}
+	statuses, _ = Status(db)
+	if statuses[0].AppliedAt != nil {
+		t.Errorf("expected the initial schema to be pending, got %+v", statuses[0])
+	}
+}
+
+func TestMigrateUp_RefusesNewerSchema(t *testing.T) {
+	db := setupMigrateTestDB(t)
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	latest := migrations[len(migrations)-1].Version
+	db.Create(&schemaMigration{Version: latest + 1, Name: "from the future", AppliedAt: time.Now()})
+
+	if _, err := MigrateUp(db); !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("expected ErrSchemaTooNew, got %v", err)
+	}
+	if _, err := Rollback(db, 1, false); !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("expected ErrSchemaTooNew on rollback, got %v", err)
+	}
+
+	statuses, _ := Status(db)
+	if last := statuses[len(statuses)-1]; last.Version != latest+1 || last.Known {
+		t.Errorf("expected the unknown migration to be listed, got %+v", last)
+	}
+}
+
+func TestMigrateUp_AdoptsExistingSchema(t *testing.T) {
+	db := setupMigrateTestDB(t)
+
+	// A database set up by AutoMigrate before migrations were versioned
+	if err := db.AutoMigrate(v1Tables...); err != nil {
+		t.Fatalf("failed to set up schema: %v", err)
+	}
+	db.Create(&v1Repository{Name: "kept"})
+
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	var count int64
+	db.Model(&models.Repository{}).Count(&count)
+	if count != 1 {
+		t.Errorf("expected existing rows to be kept, got %d", count)
+	}
+}
+
+func TestMigrateUp_CommitIdentityPerRepository(t *testing.T) {
+	db := setupMigrateTestDB(t)
+
+	// A database at version 1, from before commits were identified per repository
+	if err := createInitialSchema(db); err != nil {
+		t.Fatalf("failed to set up version 1: %v", err)
+	}
+	db.Create(&schemaMigration{Version: 1, Name: "initial schema", AppliedAt: time.Now()})
+	db.Create(&v1Commit{RepoID: 1, CommitHash: "shared", Author: "dev", Message: "fix TICKET-7"})
+
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	// The fork can now hold the commit of its upstream, but a repository still holds a commit once
+	if err := db.Create(&models.Commit{RepoID: 2, CommitHash: "shared", Author: "dev", Message: "fix TICKET-7"}).Error; err != nil {
+		t.Errorf("expected the fork to store the shared commit, got %v", err)
+	}
+	if err := db.Create(&models.Commit{RepoID: 2, CommitHash: "shared", Author: "dev", Message: "again"}).Error; err == nil {
+		t.Error("expected a duplicate within a repository to be rejected")
+	}
+
+	var indexed int64
+	db.Raw("SELECT COUNT(*) FROM commits_fts WHERE commits_fts MATCH 'ticket'").Scan(&indexed)
+	if indexed != 2 {
+		t.Errorf("expected both commits in the search index, got %d", indexed)
+	}
+	for _, index := range v1CommitIndexes {
+		if !db.Migrator().HasIndex(&models.Commit{}, index) {
+			t.Errorf("expected the commit index %s to be kept", index)
+		}
+	}
+
+	// Rolling back fails while the repositories share a commit, the later migrations are reverted first
+	if _, err := Rollback(db, len(migrations)-1, false); err == nil {
+		t.Error("expected the rollback to fail while commits are shared")
+	}
+	db.Unscoped().Where("repo_id = ?", 2).Delete(&models.Commit{})
+	if _, err := Rollback(db, 1, false); err != nil {
+		t.Fatalf("failed to roll back: %v", err)
+	}
+	if err := db.Create(&models.Commit{RepoID: 2, CommitHash: "shared", Author: "dev", Message: "m"}).Error; err == nil {
+		t.Error("expected commit hashes to be unique again after the rollback")
+	}
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatalf("failed to migrate again: %v", err)
+	}
+}
+
+func TestMigrateUp_RepositoryIdentityPerHost(t *testing.T) {
+	db := setupMigrateTestDB(t)
+
+	// A database at version 2, with repository names unique across hosts
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	if _, err := Rollback(db, len(migrations)-2, false); err != nil {
+		t.Fatalf("failed to roll back: %v", err)
+	}
+	db.Create(&v1Repository{Name: "acme/api", Provider: "github", Host: "https://GitHub.com/", URL: "u"})
+	db.Create(&v1Repository{Name: "acme/web", Provider: "gitlab", Host: "https://Git.Example.com/", URL: "u"})
+	if err := db.Create(&v1Repository{Name: "acme/api", Provider: "gitlab", URL: "u"}).Error; err == nil {
+		t.Fatal("expected repository names to be unique at version 2")
+	}
+
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	var hosts []string
+	db.Table("repositories").Order("id").Pluck("host", &hosts)
+	if len(hosts) != 2 || hosts[0] != "" || hosts[1] != "git.example.com" {
+		t.Errorf("expected canonical hosts, got %q", hosts)
+	}
+	if err := db.Create(&models.Repository{Name: "acme/api", Provider: "gitlab", URL: "u"}).Error; err != nil {
+		t.Errorf("expected the name to be tracked on another host, got %v", err)
+	}
+	if err := db.Create(&models.Repository{Name: "acme/api", Provider: "gitlab", URL: "u"}).Error; err == nil {
+		t.Error("expected a repository to be stored once per host")
+	}
+	if !db.Migrator().HasIndex(&models.Repository{}, "idx_repositories_deleted_at") {
+		t.Error("expected the repository indexes to be kept")
+	}
+
+	// Rolling back fails while a name is tracked on several hosts, the later migrations are reverted first
+	if _, err := Rollback(db, len(migrations)-2, false); err == nil {
+		t.Error("expected the rollback to fail while names are shared")
+	}
+	db.Unscoped().Where("provider = ? AND name = ?", "gitlab", "acme/api").Delete(&models.Repository{})
+	if _, err := Rollback(db, 1, false); err != nil {
+		t.Fatalf("failed to roll back: %v", err)
+	}
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatalf("failed to migrate again: %v", err)
+	}
+}
+
+// TestMigrateUp_MatchesModels catches model changes that were not shipped with a migration
+func TestMigrateUp_MatchesModels(t *testing.T) {
+	db := setupMigrateTestDB(t)
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	for _, table := range modelTables {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(table); err != nil {
+			t.Fatalf("failed to parse %T: %v", table, err)
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName != "" && !db.Migrator().HasColumn(table, field.DBName) {
+				t.Errorf("column %s.%s has no migration", stmt.Schema.Table, field.DBName)
+			}
+		}
+		for _, index := range stmt.Schema.ParseIndexes() {
+			if !db.Migrator().HasIndex(table, index.Name) {
+				t.Errorf("index %s of %s has no migration", index.Name, stmt.Schema.Table)
+			}
+		}
+	}
+}
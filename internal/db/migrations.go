@@ -0,0 +1,304 @@
+package db
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+)
+
+// migrations lists every schema change in version order. Versions are never reused or reordered once released,
+// and a released migration never changes: it works on frozen table definitions rather than on the current models.
+var migrations = []Migration{
+	{Version: 1, Name: "initial schema", Up: createInitialSchema, Down: dropInitialSchema},
+	{Version: 2, Name: "commit identity per repository", Up: uniqueCommitPerRepository, Down: uniqueCommitHash},
+	{Version: 3, Name: "repository identity by provider and host", Up: uniqueRepositoryPerHost, Down: uniqueRepositoryName},
+	{Version: 4, Name: "release resolution progress", Up: addReleasesResolvedUpTo, Down: dropReleasesResolvedUpTo},
+}
+
+// createInitialSchema creates the tables of version 1 and the commit search index. Databases set up before
+// migrations were versioned already hold the tables, AutoMigrate only adds what they miss.
+func createInitialSchema(tx *gorm.DB) error {
+	if err := tx.AutoMigrate(v1Tables...); err != nil {
+		return fmt.Errorf("failed to create tables: %w", err)
+	}
+	return createCommitSearchIndex(tx)
+}
+
+// dropInitialSchema drops the commit search index and the tables in reverse creation order
+func dropInitialSchema(tx *gorm.DB) error {
+	if err := dropCommitSearchIndex(tx); err != nil {
+		return err
+	}
+	for i := len(v1Tables) - 1; i >= 0; i-- {
+		if err := tx.Migrator().DropTable(v1Tables[i]); err != nil {
+			return fmt.Errorf("failed to drop table: %w", err)
+		}
+	}
+	return nil
+}
+
+// commitHashUnique names the constraint that made commit hashes unique across all repositories
+const commitHashUnique = "uni_commits_commit_hash"
+
+// commitIdentityIndex names the index of commits by repository and hash
+const commitIdentityIndex = "idx_repo_id_commit_hash"
+
+// v1CommitIndexes are the indexes of the commits table at version 1 besides the ones migration 2 replaces
+var v1CommitIndexes = []string{
+	"idx_repo_id_commit_date",
+	"idx_commits_author_login",
+	"idx_commits_orphaned",
+	"idx_commits_contributor_id",
+	"idx_commits_first_release_id",
+	"idx_commits_deleted_at",
+}
+
+// uniqueCommitPerRepository replaces the global uniqueness of commit hashes with uniqueness per repository,
+// so that a fork and its upstream can both hold their shared commits
+func uniqueCommitPerRepository(tx *gorm.DB) error {
+	migrator := tx.Migrator()
+	// Version 1 made hashes unique with a constraint, rolling this migration back restores an index instead.
+	// SQLite drops constraints by rebuilding the table, which loses its indexes and the search triggers.
+	rebuilt := false
+	if migrator.HasConstraint(&v1Commit{}, commitHashUnique) {
+		if err := migrator.DropConstraint(&v1Commit{}, commitHashUnique); err != nil {
+			return fmt.Errorf("failed to drop unique commit hash constraint: %w", err)
+		}
+		rebuilt = tx.Dialector.Name() == "sqlite"
+	} else if err := migrator.DropIndex(&v1Commit{}, commitHashUnique); err != nil {
+		return fmt.Errorf("failed to drop unique commit hash index: %w", err)
+	}
+
+	if rebuilt {
+		for _, index := range v1CommitIndexes {
+			if err := migrator.CreateIndex(&v1Commit{}, index); err != nil {
+				return fmt.Errorf("failed to restore commit index %s: %w", index, err)
+			}
+		}
+		if err := createCommitSearchIndex(tx); err != nil {
+			return err
+		}
+	} else if err := migrator.DropIndex(&v1Commit{}, commitIdentityIndex); err != nil {
+		return fmt.Errorf("failed to drop commit index: %w", err)
+	}
+
+	// The index of the same name used to cover the repository alone
+	if err := tx.Exec("CREATE UNIQUE INDEX " + commitIdentityIndex + " ON commits (repo_id, commit_hash)").Error; err != nil {
+		return fmt.Errorf("failed to create commit identity index: %w", err)
+	}
+	return nil
+}
+
+// uniqueCommitHash restores the global uniqueness of commit hashes, which fails while forks share commits
+func uniqueCommitHash(tx *gorm.DB) error {
+	var duplicates int64
+	err := tx.Table("commits").
+		Select("commit_hash").
+		Group("commit_hash").
+		Having("COUNT(*) > 1").
+		Count(&duplicates).Error
+	if err != nil {
+		return fmt.Errorf("failed to look for shared commits: %w", err)
+	}
+	if duplicates > 0 {
+		return fmt.Errorf("%d commits are held by several repositories, remove the forks first", duplicates)
+	}
+
+	if err := tx.Migrator().DropIndex(&v1Commit{}, commitIdentityIndex); err != nil {
+		return fmt.Errorf("failed to drop commit identity index: %w", err)
+	}
+	statements := []string{
+		"CREATE INDEX " + commitIdentityIndex + " ON commits (repo_id)",
+		"CREATE UNIQUE INDEX " + commitHashUnique + " ON commits (commit_hash)",
+	}
+	for _, statement := range statements {
+		if err := tx.Exec(statement).Error; err != nil {
+			return fmt.Errorf("failed to restore commit hash uniqueness: %w", err)
+		}
+	}
+	return nil
+}
+
+// repositoryNameUnique names the constraint that made repository names unique across all providers and hosts
+const repositoryNameUnique = "uni_repositories_name"
+
+// repositoryIdentityIndex names the unique index of repositories by provider, host and name
+const repositoryIdentityIndex = "idx_repository_identity"
+
+// canonicalRepositoryHosts rewrite the stored hosts to the form they are looked up in: lower case, without the https
+// scheme or a trailing slash, and empty for github.com and gitlab.com
+var canonicalRepositoryHosts = []string{
+	"UPDATE repositories SET host = '' WHERE host IS NULL",
+	"UPDATE repositories SET host = LOWER(TRIM(host))",
+	"UPDATE repositories SET host = SUBSTR(host, 9) WHERE host LIKE 'https://%'",
+	"UPDATE repositories SET host = SUBSTR(host, 1, LENGTH(host) - 1) WHERE host LIKE '%/'",
+	"UPDATE repositories SET host = '' WHERE (provider = 'github' AND host IN ('github.com', 'api.github.com')) " +
+		"OR (provider = 'gitlab' AND host = 'gitlab.com')",
+}
+
+// uniqueRepositoryPerHost identifies repositories by provider, host and name instead of by name alone,
+// so that repositories of the same name on different hosts can be tracked side by side
+func uniqueRepositoryPerHost(tx *gorm.DB) error {
+	for _, statement := range canonicalRepositoryHosts {
+		if err := tx.Exec(statement).Error; err != nil {
+			return fmt.Errorf("failed to normalize repository hosts: %w", err)
+		}
+	}
+
+	// Version 1 made names unique with a constraint, rolling this migration back restores an index instead.
+	// SQLite drops constraints by rebuilding the table, which loses its index of deleted rows.
+	migrator := tx.Migrator()
+	if migrator.HasConstraint(&v1Repository{}, repositoryNameUnique) {
+		if err := migrator.DropConstraint(&v1Repository{}, repositoryNameUnique); err != nil {
+			return fmt.Errorf("failed to drop unique repository name constraint: %w", err)
+		}
+		if tx.Dialector.Name() == "sqlite" {
+			if err := migrator.CreateIndex(&v1Repository{}, "idx_repositories_deleted_at"); err != nil {
+				return fmt.Errorf("failed to restore repository index: %w", err)
+			}
+		}
+	} else if err := migrator.DropIndex(&v1Repository{}, repositoryNameUnique); err != nil {
+		return fmt.Errorf("failed to drop unique repository name index: %w", err)
+	}
+
+	statement := "CREATE UNIQUE INDEX " + repositoryIdentityIndex + " ON repositories (provider, host, name)"
+	if err := tx.Exec(statement).Error; err != nil {
+		return fmt.Errorf("failed to create repository identity index: %w", err)
+	}
+	return nil
+}
+
+// uniqueRepositoryName restores the global uniqueness of repository names, which fails while a name is tracked on
+// several hosts
+func uniqueRepositoryName(tx *gorm.DB) error {
+	var duplicates int64
+	err := tx.Table("repositories").
+		Select("name").
+		Group("name").
+		Having("COUNT(*) > 1").
+		Count(&duplicates).Error
+	if err != nil {
+		return fmt.Errorf("failed to look for repository names on several hosts: %w", err)
+	}
+	if duplicates > 0 {
+		return fmt.Errorf("%d repository names are tracked on several hosts, remove them first", duplicates)
+	}
+
+	if err := tx.Migrator().DropIndex(&v1Repository{}, repositoryIdentityIndex); err != nil {
+		return fmt.Errorf("failed to drop repository identity index: %w", err)
+	}
+	if err := tx.Exec("CREATE UNIQUE INDEX " + repositoryNameUnique + " ON repositories (name)").Error; err != nil {
+		return fmt.Errorf("failed to restore repository name uniqueness: %w", err)
+	}
+	return nil
+}
+
+// v4Repository holds the column version 4 adds to the repositories table
+type v4Repository struct {
+	ReleasesResolvedUpTo uint `gorm:"not null;default:0"`
+}
+
+func (v4Repository) TableName() string { return "repositories" }
+
+// addReleasesResolvedUpTo records how far the commits of each repository were attributed to releases, so that a
+// restart does not walk the whole history of every repository again
+func addReleasesResolvedUpTo(tx *gorm.DB) error {
+	if err := tx.Migrator().AddColumn(&v4Repository{}, "ReleasesResolvedUpTo"); err != nil {
+		return fmt.Errorf("failed to add release resolution progress: %w", err)
+	}
+	return nil
+}
+
+// dropReleasesResolvedUpTo drops the release resolution progress, the next resolution walks every history again.
+// SQLite drops columns in place with ALTER TABLE, unlike the migrator, which rebuilds the table without its indexes.
+func dropReleasesResolvedUpTo(tx *gorm.DB) error {
+	if err := tx.Exec("ALTER TABLE repositories DROP COLUMN releases_resolved_up_to").Error; err != nil {
+		return fmt.Errorf("failed to drop release resolution progress: %w", err)
+	}
+	return nil
+}
+
+// commitSearchTriggers keep the SQLite full-text index of commit messages in step with the commits table
+var commitSearchTriggers = []string{
+	`CREATE TRIGGER IF NOT EXISTS commits_fts_insert AFTER INSERT ON commits BEGIN
+		INSERT INTO commits_fts(rowid, message) VALUES (new.id, new.message);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS commits_fts_delete AFTER DELETE ON commits BEGIN
+		INSERT INTO commits_fts(commits_fts, rowid, message) VALUES ('delete', old.id, old.message);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS commits_fts_update AFTER UPDATE OF message ON commits BEGIN
+		INSERT INTO commits_fts(commits_fts, rowid, message) VALUES ('delete', old.id, old.message);
+		INSERT INTO commits_fts(rowid, message) VALUES (new.id, new.message);
+	END`,
+}
+
+// commitSearchIndex names the full-text index of commit messages on PostgreSQL and MySQL
+const commitSearchIndex = "idx_commits_message_search"
+
+// createCommitSearchIndex creates the full-text index of commit messages used by commit search:
+// an FTS5 table on SQLite, a tsvector GIN index on PostgreSQL and a FULLTEXT index on MySQL
+func createCommitSearchIndex(db *gorm.DB) error {
+	switch db.Dialector.Name() {
+	case "postgres":
+		err := db.Exec("CREATE INDEX IF NOT EXISTS " + commitSearchIndex + " ON commits USING GIN (to_tsvector('simple', message))").Error
+		if err != nil {
+			return fmt.Errorf("failed to create commit search index: %w", err)
+		}
+		return nil
+	case "mysql":
+		if db.Migrator().HasIndex(&v1Commit{}, commitSearchIndex) {
+			return nil
+		}
+		if err := db.Exec("CREATE FULLTEXT INDEX " + commitSearchIndex + " ON commits (message)").Error; err != nil {
+			return fmt.Errorf("failed to create commit search index: %w", err)
+		}
+		return nil
+	}
+
+	exists := db.Migrator().HasTable("commits_fts")
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec("CREATE VIRTUAL TABLE IF NOT EXISTS commits_fts USING fts5(message, content='commits', content_rowid='id')").Error
+		if err != nil {
+			return fmt.Errorf("failed to create commit search index: %w", err)
+		}
+		for _, trigger := range commitSearchTriggers {
+			if err := tx.Exec(trigger).Error; err != nil {
+				return fmt.Errorf("failed to create commit search trigger: %w", err)
+			}
+		}
+		if !exists {
+			if err := tx.Exec("INSERT INTO commits_fts(commits_fts) VALUES ('rebuild')").Error; err != nil {
+				return fmt.Errorf("failed to build commit search index: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// dropCommitSearchIndex drops the full-text index of commit messages along with the SQLite triggers feeding it
+func dropCommitSearchIndex(tx *gorm.DB) error {
+	var statements []string
+	switch tx.Dialector.Name() {
+	case "postgres":
+		statements = []string{"DROP INDEX IF EXISTS " + commitSearchIndex}
+	case "mysql":
+		if !tx.Migrator().HasIndex(&v1Commit{}, commitSearchIndex) {
+			return nil
+		}
+		statements = []string{"DROP INDEX " + commitSearchIndex + " ON commits"}
+	default:
+		statements = []string{
+			"DROP TRIGGER IF EXISTS commits_fts_insert",
+			"DROP TRIGGER IF EXISTS commits_fts_delete",
+			"DROP TRIGGER IF EXISTS commits_fts_update",
+			"DROP TABLE IF EXISTS commits_fts",
+		}
+	}
+
+	for _, statement := range statements {
+		if err := tx.Exec(statement).Error; err != nil {
+			return fmt.Errorf("failed to drop commit search index: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package db
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// The structs below freeze the tables of the initial schema. They must never change: later schema changes are made by
+// new migrations, so that every database reaches the latest version through the same steps whatever build created it.
+
+type v1Repository struct {
+	gorm.Model
+	Name                  string     `gorm:"unique;not null;size:255"`
+	Provider              string     `gorm:"not null;size:20;default:github"`
+	Host                  string     `gorm:"size:255"`
+	InstallationID        int64      `gorm:"default:0"`
+	Description           string     `gorm:"type:TEXT"`
+	URL                   string     `gorm:"not null;size:255"`
+	Language              string     `gorm:"size:50"`
+	ForksCount            int        `gorm:"default:0"`
+	StarsCount            int        `gorm:"default:0"`
+	OpenIssuesCount       int        `gorm:"default:0"`
+	WatchersCount         int        `gorm:"default:0"`
+	DefaultBranch         string     `gorm:"size:255"`
+	CreatedAt             time.Time  `gorm:"not null"`
+	UpdatedAt             time.Time  `gorm:"not null"`
+	Commits               []v1Commit `gorm:"foreignKey:RepoID;references:ID"`
+	TrackedBranches       string     `gorm:"type:TEXT"`
+	LastCommitSHA         string     `gorm:"size:40"`
+	LastCommitDate        *time.Time
+	LastSyncedAt          *time.Time
+	LastSyncError         string `gorm:"type:TEXT"`
+	SyncFrom              *time.Time
+	MetadataSyncedAt      *time.Time
+	ReleasesSyncedAt      *time.Time
+	PullRequestsUpdatedAt *time.Time
+	IssuesUpdatedAt       *time.Time
+	LastWebhookAt         *time.Time
+}
+
+func (v1Repository) TableName() string { return "repositories" }
+
+type v1Commit struct {
+	gorm.Model
+	RepoID             uint      `gorm:"not null;index:idx_repo_id_commit_hash;index:idx_repo_id_commit_date,priority:1"`
+	CommitHash         string    `gorm:"unique;not null;size:40"`
+	Author             string    `gorm:"not null;size:255"`
+	AuthorEmail        string    `gorm:"size:255"`
+	AuthorLogin        string    `gorm:"size:100;index"`
+	Message            string    `gorm:"not null;type:TEXT"`
+	CommitDate         time.Time `gorm:"not null;index:idx_repo_id_commit_date,priority:2"`
+	CommitURL          string    `gorm:"not null;size:255"`
+	CommitterName      string    `gorm:"size:255"`
+	CommitterEmail     string    `gorm:"size:255"`
+	CommitterDate      time.Time
+	Parents            string `gorm:"type:TEXT"`
+	TreeSHA            string `gorm:"size:40"`
+	Verified           bool   `gorm:"default:false"`
+	VerificationReason string `gorm:"size:50"`
+	Orphaned           bool   `gorm:"default:false;index"`
+	OrphanedAt         *time.Time
+	ContributorID      *uint          `gorm:"index"`
+	FirstReleaseID     *uint          `gorm:"index"`
+	Files              []v1CommitFile `gorm:"foreignKey:CommitID"`
+}
+
+func (v1Commit) TableName() string { return "commits" }
+
+type v1Contributor struct {
+	gorm.Model
+	Name    string               `gorm:"size:255;index"`
+	Email   string               `gorm:"size:255"`
+	Login   string               `gorm:"size:100"`
+	Aliases []v1ContributorAlias `gorm:"foreignKey:ContributorID"`
+}
+
+func (v1Contributor) TableName() string { return "contributors" }
+
+type v1ContributorAlias struct {
+	gorm.Model
+	ContributorID uint   `gorm:"not null;index"`
+	Kind          string `gorm:"not null;size:10;uniqueIndex:idx_kind_value"`
+	Value         string `gorm:"not null;size:255;uniqueIndex:idx_kind_value"`
+}
+
+func (v1ContributorAlias) TableName() string { return "contributor_aliases" }
+
+type v1CommitFile struct {
+	gorm.Model
+	CommitID         uint   `gorm:"not null;index"`
+	Filename         string `gorm:"not null;size:1024"`
+	PreviousFilename string `gorm:"size:1024"`
+	Directory        string `gorm:"not null;size:1024;index:,length:255"`
+	Status           string `gorm:"size:20"`
+	Additions        int    `gorm:"default:0"`
+	Deletions        int    `gorm:"default:0"`
+}
+
+func (v1CommitFile) TableName() string { return "commit_files" }
+
+type v1Branch struct {
+	gorm.Model
+	RepoID         uint   `gorm:"not null;uniqueIndex:idx_repo_id_branch_name"`
+	Name           string `gorm:"not null;size:255;uniqueIndex:idx_repo_id_branch_name"`
+	LastCommitSHA  string `gorm:"size:40"`
+	LastCommitDate *time.Time
+	LastSyncedAt   *time.Time
+	HeadSHA        string `gorm:"size:40"`
+}
+
+func (v1Branch) TableName() string { return "branches" }
+
+type v1CommitBranch struct {
+	gorm.Model
+	CommitID uint   `gorm:"not null;uniqueIndex:idx_commit_id_branch"`
+	Branch   string `gorm:"not null;size:255;uniqueIndex:idx_commit_id_branch;index"`
+}
+
+func (v1CommitBranch) TableName() string { return "commit_branches" }
+
+type v1HistoryRewrite struct {
+	gorm.Model
+	RepoID     uint      `gorm:"not null;index"`
+	Branch     string    `gorm:"not null;size:255"`
+	OldHeadSHA string    `gorm:"not null;size:40"`
+	NewHeadSHA string    `gorm:"not null;size:40"`
+	DetectedAt time.Time `gorm:"not null;index"`
+	LostSHAs   string    `gorm:"type:TEXT"`
+}
+
+func (v1HistoryRewrite) TableName() string { return "history_rewrites" }
+
+type v1Tag struct {
+	gorm.Model
+	RepoID    uint   `gorm:"not null;uniqueIndex:idx_repo_id_tag_name"`
+	Name      string `gorm:"not null;size:255;uniqueIndex:idx_repo_id_tag_name"`
+	TargetSHA string `gorm:"size:40;index"`
+}
+
+func (v1Tag) TableName() string { return "tags" }
+
+type v1Release struct {
+	gorm.Model
+	RepoID          uint       `gorm:"not null;uniqueIndex:idx_repo_id_release_tag"`
+	TagName         string     `gorm:"not null;size:255;uniqueIndex:idx_repo_id_release_tag"`
+	Name            string     `gorm:"size:255"`
+	TargetSHA       string     `gorm:"size:40"`
+	Draft           bool       `gorm:"default:false"`
+	Prerelease      bool       `gorm:"default:false"`
+	Notes           string     `gorm:"type:TEXT"`
+	URL             string     `gorm:"size:255"`
+	PublishedAt     *time.Time `gorm:"index"`
+	CommitsResolved bool       `gorm:"default:false"`
+}
+
+func (v1Release) TableName() string { return "releases" }
+
+type v1PullRequest struct {
+	gorm.Model
+	RepoID         uint      `gorm:"not null;uniqueIndex:idx_repo_id_number"`
+	Number         int       `gorm:"not null;uniqueIndex:idx_repo_id_number"`
+	Title          string    `gorm:"type:TEXT"`
+	Author         string    `gorm:"size:255;index"`
+	State          string    `gorm:"size:20;index"`
+	BaseBranch     string    `gorm:"size:255"`
+	HeadBranch     string    `gorm:"size:255"`
+	MergeCommitSHA string    `gorm:"size:40"`
+	Additions      int       `gorm:"default:0"`
+	Deletions      int       `gorm:"default:0"`
+	ReviewCount    int       `gorm:"default:0"`
+	OpenedAt       time.Time `gorm:"index"`
+	FirstReviewAt  *time.Time
+	MergedAt       *time.Time
+	ClosedAt       *time.Time
+	LastActivityAt time.Time
+}
+
+func (v1PullRequest) TableName() string { return "pull_requests" }
+
+type v1Issue struct {
+	gorm.Model
+	RepoID         uint      `gorm:"not null;uniqueIndex:idx_repo_id_issue_number"`
+	Number         int       `gorm:"not null;uniqueIndex:idx_repo_id_issue_number"`
+	Title          string    `gorm:"type:TEXT"`
+	Author         string    `gorm:"size:255;index"`
+	State          string    `gorm:"size:20;index"`
+	Labels         string    `gorm:"type:TEXT"`
+	Assignees      string    `gorm:"type:TEXT"`
+	OpenedAt       time.Time `gorm:"index"`
+	ClosedAt       *time.Time
+	LastActivityAt time.Time
+}
+
+func (v1Issue) TableName() string { return "issues" }
+
+type v1RepositorySnapshot struct {
+	gorm.Model
+	RepoID     uint      `gorm:"not null;index:idx_repo_id_recorded_at"`
+	RecordedAt time.Time `gorm:"not null;index:idx_repo_id_recorded_at"`
+	Stars      int       `gorm:"default:0"`
+	Forks      int       `gorm:"default:0"`
+	Watchers   int       `gorm:"default:0"`
+	OpenIssues int       `gorm:"default:0"`
+}
+
+func (v1RepositorySnapshot) TableName() string { return "repository_snapshots" }
+
+// v1Tables are the tables of the initial schema, in creation order
+var v1Tables = []interface{}{
+	&v1Repository{},
+	&v1Commit{},
+	&v1Contributor{},
+	&v1ContributorAlias{},
+	&v1CommitFile{},
+	&v1Branch{},
+	&v1CommitBranch{},
+	&v1HistoryRewrite{},
+	&v1Tag{},
+	&v1Release{},
+	&v1PullRequest{},
+	&v1Issue{},
+	&v1RepositorySnapshot{},
+}
@@ -0,0 +1,201 @@
+package fetcher
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"gmonitor/internal/models"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// appJWTLifetime is the validity of app JWTs, GitHub accepts at most ten minutes
+	appJWTLifetime = 9 * time.Minute
+	// appClockSkew backdates JWTs to tolerate clock drift between us and GitHub
+	appClockSkew = time.Minute
+	// tokenRefreshMargin is how long before expiry an installation token is replaced
+	tokenRefreshMargin = 5 * time.Minute
+)
+
+// installationToken is an installation access token together with its expiry
+type installationToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// AppTokenSource authenticates as a GitHub App and hands out installation tokens per repository
+type AppTokenSource struct {
+	AppID      int64
+	PrivateKey *rsa.PrivateKey
+	BaseURL    string
+	HTTPClient *http.Client
+
+	mu            sync.Mutex
+	installations map[string]int64
+	tokens        map[int64]installationToken
+}
+
+// NewAppTokenSource creates an app token source from the app ID and its PEM encoded private key
+func NewAppTokenSource(appID int64, privateKeyPEM []byte, baseURL string) (*AppTokenSource, error) {
+	key, err := parsePrivateKey(privateKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+	if baseURL == "" {
+		baseURL = DefaultGitHubAPIURL
+	}
+
+	return &AppTokenSource{
+		AppID:         appID,
+		PrivateKey:    key,
+		BaseURL:       strings.TrimSuffix(baseURL, "/"),
+		HTTPClient:    &http.Client{Timeout: 10 * time.Second},
+		installations: make(map[string]int64),
+		tokens:        make(map[int64]installationToken),
+	}, nil
+}
+
+// Token returns an installation token for the repository, recording the owning installation on it
+func (a *AppTokenSource) Token(repo *models.Repository) (string, error) {
+	installationID := repo.InstallationID
+	if installationID == 0 {
+		id, err := a.installationID(repo.Name)
+		if err != nil {
+			return "", err
+		}
+		installationID = id
+		repo.InstallationID = id
+	}
+
+	a.mu.Lock()
+	cached, found := a.tokens[installationID]
+	a.mu.Unlock()
+	if found && time.Until(cached.ExpiresAt) > tokenRefreshMargin {
+		return cached.Token, nil
+	}
+
+	token, err := a.createInstallationToken(installationID)
+	if err != nil {
+		return "", err
+	}
+
+	a.mu.Lock()
+	a.tokens[installationID] = token
+	a.mu.Unlock()
+	return token.Token, nil
+}
+
+// installationID looks up the installation of the app that owns a repository
+func (a *AppTokenSource) installationID(repoName string) (int64, error) {
+	a.mu.Lock()
+	id, found := a.installations[repoName]
+	a.mu.Unlock()
+	if found {
+		return id, nil
+	}
+
+	var installation struct {
+		ID int64 `json:"id"`
+	}
+	if err := a.call("GET", fmt.Sprintf("%s/repos/%s/installation", a.BaseURL, repoName), &installation); err != nil {
+		return 0, fmt.Errorf("error finding app installation for %s: %v", repoName, err)
+	}
+
+	a.mu.Lock()
+	a.installations[repoName] = installation.ID
+	a.mu.Unlock()
+	return installation.ID, nil
+}
+
+// createInstallationToken exchanges an app JWT for a fresh installation access token
+func (a *AppTokenSource) createInstallationToken(installationID int64) (installationToken, error) {
+	var token installationToken
+	url := fmt.Sprintf("%s/app/installations/%d/access_tokens", a.BaseURL, installationID)
+	if err := a.call("POST", url, &token); err != nil {
+		return installationToken{}, fmt.Errorf("error creating installation token: %v", err)
+	}
+	return token, nil
+}
+
+// call sends a request authenticated with an app JWT and decodes the JSON response into v
+func (a *AppTokenSource) call(method, url string, v interface{}) error {
+	jwt, err := a.jwt(time.Now())
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return fmt.Errorf("error creating request: %v", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	req.Header.Set("Authorization", "Bearer "+jwt)
+
+	resp, err := a.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error making request: %v", err)
+	}
+	defer closeBody(resp.Body)
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("GitHub API returned status: %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("error decoding JSON: %v", err)
+	}
+	return nil
+}
+
+// jwt mints an RS256 signed JSON Web Token identifying the app
+func (a *AppTokenSource) jwt(now time.Time) (string, error) {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]interface{}{
+		"iat": now.Add(-appClockSkew).Unix(),
+		"exp": now.Add(appJWTLifetime).Unix(),
+		"iss": strconv.FormatInt(a.AppID, 10),
+	})
+	if err != nil {
+		return "", fmt.Errorf("error encoding JWT claims: %v", err)
+	}
+
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, a.PrivateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("error signing JWT: %v", err)
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// parsePrivateKey decodes a PKCS#1 or PKCS#8 PEM encoded RSA private key
+func parsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("invalid app private key: no PEM data found")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid app private key: %v", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("invalid app private key: not an RSA key")
+	}
+	return key, nil
+}
@@ -0,0 +1,127 @@
+package fetcher
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"gmonitor/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func generateTestKey(t *testing.T) (*rsa.PrivateKey, []byte) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	pemData := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	return key, pemData
+}
+
+// verifyTestJWT checks the RS256 signature and issuer of an app JWT
+func verifyTestJWT(t *testing.T, key *rsa.PrivateKey, authorization string) {
+	parts := strings.Split(strings.TrimPrefix(authorization, "Bearer "), ".")
+	if len(parts) != 3 {
+		t.Fatalf("malformed JWT: %s", authorization)
+	}
+
+	signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+		t.Errorf("invalid JWT signature: %v", err)
+	}
+
+	payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	var claims struct {
+		Iss string `json:"iss"`
+		Iat int64  `json:"iat"`
+		Exp int64  `json:"exp"`
+	}
+	_ = json.Unmarshal(payload, &claims)
+	if claims.Iss != "42" || claims.Exp-claims.Iat > 600 {
+		t.Errorf("unexpected JWT claims: %+v", claims)
+	}
+}
+
+func TestAppTokenSource_Token(t *testing.T) {
+	key, pemData := generateTestKey(t)
+	lookups, exchanges := 0, 0
+	expiresIn := time.Hour
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		verifyTestJWT(t, key, r.Header.Get("Authorization"))
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/repos/org/repo/installation":
+			lookups++
+			_, _ = w.Write([]byte(`{"id": 7}`))
+		case r.Method == "POST" && r.URL.Path == "/app/installations/7/access_tokens":
+			exchanges++
+			w.WriteHeader(http.StatusCreated)
+			_, _ = fmt.Fprintf(w, `{"token": "ghs_%d", "expires_at": %q}`, exchanges, time.Now().Add(expiresIn).Format(time.RFC3339))
+		default:
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	source, err := NewAppTokenSource(42, pemData, server.URL)
+	if err != nil {
+		t.Fatalf("failed to create token source: %v", err)
+	}
+
+	repo := &models.Repository{Name: "org/repo"}
+	token, err := source.Token(repo)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if token != "ghs_1" || repo.InstallationID != 7 {
+		t.Errorf("unexpected token %q for installation %d", token, repo.InstallationID)
+	}
+
+	// Cached tokens are reused while they are far from expiry
+	if token, _ := source.Token(&models.Repository{Name: "org/repo"}); token != "ghs_1" {
+		t.Errorf("expected cached token, got %q", token)
+	}
+	if lookups != 1 || exchanges != 1 {
+		t.Errorf("expected 1 lookup and 1 exchange, got %d and %d", lookups, exchanges)
+	}
+
+	// Tokens close to expiry are refreshed
+	source.tokens[7] = installationToken{Token: "ghs_old", ExpiresAt: time.Now().Add(time.Minute)}
+	if token, _ := source.Token(repo); token != "ghs_2" {
+		t.Errorf("expected refreshed token, got %q", token)
+	}
+}
+
+func TestAppTokenSource_InstallationNotFound(t *testing.T) {
+	_, pemData := generateTestKey(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	source, err := NewAppTokenSource(42, pemData, server.URL)
+	if err != nil {
+		t.Fatalf("failed to create token source: %v", err)
+	}
+
+	if _, err := source.Token(&models.Repository{Name: "org/missing"}); err == nil {
+		t.Error("expected error for a repository without installation")
+	}
+}
+
+func TestNewAppTokenSource_InvalidKey(t *testing.T) {
+	if _, err := NewAppTokenSource(42, []byte("not a key"), ""); err == nil {
+		t.Error("expected error for an invalid private key")
+	}
+}
@@ -0,0 +1,44 @@
+package fetcher
+
+import (
+	"path"
+	"strings"
+)
+
+// AllBranches is the tracking pattern that selects every branch of a repository
+const AllBranches = "*"
+
+// TracksBranch reports whether a branch is selected by the tracking patterns of a repository.
+// The default branch is always tracked, other branches match an exact name, a glob pattern or AllBranches.
+func TracksBranch(patterns []string, defaultBranch, branch string) bool {
+	if branch == "" {
+		return false
+	}
+	if branch == defaultBranch {
+		return true
+	}
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == AllBranches || pattern == branch {
+			return true
+		}
+		if matched, err := path.Match(pattern, branch); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchBranches returns the branches selected by the tracking patterns, the default branch first
+func MatchBranches(patterns []string, defaultBranch string, branches []string) []string {
+	matched := make([]string, 0, len(branches))
+	if defaultBranch != "" {
+		matched = append(matched, defaultBranch)
+	}
+	for _, branch := range branches {
+		if branch != defaultBranch && TracksBranch(patterns, defaultBranch, branch) {
+			matched = append(matched, branch)
+		}
+	}
+	return matched
+}
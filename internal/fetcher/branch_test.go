@@ -0,0 +1,35 @@
+package fetcher
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTracksBranch(t *testing.T) {
+	patterns := []string{"develop", "release/*"}
+
+	cases := map[string]bool{
+		"main":          true,
+		"develop":       true,
+		"release/1.2":   true,
+		"release/1/fix": false,
+		"feature/login": false,
+		"":              false,
+	}
+	for branch, expected := range cases {
+		if got := TracksBranch(patterns, "main", branch); got != expected {
+			t.Errorf("TracksBranch(%q) = %v, expected %v", branch, got, expected)
+		}
+	}
+
+	if !TracksBranch([]string{AllBranches}, "main", "feature/login") {
+		t.Error("expected every branch to be tracked with the all branches pattern")
+	}
+}
+
+func TestMatchBranches_DefaultBranchFirst(t *testing.T) {
+	branches := MatchBranches([]string{"release/*"}, "main", []string{"release/1.0", "feature/x", "main"})
+	if !reflect.DeepEqual(branches, []string{"main", "release/1.0"}) {
+		t.Errorf("unexpected branches: %v", branches)
+	}
+}
@@ -1,48 +1,326 @@
 package fetcher
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"math/rand/v2"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
-// makeGitHubRequest sends an authenticated request to the GitHub API
-func makeGitHubRequest(url, token string) (*http.Response, error) {
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-		Transport: &http.Transport{
-			MaxIdleConns:        10,
-			MaxIdleConnsPerHost: 5,
-			IdleConnTimeout:     90 * time.Second,
+// secondaryLimitWait is how long to back off from a secondary rate limit that carries no Retry-After header
+const secondaryLimitWait = time.Minute
+
+// maxErrorBody caps how much of an error response is read to tell its cause
+const maxErrorBody = 4096
+
+// defaultClient is shared by every GitHubFetcher so that they all draw from the same rate limit budget
+var defaultClient = NewClient()
+
+// API describes the conventions of a provider REST API that a Client follows
+type API struct {
+	// Name identifies the API in errors and logs
+	Name string
+	// Headers are set on every request
+	Headers map[string]string
+	// AuthScheme precedes the token in the Authorization header
+	AuthScheme string
+	// RateLimitHeaders is the prefix of the Limit, Remaining and Reset headers reporting the budget, the reset being
+	// a Unix time. It is empty for APIs that do not report a budget.
+	RateLimitHeaders string
+}
+
+// APIs of the supported providers
+var (
+	GitHubAPI = API{
+		Name:             "GitHub",
+		Headers:          map[string]string{"Accept": "application/vnd.github.v3+json", "X-GitHub-Api-Version": "2022-11-28"},
+		AuthScheme:       "Bearer",
+		RateLimitHeaders: "X-RateLimit-",
+	}
+	GitLabAPI = API{
+		Name:             "GitLab",
+		Headers:          map[string]string{"Accept": "application/json"},
+		AuthScheme:       "Bearer",
+		RateLimitHeaders: "RateLimit-",
+	}
+	GiteaAPI = API{
+		Name:       "Gitea",
+		Headers:    map[string]string{"Accept": "application/json"},
+		AuthScheme: "token",
+	}
+)
+
+// RateLimit describes the API budget as last reported by the response headers
+type RateLimit struct {
+	Limit       int       `json:"limit"`
+	Remaining   int       `json:"remaining"`
+	Reset       time.Time `json:"reset"`
+	PausedUntil time.Time `json:"paused_until"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	// Tokens lists the per-token budgets when a token pool is in use
+	Tokens []TokenStatus `json:"tokens,omitempty"`
+}
+
+// RateLimitError is returned when an API refuses a request because a rate limit was hit
+type RateLimitError struct {
+	API       string
+	RetryAt   time.Time
+	Secondary bool
+}
+
+func (e *RateLimitError) Error() string {
+	kind := "primary"
+	if e.Secondary {
+		kind = "secondary"
+	}
+	return strings.TrimSpace(fmt.Sprintf("%s %s rate limit exceeded, retry at %s", e.API, kind, e.RetryAt.Format(time.RFC3339)))
+}
+
+// StatusError is returned for responses with a status code that is neither success nor retried
+type StatusError struct {
+	API        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return strings.TrimSpace(fmt.Sprintf("%s API returned status: %d", e.API, e.StatusCode))
+}
+
+// isNotFound reports whether a request failed because the requested object does not exist
+func isNotFound(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && (statusErr.StatusCode == http.StatusNotFound || statusErr.StatusCode == http.StatusUnprocessableEntity)
+}
+
+// Client is an HTTP client for a provider API that tracks the rate limit budget and retries server errors
+type Client struct {
+	API         API
+	HTTPClient  *http.Client
+	MaxRetries  int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// Pool, when set, receives the budget of each token and rate limits pause single tokens instead of the client
+	Pool *TokenPool
+
+	mu   sync.Mutex
+	rate RateLimit
+}
+
+// NewClient creates a GitHub API Client with pooled connections and default retry settings
+func NewClient() *Client {
+	return NewAPIClient(GitHubAPI)
+}
+
+// NewAPIClient creates a Client for the given API with pooled connections and default retry settings
+func NewAPIClient(api API) *Client {
+	return &Client{
+		API: api,
+		HTTPClient: &http.Client{
+			Timeout: 10 * time.Second,
+			Transport: &http.Transport{
+				MaxIdleConns:        10,
+				MaxIdleConnsPerHost: 5,
+				IdleConnTimeout:     90 * time.Second,
+			},
 		},
+		MaxRetries:  3,
+		BaseBackoff: 500 * time.Millisecond,
+		MaxBackoff:  30 * time.Second,
 	}
+}
 
+// makeGitHubRequest sends an authenticated request to the GitHub API
+func makeGitHubRequest(url, token string, header http.Header) (*http.Response, error) {
+	return defaultClient.Do(url, token, header)
+}
+
+// Do sends an authenticated GET request, retrying 5xx responses with jittered exponential backoff.
+// Both 200 and 304 Not Modified responses are returned to the caller.
+func (c *Client) Do(url, token string, header http.Header) (*http.Response, error) {
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("error creating request: %v", err)
 	}
 
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
-	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	for key, value := range c.API.Headers {
+		req.Header.Set(key, value)
+	}
 	if token != "" {
-		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Authorization", c.API.AuthScheme+" "+token)
 	}
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("error making request: %v", err)
+	if until := c.tokenPausedUntil(token); time.Now().Before(until) {
+		return nil, &RateLimitError{API: c.API.Name, RetryAt: until}
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		defer func(Body io.ReadCloser) {
-			err := Body.Close()
-			if err != nil {
-				log.Printf("error closing body: %v", err)
+	for attempt := 0; ; attempt++ {
+		resp, err := c.HTTPClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("error making request: %v", err)
+		}
+		// Conditional hits are not charged against the budget, so they leave it untouched
+		if resp.StatusCode == http.StatusNotModified {
+			return resp, nil
+		}
+
+		c.updateRateLimit(resp.Header)
+		if c.Pool != nil {
+			c.Pool.Observe(token, resp.StatusCode, resp.Header)
+		}
+		if resp.StatusCode == http.StatusOK {
+			return resp, nil
+		}
+		rateErr := c.rateLimitError(resp, attempt)
+		closeBody(resp.Body)
+
+		if rateErr != nil {
+			if c.Pool != nil && c.Pool.Owns(token) {
+				c.Pool.Pause(token, rateErr.RetryAt)
+			} else {
+				c.pause(rateErr.RetryAt)
 			}
-		}(resp.Body)
-		return nil, fmt.Errorf("GitHub API returned status: %d", resp.StatusCode)
+			return nil, rateErr
+		}
+
+		if resp.StatusCode >= http.StatusInternalServerError && attempt < c.MaxRetries {
+			wait := c.backoff(attempt)
+			log.Printf("%s API returned status %d, retrying in %v", c.API.Name, resp.StatusCode, wait)
+			time.Sleep(wait)
+			continue
+		}
+
+		return nil, &StatusError{API: c.API.Name, StatusCode: resp.StatusCode}
+	}
+}
+
+// RateLimit returns the last known rate limit budget
+func (c *Client) RateLimit() RateLimit {
+	c.mu.Lock()
+	rate := c.rate
+	c.mu.Unlock()
+
+	if c.Pool != nil {
+		rate.PausedUntil = c.Pool.PausedUntil()
+		rate.Tokens = c.Pool.Status()
+	}
+	return rate
+}
+
+// PausedUntil returns the time until which requests are held back because of a rate limit.
+// With a token pool, that is until the first pooled token becomes usable again.
+func (c *Client) PausedUntil() time.Time {
+	if c.Pool != nil {
+		return c.Pool.PausedUntil()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rate.PausedUntil
+}
+
+// tokenPausedUntil returns the time until which requests with the given token are held back.
+// Tokens the pool does not own, such as GitHub App installation tokens, share the pause of the client.
+func (c *Client) tokenPausedUntil(token string) time.Time {
+	if c.Pool != nil && c.Pool.Owns(token) {
+		return c.Pool.tokenPausedUntil(token)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rate.PausedUntil
+}
+
+// updateRateLimit records the budget reported by the rate limit headers of the API
+func (c *Client) updateRateLimit(header http.Header) {
+	prefix := c.API.RateLimitHeaders
+	if prefix == "" {
+		return
+	}
+	remaining, err := strconv.Atoi(header.Get(prefix + "Remaining"))
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rate.Remaining = remaining
+	if limit, err := strconv.Atoi(header.Get(prefix + "Limit")); err == nil {
+		c.rate.Limit = limit
+	}
+	if reset, err := strconv.ParseInt(header.Get(prefix+"Reset"), 10, 64); err == nil {
+		c.rate.Reset = time.Unix(reset, 0)
+	}
+	c.rate.UpdatedAt = time.Now()
+}
+
+// pause holds back further requests until the given time
+func (c *Client) pause(until time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if until.After(c.rate.PausedUntil) {
+		c.rate.PausedUntil = until
+	}
+}
+
+// backoff returns a jittered exponential delay for the given retry attempt
+func (c *Client) backoff(attempt int) time.Duration {
+	wait := c.BaseBackoff << attempt
+	if wait <= 0 || (c.MaxBackoff > 0 && wait > c.MaxBackoff) {
+		wait = c.MaxBackoff
+	}
+	if wait <= 0 {
+		return 0
+	}
+	return wait/2 + rand.N(wait/2+1)
+}
+
+// rateLimitError inspects a failed response and reports whether a primary or secondary rate limit was hit.
+// Secondary limits without Retry-After are backed off for a minute plus the jittered backoff of the attempt.
+func (c *Client) rateLimitError(resp *http.Response, attempt int) *RateLimitError {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return nil
+	}
+
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		return &RateLimitError{API: c.API.Name, RetryAt: time.Now().Add(time.Duration(seconds) * time.Second), Secondary: true}
+	}
+
+	if prefix := c.API.RateLimitHeaders; prefix != "" && resp.Header.Get(prefix+"Remaining") == "0" {
+		retryAt := time.Now().Add(secondaryLimitWait)
+		if reset, err := strconv.ParseInt(resp.Header.Get(prefix+"Reset"), 10, 64); err == nil {
+			retryAt = time.Unix(reset, 0)
+		}
+		return &RateLimitError{API: c.API.Name, RetryAt: retryAt}
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests || mentionsSecondaryLimit(resp.Body) {
+		return &RateLimitError{API: c.API.Name, RetryAt: time.Now().Add(secondaryLimitWait + c.backoff(attempt)), Secondary: true}
+	}
+	return nil
+}
+
+// mentionsSecondaryLimit reports whether an error body is GitHub's message for a secondary rate limit,
+// which a 403 may carry without Retry-After while the primary budget is left
+func mentionsSecondaryLimit(body io.Reader) bool {
+	message, err := io.ReadAll(io.LimitReader(body, maxErrorBody))
+	if err != nil {
+		return false
+	}
+	return strings.Contains(strings.ToLower(string(message)), "secondary rate limit")
+}
+
+// closeBody closes a response body, logging any error
+func closeBody(body io.ReadCloser) {
+	if err := body.Close(); err != nil {
+		log.Printf("error closing body: %v", err)
 	}
-	return resp, nil
 }
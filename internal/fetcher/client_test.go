@@ -1,11 +1,14 @@
 package fetcher
 
 import (
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 )
 
 // override HTTP client for testing
@@ -35,7 +38,7 @@ func TestMakeGitHubRequest_Success(t *testing.T) {
 	}))
 	defer server.Close()
 
-	resp, err := makeGitHubRequest(server.URL, "test_token")
+	resp, err := makeGitHubRequest(server.URL, "test_token", nil)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -53,7 +56,7 @@ func TestMakeGitHubRequest_HTTPError(t *testing.T) {
 	}))
 	defer server.Close()
 
-	resp, err := makeGitHubRequest(server.URL, "")
+	resp, err := makeGitHubRequest(server.URL, "", nil)
 	if err == nil {
 		t.Fatalf("expected error for non-200 response")
 	}
@@ -64,8 +67,235 @@ func TestMakeGitHubRequest_HTTPError(t *testing.T) {
 
 func TestMakeGitHubRequest_RequestCreationError(t *testing.T) {
 	// invalid URL will trigger request creation error
-	_, err := makeGitHubRequest("http://[::1]:namedport", "")
+	_, err := makeGitHubRequest("http://[::1]:namedport", "", nil)
 	if err == nil || !strings.Contains(err.Error(), "error creating request") {
 		t.Errorf("expected request creation error, got: %v", err)
 	}
 }
+
+func newTestClient() *Client {
+	client := NewClient()
+	client.BaseBackoff = time.Millisecond
+	client.MaxBackoff = 5 * time.Millisecond
+	return client
+}
+
+func TestClientDo_RetriesServerErrors(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	resp, err := newTestClient().Do(server.URL, "", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", attempts)
+	}
+}
+
+func TestClientDo_GivesUpAfterMaxRetries(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := newTestClient()
+	_, err := client.Do(server.URL, "", nil)
+	if err == nil {
+		t.Fatal("expected error after exhausting retries")
+	}
+	if attempts != client.MaxRetries+1 {
+		t.Errorf("expected %d attempts, got %d", client.MaxRetries+1, attempts)
+	}
+}
+
+func TestClientDo_TracksRateLimit(t *testing.T) {
+	reset := time.Now().Add(time.Hour).Unix()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", "4999")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset, 10))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := newTestClient()
+	resp, err := client.Do(server.URL, "", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	rate := client.RateLimit()
+	if rate.Limit != 5000 || rate.Remaining != 4999 || rate.Reset.Unix() != reset {
+		t.Errorf("unexpected rate limit: %+v", rate)
+	}
+}
+
+func TestClientDo_PrimaryRateLimitPauses(t *testing.T) {
+	attempts := 0
+	reset := time.Now().Add(time.Hour).Unix()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset, 10))
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	client := newTestClient()
+	_, err := client.Do(server.URL, "", nil)
+	var rateErr *RateLimitError
+	if !errors.As(err, &rateErr) || rateErr.Secondary {
+		t.Fatalf("expected primary rate limit error, got: %v", err)
+	}
+	if client.PausedUntil().Unix() != reset {
+		t.Errorf("expected pause until reset, got %v", client.PausedUntil())
+	}
+
+	// Paused clients must not hit the API at all
+	if _, err := client.Do(server.URL, "", nil); !errors.As(err, &rateErr) {
+		t.Errorf("expected rate limit error while paused, got: %v", err)
+	}
+	if attempts != 1 {
+		t.Errorf("expected 1 attempt, got %d", attempts)
+	}
+}
+
+func TestClientDo_SecondaryRateLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	_, err := newTestClient().Do(server.URL, "", nil)
+	var rateErr *RateLimitError
+	if !errors.As(err, &rateErr) || !rateErr.Secondary {
+		t.Fatalf("expected secondary rate limit error, got: %v", err)
+	}
+	if wait := time.Until(rateErr.RetryAt); wait < 25*time.Second || wait > 30*time.Second {
+		t.Errorf("unexpected retry time: %v", rateErr.RetryAt)
+	}
+}
+
+func TestClientDo_SecondaryRateLimitWithoutRetryAfter(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Header().Set("X-RateLimit-Remaining", "4000")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"message": "You have exceeded a secondary rate limit. Please wait a few minutes before you try again."}`))
+	}))
+	defer server.Close()
+
+	client := newTestClient()
+	_, err := client.Do(server.URL, "", nil)
+	var rateErr *RateLimitError
+	if !errors.As(err, &rateErr) || !rateErr.Secondary {
+		t.Fatalf("expected secondary rate limit error, got: %v", err)
+	}
+	if wait := time.Until(rateErr.RetryAt); wait < 55*time.Second || wait > secondaryLimitWait+client.MaxBackoff {
+		t.Errorf("unexpected retry time: %v", rateErr.RetryAt)
+	}
+
+	if _, err := client.Do(server.URL, "", nil); !errors.As(err, &rateErr) || attempts != 1 {
+		t.Errorf("expected the client to back off, got %d attempts (%v)", attempts, err)
+	}
+}
+
+func TestClientDo_ForbiddenIsNotRateLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "4000")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"message": "Resource not accessible by integration"}`))
+	}))
+	defer server.Close()
+
+	_, err := newTestClient().Do(server.URL, "", nil)
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusForbidden {
+		t.Errorf("expected a 403 status error, got: %v", err)
+	}
+}
+
+func TestClientDo_NotModified(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") != `"etag"` {
+			t.Errorf("expected If-None-Match header, got %q", r.Header.Get("If-None-Match"))
+		}
+		w.Header().Set("X-RateLimit-Remaining", "10")
+		w.WriteHeader(http.StatusNotModified)
+	}))
+	defer server.Close()
+
+	client := newTestClient()
+	resp, err := client.Do(server.URL, "", http.Header{"If-None-Match": []string{`"etag"`}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotModified {
+		t.Errorf("expected 304, got %d", resp.StatusCode)
+	}
+	if !client.RateLimit().UpdatedAt.IsZero() {
+		t.Error("expected not modified response to leave the budget untouched")
+	}
+}
+
+func TestClientDo_GitLabAPI(t *testing.T) {
+	reset := time.Now().Add(time.Minute).Unix()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-GitHub-Api-Version") != "" || r.Header.Get("Accept") != "application/json" {
+			t.Errorf("unexpected headers: %v", r.Header)
+		}
+		if r.Header.Get("Authorization") != "Bearer test_token" {
+			t.Errorf("expected Authorization header, got %q", r.Header.Get("Authorization"))
+		}
+		w.Header().Set("RateLimit-Limit", "2000")
+		w.Header().Set("RateLimit-Remaining", "0")
+		w.Header().Set("RateLimit-Reset", strconv.FormatInt(reset, 10))
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client := NewAPIClient(GitLabAPI)
+	_, err := client.Do(server.URL, "test_token", nil)
+	var rateErr *RateLimitError
+	if !errors.As(err, &rateErr) || !strings.HasPrefix(err.Error(), "GitLab ") {
+		t.Fatalf("expected GitLab rate limit error, got: %v", err)
+	}
+	if wait := time.Until(client.PausedUntil()); wait < 55*time.Second || wait > 60*time.Second {
+		t.Errorf("expected a pause for Retry-After, got %v", client.PausedUntil())
+	}
+	if rate := client.RateLimit(); rate.Limit != 2000 || rate.Remaining != 0 || rate.Reset.Unix() != reset {
+		t.Errorf("unexpected rate limit: %+v", rate)
+	}
+}
+
+func TestClientDo_GiteaAPI(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token test_token" {
+			t.Errorf("expected Authorization header, got %q", r.Header.Get("Authorization"))
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	_, err := NewAPIClient(GiteaAPI).Do(server.URL, "test_token", nil)
+	if !isNotFound(err) || err.Error() != "Gitea API returned status: 404" {
+		t.Errorf("expected Gitea status error, got: %v", err)
+	}
+}
@@ -1,29 +1,78 @@
 package fetcher
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"gmonitor/internal/models"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 )
 
-type HTTPFetcher func(url, token string) (*http.Response, error)
+// HTTPFetcher sends a GET request with the given extra headers, the response headers are available on the result
+type HTTPFetcher func(url, token string, header http.Header) (*http.Response, error)
+
+// commitsPerPage is the largest page size accepted by the GitHub commits API
+const commitsPerPage = 100
+
+// DefaultGitHubAPIURL is the API base URL of github.com
+const DefaultGitHubAPIURL = "https://api.github.com"
 
 type GitHubFetcher struct {
+	// BaseURL is the API root, e.g. https://ghe.example.com/api/v3, it defaults to DefaultGitHubAPIURL
+	BaseURL string
 	Request HTTPFetcher
+	// Client tracks the rate limit budget of Request, it is nil when Request is stubbed
+	Client *Client
+	// MaxPages caps the number of commit pages fetched per call, 0 means no limit
+	MaxPages int
+	// Validators remembers ETag/Last-Modified values for conditional requests, nil disables them
+	Validators ValidatorStore
 }
 
 func NewGitHubFetcher() *GitHubFetcher {
-	return &GitHubFetcher{Request: makeGitHubRequest}
+	return &GitHubFetcher{BaseURL: DefaultGitHubAPIURL, Request: makeGitHubRequest, Client: defaultClient}
+}
+
+// NewGitHubEnterpriseFetcher creates a fetcher for a GitHub Enterprise Server instance with its own rate limit budget
+func NewGitHubEnterpriseFetcher(baseURL string) *GitHubFetcher {
+	client := NewClient()
+	return &GitHubFetcher{BaseURL: baseURL, Request: client.Do, Client: client}
+}
+
+// apiURL returns the API base URL the fetcher talks to
+func (f *GitHubFetcher) apiURL() string {
+	if f.BaseURL == "" {
+		return DefaultGitHubAPIURL
+	}
+	return strings.TrimSuffix(f.BaseURL, "/")
+}
+
+// RateLimit returns the last known GitHub rate limit budget
+func (f *GitHubFetcher) RateLimit() RateLimit {
+	if f.Client == nil {
+		return RateLimit{}
+	}
+	return f.Client.RateLimit()
+}
+
+// PausedUntil returns the time until which GitHub requests are held back by a rate limit
+func (f *GitHubFetcher) PausedUntil() time.Time {
+	if f.Client == nil {
+		return time.Time{}
+	}
+	return f.Client.PausedUntil()
 }
 
 func (f *GitHubFetcher) FetchRepository(repoName, token string) (*models.Repository, error) {
-	url := fmt.Sprintf("https://api.github.com/repos/%s", repoName)
+	url := fmt.Sprintf("%s/repos/%s", f.apiURL(), repoName)
 
-	resp, err := f.Request(url, token)
+	resp, err := f.conditional()(url, token, nil)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching repository: %v", err)
 	}
@@ -43,49 +92,291 @@ func (f *GitHubFetcher) FetchRepository(repoName, token string) (*models.Reposit
 		StarsCount:      repo.StargazersCount,
 		OpenIssuesCount: repo.OpenIssuesCount,
 		WatchersCount:   repo.WatchersCount,
+		DefaultBranch:   repo.DefaultBranch,
 		CreatedAt:       repo.CreatedAt,
 		UpdatedAt:       repo.UpdatedAt,
 	}, nil
 }
 
-func (f *GitHubFetcher) FetchCommits(repoName, token, since string) ([]models.Commit, error) {
-	until, err := time.Parse(time.RFC3339, since)
-	if err != nil {
+// FetchCommits retrieves every commit of the default branch between since and until, following the pagination links.
+// An empty until leaves the range open-ended.
+func (f *GitHubFetcher) FetchCommits(repoName, token, since, until string) ([]models.Commit, error) {
+	return f.FetchBranchCommits(repoName, token, "", since, until)
+}
+
+// FetchBranches retrieves the names of every branch of a repository
+func (f *GitHubFetcher) FetchBranches(repoName, token string) ([]string, error) {
+	next := fmt.Sprintf("%s/repos/%s/branches?per_page=%d", f.apiURL(), repoName, commitsPerPage)
+
+	names := make([]string, 0)
+	for next != "" {
+		var branches []RefResponse
+		link, err := fetchJSON(f.conditional(), next, token, &branches)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching branches: %v", err)
+		}
+		for _, branch := range branches {
+			names = append(names, branch.Name)
+		}
+		next = nextPageURL(link)
+	}
+
+	return names, nil
+}
+
+// FetchBranchHead retrieves the SHA a branch currently points to
+func (f *GitHubFetcher) FetchBranchHead(repoName, token, branch string) (string, error) {
+	var head RefResponse
+	if _, err := fetchJSON(f.conditional(), fmt.Sprintf("%s/repos/%s/branches/%s", f.apiURL(), repoName, branch), token, &head); err != nil {
+		return "", fmt.Errorf("error fetching branch: %v", err)
+	}
+	return head.SHA(), nil
+}
+
+// FetchUnreachable compares the new head against the old one, whose extra commits are no longer reachable
+func (f *GitHubFetcher) FetchUnreachable(repoName, token, oldHead, newHead string) ([]string, error) {
+	next := fmt.Sprintf("%s/repos/%s/compare/%s...%s?per_page=%d", f.apiURL(), repoName, newHead, oldHead, commitsPerPage)
+
+	lost := make([]string, 0)
+	for next != "" {
+		var comparison GitHubCompareResponse
+		link, err := fetchJSON(f.Request, next, token, &comparison)
+		if isNotFound(err) {
+			return []string{oldHead}, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error comparing commits: %v", err)
+		}
+		for _, commit := range comparison.Commits {
+			lost = append(lost, commit.SHA)
+		}
+		next = nextPageURL(link)
+	}
+
+	return lost, nil
+}
+
+// FetchPullRequests retrieves at most limit of the pull requests updated since the given time, least recently updated first
+func (f *GitHubFetcher) FetchPullRequests(repoName, token string, since time.Time, limit int) ([]models.PullRequest, error) {
+	query := fmt.Sprintf("state=all&sort=updated&direction=desc&per_page=%d", commitsPerPage)
+	return fetchGitHubPullRequests(f.conditional(), fmt.Sprintf("%s/repos/%s/pulls", f.apiURL(), repoName), query, token, since, limit)
+}
+
+// FetchIssues retrieves the issues updated since the given time, pull requests listed along with them are skipped
+func (f *GitHubFetcher) FetchIssues(repoName, token string, since time.Time) ([]models.Issue, error) {
+	issuesURL := fmt.Sprintf("%s/repos/%s/issues?state=all&per_page=%d", f.apiURL(), repoName, commitsPerPage)
+	if !since.IsZero() {
+		issuesURL += "&since=" + url.QueryEscape(since.UTC().Format(time.RFC3339))
+	}
+	return fetchGitHubIssues(f.conditional(), issuesURL, token)
+}
+
+// FetchTags retrieves every tag of a repository along with the commit it points to
+func (f *GitHubFetcher) FetchTags(repoName, token string) ([]models.Tag, error) {
+	return fetchTags(f.conditional(), fmt.Sprintf("%s/repos/%s/tags?per_page=%d", f.apiURL(), repoName, commitsPerPage), token)
+}
+
+// FetchTagTarget retrieves the commit a tag points to, annotated tags are peeled to their commit
+func (f *GitHubFetcher) FetchTagTarget(repoName, token, tag string) (string, error) {
+	var commit GitHubCommitDetailResponse
+	if _, err := fetchJSON(f.Request, fmt.Sprintf("%s/repos/%s/commits/%s", f.apiURL(), repoName, tag), token, &commit); err != nil {
+		return "", fmt.Errorf("error fetching tag: %v", err)
+	}
+	return commit.SHA, nil
+}
+
+// FetchReleases retrieves every release of a repository, drafts included when the token may see them
+func (f *GitHubFetcher) FetchReleases(repoName, token string) ([]models.Release, error) {
+	return fetchGitHubReleases(f.conditional(), fmt.Sprintf("%s/repos/%s/releases?per_page=%d", f.apiURL(), repoName, commitsPerPage), token)
+}
+
+// FetchBranchCommits retrieves every commit of a branch between since and until, following the pagination links.
+// An empty branch reads the default branch, an empty until leaves the range open-ended.
+func (f *GitHubFetcher) FetchBranchCommits(repoName, token, branch, since, until string) ([]models.Commit, error) {
+	if _, err := time.Parse(time.RFC3339, since); err != nil {
 		log.Printf("Failed to parse time: %v", err)
 	}
-	url := fmt.Sprintf("https://api.github.com/repos/%s/commits?since=%s&until=%s&per_page=100", repoName, since, until.Add(time.Hour).Format(time.RFC3339))
 
-	resp, err := f.Request(url, token)
+	params := url.Values{}
+	if branch != "" {
+		params.Set("sha", branch)
+	}
+	params.Set("since", since)
+	if until != "" {
+		params.Set("until", until)
+	}
+	params.Set("per_page", strconv.Itoa(commitsPerPage))
+	next := fmt.Sprintf("%s/repos/%s/commits?%s", f.apiURL(), repoName, params.Encode())
+
+	commitRecords, err := readCommitPages(repoName, next, f.MaxPages, func(pageURL string) ([]models.Commit, string, error) {
+		commits, link, err := f.fetchCommitPage(pageURL, token)
+		if err != nil {
+			return nil, "", err
+		}
+		records := make([]models.Commit, 0, len(commits))
+		for _, commit := range commits {
+			records = append(records, commit.toCommit())
+		}
+		return records, link, nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("error fetching commits: %v", err)
+		return nil, err
+	}
+
+	if len(commitRecords) == 0 {
+		log.Printf("No new commits found for repository: %s since %s", repoName, since)
 	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
+
+	return commitRecords, nil
+}
+
+// FetchCommitFiles retrieves the files changed by a commit, following the pagination of very large commits
+func (f *GitHubFetcher) FetchCommitFiles(repoName, token, sha string) ([]models.CommitFile, error) {
+	next := fmt.Sprintf("%s/repos/%s/commits/%s", f.apiURL(), repoName, sha)
+
+	files := make([]models.CommitFile, 0)
+	for next != "" {
+		var detail GitHubCommitDetailResponse
+		link, err := fetchJSON(f.Request, next, token, &detail)
 		if err != nil {
-			log.Printf("Error closing response body: %v", err)
+			return nil, fmt.Errorf("error fetching commit: %v", err)
 		}
-	}(resp.Body)
+
+		for _, file := range detail.Files {
+			files = append(files, models.CommitFile{
+				Filename:         file.Filename,
+				PreviousFilename: file.PreviousFilename,
+				Status:           file.Status,
+				Additions:        file.Additions,
+				Deletions:        file.Deletions,
+			})
+		}
+		next = nextPageURL(link)
+	}
+
+	return files, nil
+}
+
+// toCommit converts a GitHub API commit into a commit record
+func (c *GitHubCommitResponse) toCommit() models.Commit {
+	commit := models.Commit{
+		CommitHash:         c.SHA,
+		Author:             c.Commit.Author.Name,
+		AuthorEmail:        c.Commit.Author.Email,
+		Message:            c.Commit.Message,
+		CommitURL:          c.HTMLURL,
+		CommitDate:         c.Commit.Author.Date,
+		CommitterName:      c.Commit.Committer.Name,
+		CommitterEmail:     c.Commit.Committer.Email,
+		CommitterDate:      c.Commit.Committer.Date,
+		TreeSHA:            c.Commit.Tree.SHA,
+		Verified:           c.Commit.Verification.Verified,
+		VerificationReason: c.Commit.Verification.Reason,
+	}
+	if c.Author != nil {
+		commit.AuthorLogin = c.Author.Login
+	}
+	for _, parent := range c.Parents {
+		commit.Parents = append(commit.Parents, parent.SHA)
+	}
+	return commit
+}
+
+// fetchCommitPage retrieves a single page of commits along with its Link header
+func (f *GitHubFetcher) fetchCommitPage(url, token string) ([]GitHubCommitResponse, string, error) {
+	resp, err := f.conditional()(url, token, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("error fetching commits: %v", err)
+	}
+	defer closeBody(resp.Body)
 
 	var commits []GitHubCommitResponse
 	if err := json.NewDecoder(resp.Body).Decode(&commits); err != nil {
-		return nil, fmt.Errorf("error decoding commits JSON: %v", err)
+		return nil, "", fmt.Errorf("error decoding commits JSON: %v", err)
 	}
+	return commits, resp.Header.Get("Link"), nil
+}
 
-	if len(commits) == 0 {
-		log.Printf("No new commits found for repository: %s since %s", repoName, since)
-		return []models.Commit{}, nil
+// conditional wraps Request with conditional requests: responses carrying validators are remembered per resource and
+// replayed when GitHub answers 304 Not Modified, which does not count against the rate limit. Replaying the response
+// rather than reporting it unchanged keeps callers unaware, and a poll whose results failed to save gets them again.
+func (f *GitHubFetcher) conditional() HTTPFetcher {
+	if f.Validators == nil {
+		return f.Request
 	}
 
-	commitRecords := make([]models.Commit, 0, len(commits))
-	for _, commit := range commits {
-		commitRecords = append(commitRecords, models.Commit{
-			CommitHash: commit.SHA,
-			Author:     commit.Commit.Author.Name,
-			Message:    commit.Commit.Message,
-			CommitURL:  commit.HTMLURL,
-			CommitDate: commit.Commit.Author.Date,
-		})
+	return func(url, token string, header http.Header) (*http.Response, error) {
+		key := resourceKey(url)
+		stored, found := f.Validators.Get(key)
+		sameURL := found && stored.URL == url
+
+		conditionalHeader := header.Clone()
+		if conditionalHeader == nil {
+			conditionalHeader = http.Header{}
+		}
+		if found && stored.ETag != "" && (sameURL || stored.Link == "") {
+			conditionalHeader.Set("If-None-Match", stored.ETag)
+		}
+		if sameURL && stored.LastModified != "" {
+			conditionalHeader.Set("If-Modified-Since", stored.LastModified)
+		}
+		conditionalSent := conditionalHeader.Get("If-None-Match") != "" || conditionalHeader.Get("If-Modified-Since") != ""
+
+		resp, err := f.Request(url, token, conditionalHeader)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode == http.StatusNotModified {
+			closeBody(resp.Body)
+			if !conditionalSent {
+				return nil, &StatusError{API: GitHubAPI.Name, StatusCode: resp.StatusCode}
+			}
+			replayed := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(bytes.NewReader(stored.Body))}
+			if stored.Link != "" {
+				replayed.Header.Set("Link", stored.Link)
+			}
+			return replayed, nil
+		}
+
+		validators := Validators{URL: url, ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}
+		if validators.ETag == "" && validators.LastModified == "" {
+			return resp, nil
+		}
+		body, err := io.ReadAll(resp.Body)
+		closeBody(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("error reading response: %v", err)
+		}
+		if len(body) <= maxValidatedBody {
+			validators.Link = resp.Header.Get("Link")
+			validators.Body = body
+			f.Validators.Set(key, validators)
+		}
+
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		return resp, nil
 	}
+}
 
-	return commitRecords, nil
+// nextPageURL extracts the rel="next" target from a GitHub Link header
+func nextPageURL(link string) string {
+	return linkURL(link, "next")
+}
+
+// linkURL extracts the target of the given relation from a GitHub Link header
+func linkURL(link, rel string) string {
+	for _, part := range strings.Split(link, ",") {
+		segments := strings.Split(part, ";")
+		if len(segments) < 2 {
+			continue
+		}
+		target := strings.Trim(strings.TrimSpace(segments[0]), "<>")
+		for _, attr := range segments[1:] {
+			if strings.TrimSpace(attr) == `rel="`+rel+`"` {
+				return target
+			}
+		}
+	}
+	return ""
 }
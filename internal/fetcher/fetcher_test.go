@@ -2,12 +2,14 @@ package fetcher
 
 import (
 	"errors"
+	"fmt"
 	_ "gmonitor/internal/models"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
-	_ "time"
+	"time"
 )
 
 func mockResponse(statusCode int, body string) *http.Response {
@@ -19,7 +21,7 @@ func mockResponse(statusCode int, body string) *http.Response {
 
 func TestFetchRepository_Success(t *testing.T) {
 	mockFetcher := &GitHubFetcher{
-		Request: func(url, token string) (*http.Response, error) {
+		Request: func(url, token string, header http.Header) (*http.Response, error) {
 			body := `{
 				"full_name": "chromium/chromium",
 				"description": "desc",
@@ -47,7 +49,7 @@ func TestFetchRepository_Success(t *testing.T) {
 
 func TestFetchRepository_JSONError(t *testing.T) {
 	mockFetcher := &GitHubFetcher{
-		Request: func(url, token string) (*http.Response, error) {
+		Request: func(url, token string, header http.Header) (*http.Response, error) {
 			return mockResponse(200, `{invalid json}`), nil
 		},
 	}
@@ -60,7 +62,7 @@ func TestFetchRepository_JSONError(t *testing.T) {
 
 func TestFetchRepository_RequestError(t *testing.T) {
 	mockFetcher := &GitHubFetcher{
-		Request: func(url, token string) (*http.Response, error) {
+		Request: func(url, token string, header http.Header) (*http.Response, error) {
 			return nil, errors.New("network error")
 		},
 	}
@@ -73,7 +75,7 @@ func TestFetchRepository_RequestError(t *testing.T) {
 
 func TestFetchCommits_Success(t *testing.T) {
 	mockFetcher := &GitHubFetcher{
-		Request: func(url, token string) (*http.Response, error) {
+		Request: func(url, token string, header http.Header) (*http.Response, error) {
 			body := `[
 				{
 					"sha": "abc123",
@@ -89,7 +91,7 @@ func TestFetchCommits_Success(t *testing.T) {
 	}
 
 	since := "2023-01-01T00:00:00Z"
-	commits, err := mockFetcher.FetchCommits("chromium/chromium", "", since)
+	commits, err := mockFetcher.FetchCommits("chromium/chromium", "", since, "")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -98,14 +100,57 @@ func TestFetchCommits_Success(t *testing.T) {
 	}
 }
 
+func TestFetchCommits_FullMetadata(t *testing.T) {
+	mockFetcher := &GitHubFetcher{
+		Request: func(url, token string, header http.Header) (*http.Response, error) {
+			body := `[
+				{
+					"sha": "merge1",
+					"commit": {
+						"author": { "name": "dev", "email": "dev@example.com", "date": "2023-01-01T12:00:00Z" },
+						"committer": { "name": "GitHub", "email": "noreply@github.com", "date": "2023-01-01T12:05:00Z" },
+						"message": "Merge pull request #1",
+						"tree": { "sha": "tree1" },
+						"verification": { "verified": true, "reason": "valid" }
+					},
+					"author": { "login": "dev-login" },
+					"parents": [ { "sha": "p1" }, { "sha": "p2" } ]
+				},
+				{
+					"sha": "plain1",
+					"commit": { "author": { "name": "anon", "date": "2023-01-01T11:00:00Z" }, "message": "fix" },
+					"author": null,
+					"parents": [ { "sha": "p1" } ]
+				}
+			]`
+			return mockResponse(200, body), nil
+		},
+	}
+
+	commits, err := mockFetcher.FetchCommits("chromium/chromium", "", "2023-01-01T00:00:00Z", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	merge := commits[0]
+	if merge.AuthorEmail != "dev@example.com" || merge.AuthorLogin != "dev-login" || merge.CommitterName != "GitHub" {
+		t.Errorf("unexpected author/committer: %+v", merge)
+	}
+	if !merge.IsMerge() || merge.TreeSHA != "tree1" || !merge.Verified || merge.VerificationReason != "valid" {
+		t.Errorf("unexpected merge commit metadata: %+v", merge)
+	}
+	if commits[1].IsMerge() || commits[1].AuthorLogin != "" {
+		t.Errorf("unexpected plain commit metadata: %+v", commits[1])
+	}
+}
+
 func TestFetchCommits_Empty(t *testing.T) {
 	mockFetcher := &GitHubFetcher{
-		Request: func(url, token string) (*http.Response, error) {
+		Request: func(url, token string, header http.Header) (*http.Response, error) {
 			return mockResponse(200, `[]`), nil
 		},
 	}
 
-	commits, err := mockFetcher.FetchCommits("chromium/chromium", "", "2023-01-01T00:00:00Z")
+	commits, err := mockFetcher.FetchCommits("chromium/chromium", "", "2023-01-01T00:00:00Z", "")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -116,12 +161,12 @@ func TestFetchCommits_Empty(t *testing.T) {
 
 func TestFetchCommits_JSONError(t *testing.T) {
 	mockFetcher := &GitHubFetcher{
-		Request: func(url, token string) (*http.Response, error) {
+		Request: func(url, token string, header http.Header) (*http.Response, error) {
 			return mockResponse(200, `invalid-json`), nil
 		},
 	}
 
-	_, err := mockFetcher.FetchCommits("chromium/chromium", "", "2023-01-01T00:00:00Z")
+	_, err := mockFetcher.FetchCommits("chromium/chromium", "", "2023-01-01T00:00:00Z", "")
 	if err == nil || !strings.Contains(err.Error(), "error decoding commits JSON") {
 		t.Errorf("expected decoding error, got: %v", err)
 	}
@@ -129,12 +174,12 @@ func TestFetchCommits_JSONError(t *testing.T) {
 
 func TestFetchCommits_RequestError(t *testing.T) {
 	mockFetcher := &GitHubFetcher{
-		Request: func(url, token string) (*http.Response, error) {
+		Request: func(url, token string, header http.Header) (*http.Response, error) {
 			return nil, errors.New("mock failure")
 		},
 	}
 
-	_, err := mockFetcher.FetchCommits("chromium/chromium", "", "2023-01-01T00:00:00Z")
+	_, err := mockFetcher.FetchCommits("chromium/chromium", "", "2023-01-01T00:00:00Z", "")
 	if err == nil || !strings.Contains(err.Error(), "error fetching commits") {
 		t.Errorf("expected fetch error, got: %v", err)
 	}
@@ -142,14 +187,563 @@ func TestFetchCommits_RequestError(t *testing.T) {
 
 func TestFetchCommits_InvalidTime(t *testing.T) {
 	mockFetcher := &GitHubFetcher{
-		Request: func(url, token string) (*http.Response, error) {
+		Request: func(url, token string, header http.Header) (*http.Response, error) {
 			return mockResponse(200, `[]`), nil
 		},
 	}
 
 	// Should not error, just log internally
-	_, err := mockFetcher.FetchCommits("chromium/chromium", "", "invalid-time")
+	_, err := mockFetcher.FetchCommits("chromium/chromium", "", "invalid-time", "")
 	if err != nil {
 		t.Errorf("expected no hard error, got: %v", err)
 	}
 }
+
+func TestFetchCommits_FollowsPagination(t *testing.T) {
+	var requested []string
+	mockFetcher := &GitHubFetcher{
+		Request: func(url, token string, header http.Header) (*http.Response, error) {
+			requested = append(requested, url)
+			if len(requested) == 1 {
+				resp := mockResponse(200, `[{"sha": "page1"}]`)
+				resp.Header = http.Header{}
+				resp.Header.Set("Link", `<https://api.github.com/repositories/1/commits?page=2>; rel="next", <https://api.github.com/repositories/1/commits?page=2>; rel="last"`)
+				return resp, nil
+			}
+			return mockResponse(200, `[{"sha": "page2"}]`), nil
+		},
+	}
+
+	commits, err := mockFetcher.FetchCommits("chromium/chromium", "", "2023-01-01T00:00:00Z", "2023-02-01T00:00:00Z")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(commits) != 2 || commits[1].CommitHash != "page2" {
+		t.Errorf("unexpected commits: %+v", commits)
+	}
+	if len(requested) != 2 || requested[1] != "https://api.github.com/repositories/1/commits?page=2" {
+		t.Errorf("unexpected requests: %v", requested)
+	}
+	if !strings.Contains(requested[0], "until=2023-02-01") {
+		t.Errorf("expected until bound in first request, got: %s", requested[0])
+	}
+}
+
+// pagedCommits serves a listing of three pages of one commit each, newest first, optionally without a last link
+func pagedCommits(requested *[]string, withLast bool) HTTPFetcher {
+	return func(rawURL, token string, header http.Header) (*http.Response, error) {
+		*requested = append(*requested, rawURL)
+		page := pageNumber(rawURL)
+		if page == 0 {
+			page = 1
+		}
+
+		links := make([]string, 0, 3)
+		if page > 1 {
+			links = append(links, fmt.Sprintf(`<https://api.github.com/commits?page=%d>; rel="prev"`, page-1))
+		}
+		if page < 3 {
+			links = append(links, fmt.Sprintf(`<https://api.github.com/commits?page=%d>; rel="next"`, page+1))
+		}
+		if withLast {
+			links = append(links, `<https://api.github.com/commits?page=3>; rel="last"`)
+		}
+		resp := mockResponse(200, fmt.Sprintf(`[{"sha": "page%d"}]`, page))
+		resp.Header = http.Header{"Link": []string{strings.Join(links, ", ")}}
+		return resp, nil
+	}
+}
+
+func TestFetchCommits_MaxPagesKeepsOldestPages(t *testing.T) {
+	var requested []string
+	mockFetcher := &GitHubFetcher{MaxPages: 2, Request: pagedCommits(&requested, true)}
+
+	commits, err := mockFetcher.FetchCommits("chromium/chromium", "", "2023-01-01T00:00:00Z", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(commits) != 2 || commits[0].CommitHash != "page2" || commits[1].CommitHash != "page3" {
+		t.Errorf("expected the two oldest pages, newest first, got: %+v", commits)
+	}
+	if len(requested) != 3 {
+		t.Errorf("expected the first page and the two oldest ones to be requested, got: %v", requested)
+	}
+}
+
+func TestFetchCommits_MaxPagesWithoutLastLink(t *testing.T) {
+	var requested []string
+	mockFetcher := &GitHubFetcher{MaxPages: 1, Request: pagedCommits(&requested, false)}
+
+	commits, err := mockFetcher.FetchCommits("chromium/chromium", "", "2023-01-01T00:00:00Z", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(commits) != 3 {
+		t.Errorf("expected every page to be read without a last link, got: %+v", commits)
+	}
+}
+
+func TestNextPageURL(t *testing.T) {
+	link := `<https://api.github.com/x?page=1>; rel="prev", <https://api.github.com/x?page=3>; rel="next"`
+	if got := nextPageURL(link); got != "https://api.github.com/x?page=3" {
+		t.Errorf("unexpected next page: %s", got)
+	}
+	if got := nextPageURL(`<https://api.github.com/x?page=1>; rel="prev"`); got != "" {
+		t.Errorf("expected no next page, got: %s", got)
+	}
+}
+
+func TestFetchCommits_ConditionalRequest(t *testing.T) {
+	var sentETag string
+	calls := 0
+	mockFetcher := &GitHubFetcher{
+		Validators: NewMemoryValidatorStore(),
+		Request: func(url, token string, header http.Header) (*http.Response, error) {
+			calls++
+			sentETag = header.Get("If-None-Match")
+			if sentETag == `"abc"` {
+				return mockResponse(http.StatusNotModified, ""), nil
+			}
+			resp := mockResponse(200, `[]`)
+			resp.Header = http.Header{"Etag": []string{`"abc"`}}
+			return resp, nil
+		},
+	}
+
+	since := "2023-01-01T00:00:00Z"
+	if _, err := mockFetcher.FetchCommits("chromium/chromium", "", since, ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sentETag != "" {
+		t.Errorf("expected no validator on the first request, got %q", sentETag)
+	}
+
+	commits, err := mockFetcher.FetchCommits("chromium/chromium", "", since, "")
+	if err != nil {
+		t.Fatalf("unexpected error on not modified response: %v", err)
+	}
+	if sentETag != `"abc"` {
+		t.Errorf("expected If-None-Match to be sent, got %q", sentETag)
+	}
+	if len(commits) != 0 || calls != 2 {
+		t.Errorf("expected no commits from 2 calls, got %d commits from %d calls", len(commits), calls)
+	}
+}
+
+func TestFetchCommits_ReplaysNotModifiedPages(t *testing.T) {
+	var sentETag string
+	mockFetcher := &GitHubFetcher{
+		Validators: NewMemoryValidatorStore(),
+		Request: func(url, token string, header http.Header) (*http.Response, error) {
+			sentETag = header.Get("If-None-Match")
+			if sentETag == `"abc"` {
+				return mockResponse(http.StatusNotModified, ""), nil
+			}
+			resp := mockResponse(200, `[{"sha": "abc123"}]`)
+			resp.Header = http.Header{"Etag": []string{`"abc"`}}
+			return resp, nil
+		},
+	}
+
+	if _, err := mockFetcher.FetchCommits("chromium/chromium", "", "2023-01-01T00:00:00Z", ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The validators of the resource are sent whatever cursor it is requested from
+	commits, err := mockFetcher.FetchCommits("chromium/chromium", "", "2023-01-02T00:00:00Z", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sentETag != `"abc"` {
+		t.Errorf("expected If-None-Match to be sent for a moved cursor, got %q", sentETag)
+	}
+	if len(commits) != 1 || commits[0].CommitHash != "abc123" {
+		t.Errorf("expected the stored page to be replayed, got: %+v", commits)
+	}
+}
+
+func TestFetchCommits_MovedCursorSendsOnlyPageETag(t *testing.T) {
+	var sent http.Header
+	link := ""
+	mockFetcher := &GitHubFetcher{
+		Validators: NewMemoryValidatorStore(),
+		Request: func(url, token string, header http.Header) (*http.Response, error) {
+			sent = header
+			resp := mockResponse(200, `[]`)
+			resp.Header = http.Header{"Etag": []string{`"abc"`}, "Last-Modified": []string{"Sun, 01 Jan 2023 00:00:00 GMT"}}
+			if link != "" {
+				resp.Header.Set("Link", link)
+			}
+			return resp, nil
+		},
+	}
+
+	tests := []struct {
+		name         string
+		link         string
+		expectedETag string
+	}{
+		{"single page", "", `"abc"`},
+		{"paginated", `<https://api.github.com/x?page=2>; rel="next"`, ""},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			link = tt.link
+			repo := fmt.Sprintf("chromium/chromium%d", i)
+			if _, _, err := mockFetcher.fetchCommitPage(fmt.Sprintf("https://api.github.com/repos/%s/commits?since=a", repo), ""); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if _, _, err := mockFetcher.fetchCommitPage(fmt.Sprintf("https://api.github.com/repos/%s/commits?since=b", repo), ""); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if sent.Get("If-None-Match") != tt.expectedETag {
+				t.Errorf("expected If-None-Match %q, got %q", tt.expectedETag, sent.Get("If-None-Match"))
+			}
+			if sent.Get("If-Modified-Since") != "" {
+				t.Errorf("expected no If-Modified-Since for another cursor, got %q", sent.Get("If-Modified-Since"))
+			}
+		})
+	}
+}
+
+func TestGitHubFetcher_ConditionalRequests(t *testing.T) {
+	calls := make(map[string]int)
+	modified := make(map[string]int)
+	bodies := map[string]string{
+		"/repos/chromium/chromium":          `{"full_name": "chromium/chromium", "stargazers_count": 7}`,
+		"/repos/chromium/chromium/branches": `[{"name": "main"}]`,
+		"/repos/chromium/chromium/tags":     `[{"name": "v1", "commit": {"sha": "abc"}}]`,
+		"/repos/chromium/chromium/releases": `[{"tag_name": "v1"}]`,
+		"/repos/chromium/chromium/issues":   `[{"number": 1, "title": "bug", "updated_at": "2023-01-02T00:00:00Z"}]`,
+	}
+	mockFetcher := &GitHubFetcher{
+		Validators: NewMemoryValidatorStore(),
+		Request: func(rawURL, token string, header http.Header) (*http.Response, error) {
+			path := strings.TrimPrefix(strings.SplitN(rawURL, "?", 2)[0], DefaultGitHubAPIURL)
+			calls[path]++
+			if header.Get("If-None-Match") == `"`+path+`"` {
+				return mockResponse(http.StatusNotModified, ""), nil
+			}
+			modified[path]++
+			resp := mockResponse(200, bodies[path])
+			resp.Header = http.Header{"Etag": []string{`"` + path + `"`}}
+			return resp, nil
+		},
+	}
+
+	for round := 0; round < 2; round++ {
+		repo, err := mockFetcher.FetchRepository("chromium/chromium", "")
+		if err != nil || repo.StarsCount != 7 {
+			t.Fatalf("unexpected repository: %+v (%v)", repo, err)
+		}
+		if branches, err := mockFetcher.FetchBranches("chromium/chromium", ""); err != nil || len(branches) != 1 {
+			t.Fatalf("unexpected branches: %v (%v)", branches, err)
+		}
+		if tags, err := mockFetcher.FetchTags("chromium/chromium", ""); err != nil || len(tags) != 1 {
+			t.Fatalf("unexpected tags: %v (%v)", tags, err)
+		}
+		if releases, err := mockFetcher.FetchReleases("chromium/chromium", ""); err != nil || len(releases) != 1 {
+			t.Fatalf("unexpected releases: %v (%v)", releases, err)
+		}
+		since := time.Date(2023, 1, round+1, 0, 0, 0, 0, time.UTC)
+		if issues, err := mockFetcher.FetchIssues("chromium/chromium", "", since); err != nil || len(issues) != 1 {
+			t.Fatalf("unexpected issues: %v (%v)", issues, err)
+		}
+	}
+
+	for path := range bodies {
+		if calls[path] != 2 || modified[path] != 1 {
+			t.Errorf("expected %s to be requested conditionally the second time, got %d calls and %d full responses", path, calls[path], modified[path])
+		}
+	}
+}
+
+func TestGitHubEnterpriseFetcher_UsesBaseURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/repos/team/service":
+			_, _ = w.Write([]byte(`{"full_name": "team/service", "html_url": "https://ghe.example.com/team/service"}`))
+		case "/api/v3/repos/team/service/commits":
+			if r.URL.Query().Get("since") != "2023-01-01T00:00:00Z" {
+				t.Errorf("unexpected since: %s", r.URL.Query().Get("since"))
+			}
+			_, _ = w.Write([]byte(`[{"sha": "abc123", "commit": {"author": {"name": "dev", "date": "2023-01-01T12:00:00Z"}, "message": "fix"}}]`))
+		default:
+			t.Errorf("unexpected path: %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	ghe := NewGitHubEnterpriseFetcher(server.URL + "/api/v3")
+
+	repo, err := ghe.FetchRepository("team/service", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if repo.Name != "team/service" {
+		t.Errorf("unexpected repository: %+v", repo)
+	}
+
+	commits, err := ghe.FetchCommits("team/service", "", "2023-01-01T00:00:00Z", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(commits) != 1 || commits[0].CommitHash != "abc123" {
+		t.Errorf("unexpected commits: %+v", commits)
+	}
+}
+
+func TestFetchCommitFiles_Success(t *testing.T) {
+	calls := 0
+	mockFetcher := &GitHubFetcher{
+		Request: func(url, token string, header http.Header) (*http.Response, error) {
+			calls++
+			if calls == 1 {
+				if url != "https://api.github.com/repos/chromium/chromium/commits/abc123" {
+					t.Errorf("unexpected url: %s", url)
+				}
+				resp := mockResponse(200, `{"sha": "abc123", "files": [
+					{"filename": "src/main.go", "status": "modified", "additions": 3, "deletions": 1},
+					{"filename": "docs/new.md", "previous_filename": "docs/old.md", "status": "renamed"}
+				]}`)
+				resp.Header = http.Header{"Link": []string{`<https://api.github.com/next>; rel="next"`}}
+				return resp, nil
+			}
+			return mockResponse(200, `{"sha": "abc123", "files": [{"filename": "README.md", "status": "added", "additions": 10}]}`), nil
+		},
+	}
+
+	files, err := mockFetcher.FetchCommitFiles("chromium/chromium", "", "abc123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(files) != 3 || files[0].Additions != 3 || files[1].PreviousFilename != "docs/old.md" || files[2].Status != "added" {
+		t.Errorf("unexpected files: %+v", files)
+	}
+}
+
+func TestFetchBranchCommits_PassesBranch(t *testing.T) {
+	mockFetcher := &GitHubFetcher{
+		Request: func(url, token string, header http.Header) (*http.Response, error) {
+			if !strings.Contains(url, "sha=release%2F1.0") {
+				t.Errorf("expected the branch in the url: %s", url)
+			}
+			return mockResponse(200, `[{"sha": "abc123"}]`), nil
+		},
+	}
+
+	commits, err := mockFetcher.FetchBranchCommits("chromium/chromium", "", "release/1.0", "2023-01-01T00:00:00Z", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(commits) != 1 {
+		t.Errorf("expected 1 commit, got %d", len(commits))
+	}
+}
+
+func TestFetchBranches_FollowsPagination(t *testing.T) {
+	calls := 0
+	mockFetcher := &GitHubFetcher{
+		Request: func(url, token string, header http.Header) (*http.Response, error) {
+			calls++
+			if calls == 1 {
+				resp := mockResponse(200, `[{"name": "main"}, {"name": "release/1.0"}]`)
+				resp.Header = http.Header{"Link": []string{`<https://api.github.com/next>; rel="next"`}}
+				return resp, nil
+			}
+			return mockResponse(200, `[{"name": "release/1.1"}]`), nil
+		},
+	}
+
+	branches, err := mockFetcher.FetchBranches("chromium/chromium", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(branches) != 3 || branches[2] != "release/1.1" {
+		t.Errorf("unexpected branches: %v", branches)
+	}
+}
+
+func TestFetchUnreachable_ComparesNewHeadToOldHead(t *testing.T) {
+	mockFetcher := &GitHubFetcher{
+		Request: func(url, token string, header http.Header) (*http.Response, error) {
+			if !strings.Contains(url, "/repos/chromium/chromium/compare/new123...old456") {
+				t.Errorf("unexpected url: %s", url)
+			}
+			return mockResponse(200, `{"status": "ahead", "commits": [{"sha": "lost1"}, {"sha": "old456"}]}`), nil
+		},
+	}
+
+	lost, err := mockFetcher.FetchUnreachable("chromium/chromium", "", "old456", "new123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(lost) != 2 || lost[1] != "old456" {
+		t.Errorf("unexpected lost commits: %v", lost)
+	}
+}
+
+func TestFetchUnreachable_UnknownOldHead(t *testing.T) {
+	mockFetcher := &GitHubFetcher{
+		Request: func(url, token string, header http.Header) (*http.Response, error) {
+			return nil, &StatusError{StatusCode: http.StatusNotFound}
+		},
+	}
+
+	lost, err := mockFetcher.FetchUnreachable("chromium/chromium", "", "old456", "new123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(lost) != 1 || lost[0] != "old456" {
+		t.Errorf("expected only the old head, got: %v", lost)
+	}
+}
+
+func TestFetchReleases_Success(t *testing.T) {
+	mockFetcher := &GitHubFetcher{
+		Request: func(url, token string, header http.Header) (*http.Response, error) {
+			if !strings.Contains(url, "/repos/chromium/chromium/releases?") {
+				t.Errorf("unexpected url: %s", url)
+			}
+			return mockResponse(200, `[
+				{"tag_name": "v2.0", "name": "Two", "body": "notes", "prerelease": true, "published_at": "2023-01-01T12:00:00Z"},
+				{"tag_name": "v3.0", "draft": true, "published_at": null}
+			]`), nil
+		},
+	}
+
+	releases, err := mockFetcher.FetchReleases("chromium/chromium", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(releases) != 2 || releases[0].Notes != "notes" || !releases[0].Prerelease || releases[0].PublishedAt == nil {
+		t.Errorf("unexpected first release: %+v", releases)
+	}
+	if !releases[1].Draft || releases[1].PublishedAt != nil {
+		t.Errorf("unexpected draft release: %+v", releases[1])
+	}
+}
+
+func TestFetchTags_Success(t *testing.T) {
+	mockFetcher := &GitHubFetcher{
+		Request: func(url, token string, header http.Header) (*http.Response, error) {
+			return mockResponse(200, `[{"name": "v2.0", "commit": {"sha": "abc123"}}]`), nil
+		},
+	}
+
+	tags, err := mockFetcher.FetchTags("chromium/chromium", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(tags) != 1 || tags[0].Name != "v2.0" || tags[0].TargetSHA != "abc123" {
+		t.Errorf("unexpected tags: %+v", tags)
+	}
+}
+
+func TestFetchPullRequests_StopsAtCursor(t *testing.T) {
+	mockFetcher := &GitHubFetcher{
+		Request: func(url, token string, header http.Header) (*http.Response, error) {
+			switch {
+			case strings.Contains(url, "/pulls?"):
+				return mockResponse(200, `[
+					{"number": 2, "updated_at": "2023-01-03T00:00:00Z"},
+					{"number": 1, "updated_at": "2022-12-01T00:00:00Z"}
+				]`), nil
+			case strings.HasSuffix(url, "/pulls/2"):
+				return mockResponse(200, `{
+					"number": 2, "title": "Fix", "state": "closed", "user": {"login": "dev"},
+					"base": {"ref": "main"}, "head": {"ref": "fix"}, "merge_commit_sha": "abc123",
+					"additions": 10, "deletions": 2,
+					"created_at": "2023-01-01T00:00:00Z", "updated_at": "2023-01-03T00:00:00Z",
+					"merged_at": "2023-01-03T00:00:00Z", "closed_at": "2023-01-03T00:00:00Z"
+				}`), nil
+			case strings.HasSuffix(url, "/pulls/2/reviews"):
+				return mockResponse(200, `[
+					{"state": "COMMENTED", "user": {"login": "dev"}, "submitted_at": "2023-01-01T01:00:00Z"},
+					{"state": "APPROVED", "user": {"login": "reviewer"}, "submitted_at": "2023-01-02T00:00:00Z"},
+					{"state": "PENDING", "user": {"login": "other"}}
+				]`), nil
+			}
+			t.Errorf("unexpected url: %s", url)
+			return mockResponse(404, `{}`), nil
+		},
+	}
+
+	pulls, err := mockFetcher.FetchPullRequests("chromium/chromium", "", time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(pulls) != 1 {
+		t.Fatalf("expected only the pull request updated since the cursor, got %+v", pulls)
+	}
+
+	pr := pulls[0]
+	if pr.State != "merged" || pr.MergeCommitSHA != "abc123" || pr.Additions != 10 || pr.BaseBranch != "main" {
+		t.Errorf("unexpected pull request: %+v", pr)
+	}
+	if pr.ReviewCount != 1 || pr.FirstReviewAt == nil || pr.FirstReviewAt.Day() != 2 {
+		t.Errorf("expected one review by someone other than the author, got %d at %v", pr.ReviewCount, pr.FirstReviewAt)
+	}
+}
+
+func TestFetchPullRequests_CompletesOldestBatch(t *testing.T) {
+	var completed []string
+	mockFetcher := &GitHubFetcher{
+		Request: func(url, token string, header http.Header) (*http.Response, error) {
+			if strings.Contains(url, "/pulls?") {
+				return mockResponse(200, `[
+					{"number": 4, "updated_at": "2023-01-04T00:00:00Z"},
+					{"number": 3, "updated_at": "2023-01-02T00:00:00Z"},
+					{"number": 2, "updated_at": "2023-01-02T00:00:00Z"},
+					{"number": 1, "updated_at": "2023-01-01T00:00:00Z"}
+				]`), nil
+			}
+			path := url[strings.Index(url, "/pulls/")+len("/pulls/"):]
+			if strings.HasSuffix(path, "/reviews") {
+				return mockResponse(200, `[]`), nil
+			}
+			completed = append(completed, path)
+			return mockResponse(200, `{"number": `+path+`, "state": "open"}`), nil
+		},
+	}
+
+	// A zero cursor lists every pull request, the one updated along with the last of the batch joins it
+	pulls, err := mockFetcher.FetchPullRequests("chromium/chromium", "", time.Time{}, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(pulls) != 3 || pulls[0].Number != 1 || pulls[2].Number != 3 {
+		t.Errorf("expected the three least recently updated pull requests, oldest first, got %+v", pulls)
+	}
+	if strings.Join(completed, ",") != "1,2,3" {
+		t.Errorf("expected only the batch to be completed, got: %v", completed)
+	}
+}
+
+func TestFetchIssues_SkipsPullRequests(t *testing.T) {
+	mockFetcher := &GitHubFetcher{
+		Request: func(url, token string, header http.Header) (*http.Response, error) {
+			if !strings.Contains(url, "/repos/chromium/chromium/issues?state=all") || !strings.Contains(url, "since=2023-01-01T00%3A00%3A00Z") {
+				t.Errorf("unexpected url: %s", url)
+			}
+			return mockResponse(200, `[
+				{"number": 1, "title": "Bug", "state": "closed", "user": {"login": "dev"},
+				 "labels": [{"name": "bug"}], "assignees": [{"login": "fixer"}],
+				 "created_at": "2023-01-01T00:00:00Z", "updated_at": "2023-01-02T00:00:00Z", "closed_at": "2023-01-02T00:00:00Z"},
+				{"number": 2, "state": "open", "pull_request": {"url": "https://api.github.com/pulls/2"}}
+			]`), nil
+		},
+	}
+
+	issues, err := mockFetcher.FetchIssues("chromium/chromium", "", time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(issues) != 1 {
+		t.Fatalf("expected the pull request to be skipped, got %+v", issues)
+	}
+	if issues[0].State != "closed" || issues[0].Labels[0] != "bug" || issues[0].Assignees[0] != "fixer" || issues[0].ClosedAt == nil {
+		t.Errorf("unexpected issue: %+v", issues[0])
+	}
+}
@@ -0,0 +1,171 @@
+package fetcher
+
+import (
+	"fmt"
+	"gmonitor/internal/models"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// giteaCommitsPerPage is the default maximum page size of a Gitea instance
+const giteaCommitsPerPage = 50
+
+// GiteaFetcher fetches repositories and commits from the Gitea v1 REST API
+type GiteaFetcher struct {
+	BaseURL string
+	Request HTTPFetcher
+	// Client tracks the rate limit budget of Request, it is nil when Request is stubbed
+	Client *Client
+	// MaxPages caps the number of commit pages fetched per call, 0 means no limit
+	MaxPages int
+}
+
+// NewGiteaFetcher creates a fetcher for the Gitea instance serving the given API base URL
+func NewGiteaFetcher(baseURL string) *GiteaFetcher {
+	client := NewAPIClient(GiteaAPI)
+	return &GiteaFetcher{BaseURL: baseURL, Request: client.Do, Client: client}
+}
+
+// PausedUntil returns the time until which requests to the instance are held back by a rate limit
+func (f *GiteaFetcher) PausedUntil() time.Time {
+	if f.Client == nil {
+		return time.Time{}
+	}
+	return f.Client.PausedUntil()
+}
+
+func (f *GiteaFetcher) FetchRepository(repoName, token string) (*models.Repository, error) {
+	repoURL := fmt.Sprintf("%s/repos/%s", f.BaseURL, repoName)
+
+	var repo GiteaRepositoryResponse
+	if _, err := fetchJSON(f.Request, repoURL, token, &repo); err != nil {
+		return nil, fmt.Errorf("error fetching repository: %v", err)
+	}
+
+	return &models.Repository{
+		Name:            repo.FullName,
+		Description:     repo.Description,
+		URL:             repo.HTMLURL,
+		Language:        repo.Language,
+		ForksCount:      repo.ForksCount,
+		StarsCount:      repo.StarsCount,
+		OpenIssuesCount: repo.OpenIssuesCount,
+		WatchersCount:   repo.WatchersCount,
+		DefaultBranch:   repo.DefaultBranch,
+		CreatedAt:       repo.CreatedAt,
+		UpdatedAt:       repo.UpdatedAt,
+	}, nil
+}
+
+// FetchCommits retrieves every commit of the default branch between since and until, following the pagination links.
+// An empty until leaves the range open-ended.
+func (f *GiteaFetcher) FetchCommits(repoName, token, since, until string) ([]models.Commit, error) {
+	return f.FetchBranchCommits(repoName, token, "", since, until)
+}
+
+// FetchBranches retrieves the names of every branch of a repository
+func (f *GiteaFetcher) FetchBranches(repoName, token string) ([]string, error) {
+	next := fmt.Sprintf("%s/repos/%s/branches?limit=%d", f.BaseURL, repoName, giteaCommitsPerPage)
+
+	names := make([]string, 0)
+	for next != "" {
+		var branches []RefResponse
+		link, err := fetchJSON(f.Request, next, token, &branches)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching branches: %v", err)
+		}
+		for _, branch := range branches {
+			names = append(names, branch.Name)
+		}
+		next = nextPageURL(link)
+	}
+
+	return names, nil
+}
+
+// FetchBranchHead retrieves the SHA a branch currently points to
+func (f *GiteaFetcher) FetchBranchHead(repoName, token, branch string) (string, error) {
+	var head RefResponse
+	if _, err := fetchJSON(f.Request, fmt.Sprintf("%s/repos/%s/branches/%s", f.BaseURL, repoName, branch), token, &head); err != nil {
+		return "", fmt.Errorf("error fetching branch: %v", err)
+	}
+	return head.SHA(), nil
+}
+
+// FetchUnreachable compares the new head against the old one, whose extra commits are no longer reachable
+func (f *GiteaFetcher) FetchUnreachable(repoName, token, oldHead, newHead string) ([]string, error) {
+	var comparison GitHubCompareResponse
+	_, err := fetchJSON(f.Request, fmt.Sprintf("%s/repos/%s/compare/%s...%s", f.BaseURL, repoName, newHead, oldHead), token, &comparison)
+	if isNotFound(err) {
+		return []string{oldHead}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error comparing commits: %v", err)
+	}
+
+	lost := make([]string, 0, len(comparison.Commits))
+	for _, commit := range comparison.Commits {
+		lost = append(lost, commit.SHA)
+	}
+	return lost, nil
+}
+
+// FetchPullRequests retrieves at most limit of the pull requests updated since the given time, least recently updated first
+func (f *GiteaFetcher) FetchPullRequests(repoName, token string, since time.Time, limit int) ([]models.PullRequest, error) {
+	query := fmt.Sprintf("state=all&sort=recentupdate&limit=%d", giteaCommitsPerPage)
+	return fetchGitHubPullRequests(f.Request, fmt.Sprintf("%s/repos/%s/pulls", f.BaseURL, repoName), query, token, since, limit)
+}
+
+// FetchIssues retrieves the issues updated since the given time
+func (f *GiteaFetcher) FetchIssues(repoName, token string, since time.Time) ([]models.Issue, error) {
+	issuesURL := fmt.Sprintf("%s/repos/%s/issues?state=all&type=issues&limit=%d", f.BaseURL, repoName, giteaCommitsPerPage)
+	if !since.IsZero() {
+		issuesURL += "&since=" + url.QueryEscape(since.UTC().Format(time.RFC3339))
+	}
+	return fetchGitHubIssues(f.Request, issuesURL, token)
+}
+
+// FetchTags retrieves every tag of a repository along with the commit it points to
+func (f *GiteaFetcher) FetchTags(repoName, token string) ([]models.Tag, error) {
+	return fetchTags(f.Request, fmt.Sprintf("%s/repos/%s/tags?limit=%d", f.BaseURL, repoName, giteaCommitsPerPage), token)
+}
+
+// FetchReleases retrieves every release of a repository, drafts included when the token may see them
+func (f *GiteaFetcher) FetchReleases(repoName, token string) ([]models.Release, error) {
+	return fetchGitHubReleases(f.Request, fmt.Sprintf("%s/repos/%s/releases?limit=%d", f.BaseURL, repoName, giteaCommitsPerPage), token)
+}
+
+// FetchBranchCommits retrieves every commit of a branch between since and until, following the pagination links.
+// An empty branch reads the default branch, an empty until leaves the range open-ended.
+func (f *GiteaFetcher) FetchBranchCommits(repoName, token, branch, since, until string) ([]models.Commit, error) {
+	params := url.Values{}
+	if branch != "" {
+		params.Set("sha", branch)
+	}
+	// The range is applied by the instance, which compares it with the commit dates
+	if since != "" {
+		params.Set("since", since)
+	}
+	if until != "" {
+		params.Set("until", until)
+	}
+	params.Set("limit", strconv.Itoa(giteaCommitsPerPage))
+	params.Set("stat", "false")
+	params.Set("files", "false")
+	next := fmt.Sprintf("%s/repos/%s/commits?%s", f.BaseURL, repoName, params.Encode())
+
+	return readCommitPages(repoName, next, f.MaxPages, func(pageURL string) ([]models.Commit, string, error) {
+		var commits []GitHubCommitResponse
+		link, err := fetchJSON(f.Request, pageURL, token, &commits)
+		if err != nil {
+			return nil, "", fmt.Errorf("error fetching commits: %v", err)
+		}
+
+		records := make([]models.Commit, 0, len(commits))
+		for _, commit := range commits {
+			records = append(records, commit.toCommit())
+		}
+		return records, link, nil
+	})
+}
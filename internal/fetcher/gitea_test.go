@@ -0,0 +1,54 @@
+package fetcher
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestGiteaFetchRepository_Success(t *testing.T) {
+	mockFetcher := &GiteaFetcher{
+		BaseURL: "https://gitea.example.com/api/v1",
+		Request: func(url, token string, header http.Header) (*http.Response, error) {
+			if url != "https://gitea.example.com/api/v1/repos/owner/repo" {
+				t.Errorf("unexpected url: %s", url)
+			}
+			return mockResponse(200, `{"full_name": "owner/repo", "stars_count": 7, "language": "Go"}`), nil
+		},
+	}
+
+	repo, err := mockFetcher.FetchRepository("owner/repo", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if repo.Name != "owner/repo" || repo.StarsCount != 7 {
+		t.Errorf("unexpected repository: %+v", repo)
+	}
+}
+
+func TestGiteaFetchCommits_PassesRange(t *testing.T) {
+	mockFetcher := &GiteaFetcher{
+		BaseURL: "https://gitea.example.com/api/v1",
+		Request: func(url, token string, header http.Header) (*http.Response, error) {
+			if !strings.Contains(url, "/repos/owner/repo/commits?") {
+				t.Errorf("unexpected url: %s", url)
+			}
+			if !strings.Contains(url, "since=2023-01-01T00%3A00%3A00Z") || !strings.Contains(url, "until=2023-02-01T00%3A00%3A00Z") {
+				t.Errorf("expected the range to be passed to the API, got: %s", url)
+			}
+			body := `[
+				{"sha": "new", "commit": {"author": {"name": "dev", "date": "2023-01-02T00:00:00Z"}, "message": "in range"}},
+				{"sha": "rebased", "commit": {"author": {"name": "dev", "date": "2022-12-31T00:00:00Z"}, "committer": {"date": "2023-01-03T00:00:00Z"}, "message": "authored earlier"}}
+			]`
+			return mockResponse(200, body), nil
+		},
+	}
+
+	commits, err := mockFetcher.FetchCommits("owner/repo", "", "2023-01-01T00:00:00Z", "2023-02-01T00:00:00Z")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(commits) != 2 || commits[1].CommitHash != "rebased" {
+		t.Errorf("expected every commit returned by the API, got: %+v", commits)
+	}
+}
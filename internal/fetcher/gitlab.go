@@ -0,0 +1,323 @@
+package fetcher
+
+import (
+	"fmt"
+	"gmonitor/internal/models"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GitLabFetcher fetches repositories and commits from the GitLab v4 REST API
+type GitLabFetcher struct {
+	BaseURL string
+	Request HTTPFetcher
+	// Client tracks the rate limit budget of Request, it is nil when Request is stubbed
+	Client *Client
+	// MaxPages caps the number of commit pages fetched per call, 0 means no limit
+	MaxPages int
+}
+
+// NewGitLabFetcher creates a fetcher for the GitLab instance serving the given API base URL
+func NewGitLabFetcher(baseURL string) *GitLabFetcher {
+	client := NewAPIClient(GitLabAPI)
+	return &GitLabFetcher{BaseURL: baseURL, Request: client.Do, Client: client}
+}
+
+// PausedUntil returns the time until which requests to the instance are held back by a rate limit
+func (f *GitLabFetcher) PausedUntil() time.Time {
+	if f.Client == nil {
+		return time.Time{}
+	}
+	return f.Client.PausedUntil()
+}
+
+func (f *GitLabFetcher) FetchRepository(repoName, token string) (*models.Repository, error) {
+	projectURL := fmt.Sprintf("%s/projects/%s", f.BaseURL, url.PathEscape(repoName))
+
+	var project GitLabProjectResponse
+	if _, err := fetchJSON(f.Request, projectURL, token, &project); err != nil {
+		return nil, fmt.Errorf("error fetching repository: %v", err)
+	}
+
+	return &models.Repository{
+		Name:            project.PathWithNamespace,
+		Description:     project.Description,
+		URL:             project.WebURL,
+		ForksCount:      project.ForksCount,
+		StarsCount:      project.StarCount,
+		OpenIssuesCount: project.OpenIssuesCount,
+		DefaultBranch:   project.DefaultBranch,
+		CreatedAt:       project.CreatedAt,
+		UpdatedAt:       project.LastActivityAt,
+	}, nil
+}
+
+// FetchCommits retrieves every commit of the default branch between since and until, following the pagination links.
+// An empty until leaves the range open-ended.
+func (f *GitLabFetcher) FetchCommits(repoName, token, since, until string) ([]models.Commit, error) {
+	return f.FetchBranchCommits(repoName, token, "", since, until)
+}
+
+// FetchBranches retrieves the names of every branch of a project
+func (f *GitLabFetcher) FetchBranches(repoName, token string) ([]string, error) {
+	next := fmt.Sprintf("%s/projects/%s/repository/branches?per_page=%d", f.BaseURL, url.PathEscape(repoName), commitsPerPage)
+
+	names := make([]string, 0)
+	for next != "" {
+		var branches []RefResponse
+		link, err := fetchJSON(f.Request, next, token, &branches)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching branches: %v", err)
+		}
+		for _, branch := range branches {
+			names = append(names, branch.Name)
+		}
+		next = nextPageURL(link)
+	}
+
+	return names, nil
+}
+
+// FetchBranchHead retrieves the SHA a branch currently points to
+func (f *GitLabFetcher) FetchBranchHead(repoName, token, branch string) (string, error) {
+	branchURL := fmt.Sprintf("%s/projects/%s/repository/branches/%s", f.BaseURL, url.PathEscape(repoName), url.PathEscape(branch))
+
+	var head RefResponse
+	if _, err := fetchJSON(f.Request, branchURL, token, &head); err != nil {
+		return "", fmt.Errorf("error fetching branch: %v", err)
+	}
+	return head.SHA(), nil
+}
+
+// FetchUnreachable compares the new head against the old one, whose extra commits are no longer reachable
+func (f *GitLabFetcher) FetchUnreachable(repoName, token, oldHead, newHead string) ([]string, error) {
+	params := url.Values{}
+	params.Set("from", newHead)
+	params.Set("to", oldHead)
+	compareURL := fmt.Sprintf("%s/projects/%s/repository/compare?%s", f.BaseURL, url.PathEscape(repoName), params.Encode())
+
+	var comparison GitLabCompareResponse
+	_, err := fetchJSON(f.Request, compareURL, token, &comparison)
+	if isNotFound(err) {
+		return []string{oldHead}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error comparing commits: %v", err)
+	}
+
+	lost := make([]string, 0, len(comparison.Commits))
+	for _, commit := range comparison.Commits {
+		lost = append(lost, commit.ID)
+	}
+	return lost, nil
+}
+
+// FetchPullRequests retrieves the merge requests updated since the given time, most recently updated first.
+// Merge requests carry no line counts or reviews, so those are left empty and the listing alone is not limited.
+func (f *GitLabFetcher) FetchPullRequests(repoName, token string, since time.Time, limit int) ([]models.PullRequest, error) {
+	next := fmt.Sprintf("%s/projects/%s/merge_requests?state=all&order_by=updated_at&sort=desc&per_page=%d",
+		f.BaseURL, url.PathEscape(repoName), commitsPerPage)
+	if !since.IsZero() {
+		next += "&updated_after=" + url.QueryEscape(since.UTC().Format(time.RFC3339))
+	}
+
+	pulls := make([]models.PullRequest, 0)
+	for next != "" {
+		var page []GitLabMergeRequestResponse
+		link, err := fetchJSON(f.Request, next, token, &page)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching merge requests: %v", err)
+		}
+		for _, mr := range page {
+			pr := models.PullRequest{
+				Number:         mr.IID,
+				Title:          mr.Title,
+				Author:         mr.Author.Username,
+				State:          models.PullRequestOpen,
+				BaseBranch:     mr.TargetBranch,
+				HeadBranch:     mr.SourceBranch,
+				OpenedAt:       mr.CreatedAt,
+				MergedAt:       mr.MergedAt,
+				ClosedAt:       mr.ClosedAt,
+				LastActivityAt: mr.UpdatedAt,
+			}
+			switch mr.State {
+			case "merged":
+				pr.State = models.PullRequestMerged
+				pr.MergeCommitSHA = mr.MergeCommitSHA
+				if pr.MergeCommitSHA == "" {
+					pr.MergeCommitSHA = mr.SquashCommitSHA
+				}
+			case "closed":
+				pr.State = models.PullRequestClosed
+			}
+			pulls = append(pulls, pr)
+		}
+		next = nextPageURL(link)
+	}
+
+	return pulls, nil
+}
+
+// FetchIssues retrieves the issues updated since the given time
+func (f *GitLabFetcher) FetchIssues(repoName, token string, since time.Time) ([]models.Issue, error) {
+	next := fmt.Sprintf("%s/projects/%s/issues?scope=all&per_page=%d", f.BaseURL, url.PathEscape(repoName), commitsPerPage)
+	if !since.IsZero() {
+		next += "&updated_after=" + url.QueryEscape(since.UTC().Format(time.RFC3339))
+	}
+
+	issues := make([]models.Issue, 0)
+	for next != "" {
+		var page []GitLabIssueResponse
+		link, err := fetchJSON(f.Request, next, token, &page)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching issues: %v", err)
+		}
+		for _, issue := range page {
+			assignees := make([]string, 0, len(issue.Assignees))
+			for _, assignee := range issue.Assignees {
+				assignees = append(assignees, assignee.Username)
+			}
+
+			state := models.IssueOpen
+			if issue.State == "closed" {
+				state = models.IssueClosed
+			}
+			issues = append(issues, models.Issue{
+				Number:         issue.IID,
+				Title:          issue.Title,
+				Author:         issue.Author.Username,
+				State:          state,
+				Labels:         issue.Labels,
+				Assignees:      assignees,
+				OpenedAt:       issue.CreatedAt,
+				ClosedAt:       issue.ClosedAt,
+				LastActivityAt: issue.UpdatedAt,
+			})
+		}
+		next = nextPageURL(link)
+	}
+
+	return issues, nil
+}
+
+// FetchTags retrieves every tag of a project along with the commit it points to
+func (f *GitLabFetcher) FetchTags(repoName, token string) ([]models.Tag, error) {
+	return fetchTags(f.Request, fmt.Sprintf("%s/projects/%s/repository/tags?per_page=%d", f.BaseURL, url.PathEscape(repoName), commitsPerPage), token)
+}
+
+// FetchReleases retrieves every release of a project, upcoming releases are reported as drafts
+func (f *GitLabFetcher) FetchReleases(repoName, token string) ([]models.Release, error) {
+	next := fmt.Sprintf("%s/projects/%s/releases?per_page=%d", f.BaseURL, url.PathEscape(repoName), commitsPerPage)
+
+	releases := make([]models.Release, 0)
+	for next != "" {
+		var page []GitLabReleaseResponse
+		link, err := fetchJSON(f.Request, next, token, &page)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching releases: %v", err)
+		}
+		for _, release := range page {
+			releases = append(releases, models.Release{
+				TagName:     release.TagName,
+				Name:        release.Name,
+				TargetSHA:   release.Commit.ID,
+				Draft:       release.UpcomingRelease,
+				Notes:       release.Description,
+				URL:         release.Links.Self,
+				PublishedAt: release.ReleasedAt,
+			})
+		}
+		next = nextPageURL(link)
+	}
+
+	return releases, nil
+}
+
+// FetchBranchCommits retrieves every commit of a branch between since and until, following the pagination links.
+// An empty branch reads the default branch, an empty until leaves the range open-ended.
+func (f *GitLabFetcher) FetchBranchCommits(repoName, token, branch, since, until string) ([]models.Commit, error) {
+	params := url.Values{}
+	if branch != "" {
+		params.Set("ref_name", branch)
+	}
+	params.Set("since", since)
+	if until != "" {
+		params.Set("until", until)
+	}
+	params.Set("per_page", strconv.Itoa(commitsPerPage))
+	next := fmt.Sprintf("%s/projects/%s/repository/commits?%s", f.BaseURL, url.PathEscape(repoName), params.Encode())
+
+	return readCommitPages(repoName, next, f.MaxPages, func(pageURL string) ([]models.Commit, string, error) {
+		var commits []GitLabCommitResponse
+		link, err := fetchJSON(f.Request, pageURL, token, &commits)
+		if err != nil {
+			return nil, "", fmt.Errorf("error fetching commits: %v", err)
+		}
+
+		records := make([]models.Commit, 0, len(commits))
+		for _, commit := range commits {
+			records = append(records, models.Commit{
+				CommitHash:     commit.ID,
+				Author:         commit.AuthorName,
+				AuthorEmail:    commit.AuthorEmail,
+				Message:        commit.Message,
+				CommitURL:      commit.WebURL,
+				CommitDate:     commit.AuthoredDate,
+				CommitterName:  commit.CommitterName,
+				CommitterEmail: commit.CommitterEmail,
+				CommitterDate:  commit.CommittedDate,
+				Parents:        commit.ParentIDs,
+			})
+		}
+		return records, link, nil
+	})
+}
+
+// FetchCommitFiles retrieves the files changed by a commit, counting the added and removed lines of each diff
+func (f *GitLabFetcher) FetchCommitFiles(repoName, token, sha string) ([]models.CommitFile, error) {
+	next := fmt.Sprintf("%s/projects/%s/repository/commits/%s/diff?per_page=%d",
+		f.BaseURL, url.PathEscape(repoName), sha, commitsPerPage)
+
+	files := make([]models.CommitFile, 0)
+	for next != "" {
+		var diffs []GitLabDiffResponse
+		link, err := fetchJSON(f.Request, next, token, &diffs)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching commit diff: %v", err)
+		}
+
+		for _, diff := range diffs {
+			file := models.CommitFile{Filename: diff.NewPath, Status: "modified"}
+			switch {
+			case diff.NewFile:
+				file.Status = "added"
+			case diff.DeletedFile:
+				file.Status = "removed"
+			case diff.RenamedFile:
+				file.Status = "renamed"
+				file.PreviousFilename = diff.OldPath
+			}
+			file.Additions, file.Deletions = countDiffLines(diff.Diff)
+			files = append(files, file)
+		}
+		next = nextPageURL(link)
+	}
+
+	return files, nil
+}
+
+// countDiffLines counts the added and removed lines of a unified diff without file headers
+func countDiffLines(diff string) (additions, deletions int) {
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "+"):
+			additions++
+		case strings.HasPrefix(line, "-"):
+			deletions++
+		}
+	}
+	return additions, deletions
+}
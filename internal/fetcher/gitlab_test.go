@@ -0,0 +1,133 @@
+package fetcher
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGitLabFetchRepository_Success(t *testing.T) {
+	mockFetcher := &GitLabFetcher{
+		BaseURL: "https://gitlab.example.com/api/v4",
+		Request: func(url, token string, header http.Header) (*http.Response, error) {
+			if url != "https://gitlab.example.com/api/v4/projects/group%2Fsub%2Fproject" {
+				t.Errorf("unexpected url: %s", url)
+			}
+			body := `{
+				"path_with_namespace": "group/sub/project",
+				"description": "desc",
+				"web_url": "https://gitlab.example.com/group/sub/project",
+				"forks_count": 2,
+				"star_count": 3,
+				"open_issues_count": 1,
+				"created_at": "2022-01-01T00:00:00Z",
+				"last_activity_at": "2022-02-01T00:00:00Z"
+			}`
+			return mockResponse(200, body), nil
+		},
+	}
+
+	repo, err := mockFetcher.FetchRepository("group/sub/project", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if repo.Name != "group/sub/project" || repo.StarsCount != 3 {
+		t.Errorf("unexpected repository: %+v", repo)
+	}
+}
+
+func TestGitLabFetchCommits_Success(t *testing.T) {
+	calls := 0
+	mockFetcher := &GitLabFetcher{
+		BaseURL: "https://gitlab.example.com/api/v4",
+		Request: func(url, token string, header http.Header) (*http.Response, error) {
+			calls++
+			if calls == 1 {
+				if !strings.Contains(url, "/projects/group%2Fproject/repository/commits?") {
+					t.Errorf("unexpected url: %s", url)
+				}
+				resp := mockResponse(200, `[{"id": "abc123", "author_name": "dev", "message": "first", "authored_date": "2023-01-01T12:00:00Z"}]`)
+				resp.Header = http.Header{"Link": []string{`<https://gitlab.example.com/api/v4/next>; rel="next"`}}
+				return resp, nil
+			}
+			return mockResponse(200, `[{"id": "def456", "author_name": "dev", "message": "second"}]`), nil
+		},
+	}
+
+	commits, err := mockFetcher.FetchCommits("group/project", "", "2023-01-01T00:00:00Z", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(commits) != 2 || commits[0].CommitHash != "abc123" || commits[0].Author != "dev" {
+		t.Errorf("unexpected commits: %+v", commits)
+	}
+}
+
+func TestGitLabFetchCommits_RequestError(t *testing.T) {
+	mockFetcher := &GitLabFetcher{
+		Request: func(url, token string, header http.Header) (*http.Response, error) {
+			return nil, errors.New("mock failure")
+		},
+	}
+
+	_, err := mockFetcher.FetchCommits("group/project", "", "2023-01-01T00:00:00Z", "")
+	if err == nil || !strings.Contains(err.Error(), "error fetching commits") {
+		t.Errorf("expected fetch error, got: %v", err)
+	}
+}
+
+func TestGitLabFetchCommitFiles_CountsDiffLines(t *testing.T) {
+	mockFetcher := &GitLabFetcher{
+		BaseURL: "https://gitlab.example.com/api/v4",
+		Request: func(url, token string, header http.Header) (*http.Response, error) {
+			if !strings.Contains(url, "/projects/group%2Fproject/repository/commits/abc123/diff?") {
+				t.Errorf("unexpected url: %s", url)
+			}
+			return mockResponse(200, `[
+				{"old_path": "a.go", "new_path": "a.go", "diff": "@@ -1,2 +1,2 @@\n-old\n+new\n+more\n context\n"},
+				{"old_path": "b.go", "new_path": "c.go", "renamed_file": true, "diff": ""}
+			]`), nil
+		},
+	}
+
+	files, err := mockFetcher.FetchCommitFiles("group/project", "", "abc123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(files) != 2 || files[0].Additions != 2 || files[0].Deletions != 1 || files[0].Status != "modified" {
+		t.Errorf("unexpected first file: %+v", files)
+	}
+	if files[1].Status != "renamed" || files[1].PreviousFilename != "b.go" {
+		t.Errorf("unexpected renamed file: %+v", files[1])
+	}
+}
+
+func TestGitLabFetchPullRequests(t *testing.T) {
+	mockFetcher := &GitLabFetcher{
+		BaseURL: "https://gitlab.example.com/api/v4",
+		Request: func(url, token string, header http.Header) (*http.Response, error) {
+			if !strings.Contains(url, "/projects/group%2Fproject/merge_requests?") || !strings.Contains(url, "updated_after=2023-01-01T00%3A00%3A00Z") {
+				t.Errorf("unexpected url: %s", url)
+			}
+			return mockResponse(200, `[
+				{"iid": 7, "title": "Squashed", "state": "merged", "author": {"username": "dev"},
+				 "source_branch": "feature", "target_branch": "main", "squash_commit_sha": "abc123",
+				 "created_at": "2023-01-01T00:00:00Z", "updated_at": "2023-01-02T00:00:00Z", "merged_at": "2023-01-02T00:00:00Z"},
+				{"iid": 8, "state": "locked", "created_at": "2023-01-01T00:00:00Z", "updated_at": "2023-01-02T00:00:00Z"}
+			]`), nil
+		},
+	}
+
+	pulls, err := mockFetcher.FetchPullRequests("group/project", "", time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(pulls) != 2 || pulls[0].Number != 7 || pulls[0].State != "merged" || pulls[0].MergeCommitSHA != "abc123" {
+		t.Errorf("unexpected merge requests: %+v", pulls)
+	}
+	if pulls[1].State != "open" {
+		t.Errorf("expected a locked merge request to be open, got %q", pulls[1].State)
+	}
+}
@@ -0,0 +1,395 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/utils/merkletrie"
+	"gmonitor/internal/models"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// originBranchPrefix is the reference prefix of the branches of the origin remote in a working clone
+const originBranchPrefix = "refs/remotes/origin/"
+
+// LocalFetcher reads repositories and commits straight from bare or working clones on disk
+type LocalFetcher struct {
+	// Root is the directory the repository names are resolved against
+	Root string
+}
+
+// NewLocalFetcher creates a fetcher for the clones below the given root directory
+func NewLocalFetcher(root string) *LocalFetcher {
+	return &LocalFetcher{Root: root}
+}
+
+func (f *LocalFetcher) FetchRepository(repoName, _ string) (*models.Repository, error) {
+	repo, path, err := f.open(repoName)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.Repository{
+		Name: repoName,
+		URL:  "file://" + filepath.ToSlash(path),
+	}
+	if remote, err := repo.Remote("origin"); err == nil && len(remote.Config().URLs) > 0 {
+		result.URL = remote.Config().URLs[0]
+	}
+
+	if ref, err := repo.Head(); err == nil && ref.Name().IsBranch() {
+		result.DefaultBranch = ref.Name().Short()
+	}
+
+	head, err := headCommit(repo)
+	if err != nil {
+		return nil, err
+	}
+	if head != nil {
+		result.UpdatedAt = head.Committer.When
+	}
+
+	return result, nil
+}
+
+// FetchCommits walks the history of HEAD and returns the commits committed between since and until.
+// An empty until leaves the range open-ended.
+func (f *LocalFetcher) FetchCommits(repoName, token, since, until string) ([]models.Commit, error) {
+	return f.FetchBranchCommits(repoName, token, "", since, until)
+}
+
+// FetchBranches lists the local branches of a clone along with the branches of its origin remote
+func (f *LocalFetcher) FetchBranches(repoName, _ string) ([]string, error) {
+	repo, _, err := f.open(repoName)
+	if err != nil {
+		return nil, err
+	}
+
+	refs, err := repo.References()
+	if err != nil {
+		return nil, fmt.Errorf("error reading references: %v", err)
+	}
+	defer refs.Close()
+
+	seen := make(map[string]bool)
+	names := make([]string, 0)
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		name := ""
+		switch {
+		case ref.Name().IsBranch():
+			name = ref.Name().Short()
+		case strings.HasPrefix(ref.Name().String(), originBranchPrefix):
+			name = strings.TrimPrefix(ref.Name().String(), originBranchPrefix)
+		}
+		if name != "" && name != "HEAD" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading references: %v", err)
+	}
+
+	return names, nil
+}
+
+// FetchBranchHead resolves the SHA a branch currently points to
+func (f *LocalFetcher) FetchBranchHead(repoName, _, branch string) (string, error) {
+	repo, _, err := f.open(repoName)
+	if err != nil {
+		return "", err
+	}
+
+	head, err := branchCommit(repo, branch)
+	if err != nil {
+		return "", err
+	}
+	return head.Hash.String(), nil
+}
+
+// FetchUnreachable walks the history of the old head up to the commits that are still reachable from the new head
+func (f *LocalFetcher) FetchUnreachable(repoName, _, oldHead, newHead string) ([]string, error) {
+	repo, _, err := f.open(repoName)
+	if err != nil {
+		return nil, err
+	}
+
+	old, err := repo.CommitObject(plumbing.NewHash(oldHead))
+	if errors.Is(err, plumbing.ErrObjectNotFound) {
+		return []string{oldHead}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading commit %s: %v", oldHead, err)
+	}
+
+	reachable := make(map[plumbing.Hash]bool)
+	iter, err := repo.Log(&git.LogOptions{From: plumbing.NewHash(newHead)})
+	if err != nil {
+		return nil, fmt.Errorf("error reading history: %v", err)
+	}
+	err = iter.ForEach(func(commit *object.Commit) error {
+		reachable[commit.Hash] = true
+		return nil
+	})
+	iter.Close()
+	if err != nil {
+		return nil, fmt.Errorf("error reading history: %v", err)
+	}
+
+	lost := make([]string, 0)
+	seen := make(map[plumbing.Hash]bool)
+	pending := []*object.Commit{old}
+	for len(pending) > 0 {
+		commit := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if reachable[commit.Hash] || seen[commit.Hash] {
+			continue
+		}
+		seen[commit.Hash] = true
+		lost = append(lost, commit.Hash.String())
+
+		err := commit.Parents().ForEach(func(parent *object.Commit) error {
+			pending = append(pending, parent)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error reading parents of %s: %v", commit.Hash, err)
+		}
+	}
+
+	return lost, nil
+}
+
+// FetchTags lists the tags of a clone, annotated tags are peeled to the commit they point to
+func (f *LocalFetcher) FetchTags(repoName, _ string) ([]models.Tag, error) {
+	repo, _, err := f.open(repoName)
+	if err != nil {
+		return nil, err
+	}
+
+	refs, err := repo.Tags()
+	if err != nil {
+		return nil, fmt.Errorf("error reading tags: %v", err)
+	}
+	defer refs.Close()
+
+	tags := make([]models.Tag, 0)
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		target := ref.Hash()
+		if tag, err := repo.TagObject(target); err == nil {
+			commit, err := tag.Commit()
+			if err != nil {
+				// Tags of trees or blobs carry no commit
+				return nil
+			}
+			target = commit.Hash
+		}
+		tags = append(tags, models.Tag{Name: ref.Name().Short(), TargetSHA: target.String()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading tags: %v", err)
+	}
+
+	return tags, nil
+}
+
+// FetchReleases returns no releases, plain git repositories only know tags
+func (f *LocalFetcher) FetchReleases(_, _ string) ([]models.Release, error) {
+	return []models.Release{}, nil
+}
+
+// FetchBranchCommits walks the history of a branch and returns the commits committed between since and until.
+// Like the hosted APIs, the range applies to the committer date, so rebased and cherry-picked commits whose author
+// date predates since are still returned. The walk follows committer dates and stops at the first commit before since.
+// An empty branch reads HEAD, an empty until leaves the range open-ended.
+func (f *LocalFetcher) FetchBranchCommits(repoName, _, branch, since, until string) ([]models.Commit, error) {
+	repo, _, err := f.open(repoName)
+	if err != nil {
+		return nil, err
+	}
+
+	var head *object.Commit
+	if branch == "" {
+		head, err = headCommit(repo)
+	} else {
+		head, err = branchCommit(repo, branch)
+	}
+	if err != nil || head == nil {
+		return []models.Commit{}, err
+	}
+
+	sinceTime, _ := time.Parse(time.RFC3339, since)
+	untilTime, _ := time.Parse(time.RFC3339, until)
+
+	iter, err := repo.Log(&git.LogOptions{From: head.Hash, Order: git.LogOrderCommitterTime})
+	if err != nil {
+		return nil, fmt.Errorf("error reading history: %v", err)
+	}
+	defer iter.Close()
+
+	commitRecords := make([]models.Commit, 0)
+	err = iter.ForEach(func(commit *object.Commit) error {
+		committed := commit.Committer.When
+		if committed.Before(sinceTime) {
+			return storer.ErrStop
+		}
+		if !untilTime.IsZero() && committed.After(untilTime) {
+			return nil
+		}
+		record := models.Commit{
+			CommitHash:     commit.Hash.String(),
+			Author:         commit.Author.Name,
+			AuthorEmail:    commit.Author.Email,
+			Message:        commit.Message,
+			CommitDate:     commit.Author.When,
+			CommitterName:  commit.Committer.Name,
+			CommitterEmail: commit.Committer.Email,
+			CommitterDate:  commit.Committer.When,
+			TreeSHA:        commit.TreeHash.String(),
+		}
+		for _, parent := range commit.ParentHashes {
+			record.Parents = append(record.Parents, parent.String())
+		}
+		commitRecords = append(commitRecords, record)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading history: %v", err)
+	}
+
+	return commitRecords, nil
+}
+
+// FetchCommitFiles computes the files changed by a commit against its first parent, detecting renames like git does
+func (f *LocalFetcher) FetchCommitFiles(repoName, _, sha string) ([]models.CommitFile, error) {
+	repo, _, err := f.open(repoName)
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := repo.CommitObject(plumbing.NewHash(sha))
+	if err != nil {
+		return nil, fmt.Errorf("error reading commit %s: %v", sha, err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("error reading tree of commit %s: %v", sha, err)
+	}
+
+	// A root commit is compared with the empty tree
+	var parentTree *object.Tree
+	if commit.NumParents() > 0 {
+		parent, err := commit.Parent(0)
+		if err != nil {
+			return nil, fmt.Errorf("error reading parent of commit %s: %v", sha, err)
+		}
+		if parentTree, err = parent.Tree(); err != nil {
+			return nil, fmt.Errorf("error reading tree of commit %s: %v", parent.Hash, err)
+		}
+	}
+
+	changes, err := object.DiffTreeWithOptions(context.Background(), parentTree, tree, object.DefaultDiffTreeOptions)
+	if err != nil {
+		return nil, fmt.Errorf("error computing changes of commit %s: %v", sha, err)
+	}
+
+	files := make([]models.CommitFile, 0, len(changes))
+	for _, change := range changes {
+		action, err := change.Action()
+		if err != nil {
+			return nil, fmt.Errorf("error computing changes of commit %s: %v", sha, err)
+		}
+		patch, err := change.Patch()
+		if err != nil {
+			return nil, fmt.Errorf("error computing patch of commit %s: %v", sha, err)
+		}
+
+		file := models.CommitFile{Filename: change.To.Name, Status: "modified"}
+		switch {
+		case action == merkletrie.Insert:
+			file.Status = "added"
+		case action == merkletrie.Delete:
+			file.Filename = change.From.Name
+			file.Status = "removed"
+		case change.From.Name != change.To.Name:
+			file.Status = "renamed"
+			file.PreviousFilename = change.From.Name
+		}
+		for _, stat := range patch.Stats() {
+			file.Additions += stat.Addition
+			file.Deletions += stat.Deletion
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// open resolves a repository name below the root directory and opens the clone, trying a ".git" suffix for bare mirrors
+func (f *LocalFetcher) open(repoName string) (*git.Repository, string, error) {
+	if f.Root == "" {
+		return nil, "", errors.New("no root directory configured for local repositories")
+	}
+
+	path := filepath.Join(f.Root, filepath.FromSlash(repoName))
+	if rel, err := filepath.Rel(f.Root, path); err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return nil, "", fmt.Errorf("invalid local repository name: %s", repoName)
+	}
+
+	var lastErr error
+	for _, candidate := range []string{path, path + ".git"} {
+		repo, err := git.PlainOpen(candidate)
+		if err == nil {
+			return repo, candidate, nil
+		}
+		lastErr = err
+	}
+	return nil, "", fmt.Errorf("error opening local repository %s: %v", repoName, lastErr)
+}
+
+// branchCommit returns the tip of a local branch, falling back to the branch of the origin remote
+func branchCommit(repo *git.Repository, branch string) (*object.Commit, error) {
+	for _, name := range []plumbing.ReferenceName{
+		plumbing.NewBranchReferenceName(branch),
+		plumbing.ReferenceName(originBranchPrefix + branch),
+	} {
+		ref, err := repo.Reference(name, true)
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error resolving branch %s: %v", branch, err)
+		}
+
+		commit, err := repo.CommitObject(ref.Hash())
+		if err != nil {
+			return nil, fmt.Errorf("error reading tip of branch %s: %v", branch, err)
+		}
+		return commit, nil
+	}
+	return nil, fmt.Errorf("branch %s not found", branch)
+}
+
+// headCommit returns the commit HEAD points to, or nil for a repository without commits
+func headCommit(repo *git.Repository) (*object.Commit, error) {
+	ref, err := repo.Head()
+	if err != nil {
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error resolving HEAD: %v", err)
+	}
+
+	commit, err := repo.CommitObject(ref.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("error reading HEAD commit: %v", err)
+	}
+	return commit, nil
+}
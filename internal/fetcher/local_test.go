@@ -0,0 +1,250 @@
+package fetcher
+
+import (
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// initLocalRepo creates a clone at root/name with one commit per given author date
+func initLocalRepo(t *testing.T, root, name string, dates ...time.Time) {
+	path := filepath.Join(root, name)
+	repo, err := git.PlainInit(path, false)
+	if err != nil {
+		t.Fatalf("failed to init repository: %v", err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("failed to open worktree: %v", err)
+	}
+
+	for i, date := range dates {
+		file := filepath.Join(path, "file.txt")
+		if err := os.WriteFile(file, []byte(date.String()), 0o644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+		if _, err := worktree.Add("file.txt"); err != nil {
+			t.Fatalf("failed to stage file: %v", err)
+		}
+		signature := &object.Signature{Name: "dev", Email: "dev@example.com", When: date}
+		if _, err := worktree.Commit("commit "+string(rune('a'+i)), &git.CommitOptions{Author: signature}); err != nil {
+			t.Fatalf("failed to commit: %v", err)
+		}
+	}
+}
+
+func TestLocalFetchCommits_FiltersRange(t *testing.T) {
+	root := t.TempDir()
+	initLocalRepo(t, root, "owner/repo",
+		time.Date(2022, 12, 31, 0, 0, 0, 0, time.UTC),
+		time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC),
+	)
+
+	commits, err := NewLocalFetcher(root).FetchCommits("owner/repo", "", "2023-01-01T00:00:00Z", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(commits) != 1 || commits[0].Message != "commit b" || commits[0].Author != "dev" {
+		t.Errorf("unexpected commits: %+v", commits)
+	}
+	if len(commits[0].CommitHash) != 40 {
+		t.Errorf("expected a full commit hash, got %q", commits[0].CommitHash)
+	}
+}
+
+func TestLocalFetchCommits_UsesCommitterDate(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "owner/repo")
+	repo, err := git.PlainInit(path, false)
+	if err != nil {
+		t.Fatalf("failed to init repository: %v", err)
+	}
+	worktree, _ := repo.Worktree()
+
+	// The second commit was rebased: authored before since, committed after it
+	commits := []struct{ authored, committed time.Time }{
+		{time.Date(2022, 12, 30, 0, 0, 0, 0, time.UTC), time.Date(2022, 12, 30, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2022, 12, 31, 0, 0, 0, 0, time.UTC), time.Date(2023, 1, 3, 0, 0, 0, 0, time.UTC)},
+	}
+	for i, commit := range commits {
+		if err := os.WriteFile(filepath.Join(path, "file.txt"), []byte{byte('a' + i)}, 0o644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+		_, _ = worktree.Add("file.txt")
+		_, err := worktree.Commit("commit", &git.CommitOptions{
+			Author:    &object.Signature{Name: "dev", When: commit.authored},
+			Committer: &object.Signature{Name: "dev", When: commit.committed},
+		})
+		if err != nil {
+			t.Fatalf("failed to commit: %v", err)
+		}
+	}
+
+	found, err := NewLocalFetcher(root).FetchCommits("owner/repo", "", "2023-01-01T00:00:00Z", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(found) != 1 || !found[0].CommitDate.Equal(commits[1].authored) {
+		t.Errorf("expected only the rebased commit, got: %+v", found)
+	}
+}
+
+func TestLocalFetchCommitFiles_DetectsRenames(t *testing.T) {
+	root := t.TempDir()
+	initLocalRepo(t, root, "owner/repo", time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC))
+
+	path := filepath.Join(root, "owner/repo")
+	repo, _ := git.PlainOpen(path)
+	worktree, _ := repo.Worktree()
+	if err := os.Rename(filepath.Join(path, "file.txt"), filepath.Join(path, "renamed.txt")); err != nil {
+		t.Fatalf("failed to rename file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(path, "new.txt"), []byte("one\ntwo\n"), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := worktree.AddWithOptions(&git.AddOptions{All: true}); err != nil {
+		t.Fatalf("failed to stage changes: %v", err)
+	}
+	signature := &object.Signature{Name: "dev", When: time.Date(2023, 1, 3, 0, 0, 0, 0, time.UTC)}
+	hash, err := worktree.Commit("rename", &git.CommitOptions{Author: signature})
+	if err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+
+	files, err := NewLocalFetcher(root).FetchCommitFiles("owner/repo", "", hash.String())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	byName := make(map[string]string)
+	for _, file := range files {
+		byName[file.Filename] = file.Status + " " + file.PreviousFilename
+		if file.Filename == "new.txt" && file.Additions != 2 {
+			t.Errorf("expected 2 added lines, got: %+v", file)
+		}
+	}
+	if len(files) != 2 || byName["renamed.txt"] != "renamed file.txt" || byName["new.txt"] != "added " {
+		t.Errorf("unexpected files: %+v", files)
+	}
+}
+
+func TestLocalFetchRepository(t *testing.T) {
+	root := t.TempDir()
+	initLocalRepo(t, root, "owner/repo", time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC))
+
+	repo, err := NewLocalFetcher(root).FetchRepository("owner/repo", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if repo.Name != "owner/repo" || repo.URL == "" {
+		t.Errorf("unexpected repository: %+v", repo)
+	}
+}
+
+func TestLocalFetcher_RejectsPathsOutsideRoot(t *testing.T) {
+	fetcher := NewLocalFetcher(t.TempDir())
+	if _, err := fetcher.FetchRepository("../other", ""); err == nil {
+		t.Error("expected an error for a name escaping the root directory")
+	}
+}
+
+func TestLocalFetchBranchCommits(t *testing.T) {
+	root := t.TempDir()
+	initLocalRepo(t, root, "owner/repo", time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC))
+
+	repo, err := git.PlainOpen(filepath.Join(root, "owner/repo"))
+	if err != nil {
+		t.Fatalf("failed to open repository: %v", err)
+	}
+	head, _ := repo.Head()
+	if err := repo.Storer.SetReference(plumbing.NewHashReference(plumbing.NewBranchReferenceName("release/1.0"), head.Hash())); err != nil {
+		t.Fatalf("failed to create branch: %v", err)
+	}
+
+	fetcher := NewLocalFetcher(root)
+	branches, err := fetcher.FetchBranches("owner/repo", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(branches) != 2 {
+		t.Errorf("expected 2 branches, got %v", branches)
+	}
+
+	commits, err := fetcher.FetchBranchCommits("owner/repo", "", "release/1.0", "2023-01-01T00:00:00Z", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(commits) != 1 || commits[0].CommitHash != head.Hash().String() {
+		t.Errorf("unexpected commits: %+v", commits)
+	}
+
+	if _, err := fetcher.FetchBranchCommits("owner/repo", "", "missing", "2023-01-01T00:00:00Z", ""); err == nil {
+		t.Error("expected an error for a missing branch")
+	}
+}
+
+func TestLocalFetchUnreachable_AfterReset(t *testing.T) {
+	root := t.TempDir()
+	initLocalRepo(t, root, "owner/repo",
+		time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC),
+		time.Date(2023, 1, 3, 0, 0, 0, 0, time.UTC),
+	)
+
+	repo, err := git.PlainOpen(filepath.Join(root, "owner/repo"))
+	if err != nil {
+		t.Fatalf("failed to open repository: %v", err)
+	}
+	head, _ := repo.Head()
+	tip, _ := repo.CommitObject(head.Hash())
+	parent, _ := tip.Parent(0)
+	first, _ := parent.Parent(0)
+
+	lost, err := NewLocalFetcher(root).FetchUnreachable("owner/repo", "", tip.Hash.String(), first.Hash.String())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(lost) != 2 {
+		t.Errorf("expected the two dropped commits, got %v", lost)
+	}
+
+	lost, err = NewLocalFetcher(root).FetchUnreachable("owner/repo", "", first.Hash.String(), tip.Hash.String())
+	if err != nil || len(lost) != 0 {
+		t.Errorf("expected nothing lost on a fast-forward, got %v (%v)", lost, err)
+	}
+}
+
+func TestLocalFetchTags_PeelsAnnotatedTags(t *testing.T) {
+	root := t.TempDir()
+	initLocalRepo(t, root, "owner/repo", time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC))
+
+	repo, err := git.PlainOpen(filepath.Join(root, "owner/repo"))
+	if err != nil {
+		t.Fatalf("failed to open repository: %v", err)
+	}
+	head, _ := repo.Head()
+	if _, err := repo.CreateTag("v1.0", head.Hash(), nil); err != nil {
+		t.Fatalf("failed to create tag: %v", err)
+	}
+	signature := &object.Signature{Name: "dev", Email: "dev@example.com", When: time.Now()}
+	if _, err := repo.CreateTag("v1.1", head.Hash(), &git.CreateTagOptions{Tagger: signature, Message: "release"}); err != nil {
+		t.Fatalf("failed to create annotated tag: %v", err)
+	}
+
+	tags, err := NewLocalFetcher(root).FetchTags("owner/repo", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(tags) != 2 {
+		t.Fatalf("expected 2 tags, got %+v", tags)
+	}
+	for _, tag := range tags {
+		if tag.TargetSHA != head.Hash().String() {
+			t.Errorf("expected tag %s to point to HEAD, got %s", tag.Name, tag.TargetSHA)
+		}
+	}
+}
@@ -0,0 +1,512 @@
+package fetcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"gmonitor/internal/models"
+	"io"
+	"log"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Supported source providers
+const (
+	ProviderGitHub = "github"
+	ProviderGitLab = "gitlab"
+	ProviderGitea  = "gitea"
+	ProviderLocal  = "local"
+)
+
+// Provider fetches repository metadata and commit history from a source hosting service
+type Provider interface {
+	FetchRepository(repoName, token string) (*models.Repository, error)
+	FetchCommits(repoName, token, since, until string) ([]models.Commit, error)
+}
+
+// RateLimited is implemented by providers that hold back requests while a rate limit is in effect
+type RateLimited interface {
+	PausedUntil() time.Time
+}
+
+// CommitFileFetcher is implemented by providers that can report the files changed by a single commit
+type CommitFileFetcher interface {
+	FetchCommitFiles(repoName, token, sha string) ([]models.CommitFile, error)
+}
+
+// BranchFetcher is implemented by providers that can list branches and read the history of a single branch
+type BranchFetcher interface {
+	FetchBranches(repoName, token string) ([]string, error)
+	// FetchBranchCommits works like FetchCommits on the given branch, an empty branch reads the default branch
+	FetchBranchCommits(repoName, token, branch, since, until string) ([]models.Commit, error)
+}
+
+// HistoryFetcher is implemented by providers that can detect commits dropped from a branch by a force-push
+type HistoryFetcher interface {
+	FetchBranchHead(repoName, token, branch string) (string, error)
+	// FetchUnreachable returns the commits reachable from oldHead that are not reachable from newHead.
+	// An old head the provider no longer knows is returned on its own.
+	FetchUnreachable(repoName, token, oldHead, newHead string) ([]string, error)
+}
+
+// ReleaseFetcher is implemented by providers that can list the tags and releases of a repository
+type ReleaseFetcher interface {
+	FetchTags(repoName, token string) ([]models.Tag, error)
+	FetchReleases(repoName, token string) ([]models.Release, error)
+}
+
+// TagTargetFetcher is implemented by providers that can resolve the commit a single tag points to
+type TagTargetFetcher interface {
+	FetchTagTarget(repoName, token, tag string) (string, error)
+}
+
+// PullRequestFetcher is implemented by providers that can list the pull or merge requests of a repository
+type PullRequestFetcher interface {
+	// FetchPullRequests returns the pull requests updated at or after since, with their size and reviews where the
+	// provider has them, a zero since returns every pull request. Providers that need requests per pull request to
+	// complete it return at most limit of the least recently updated ones, so a backfill spreads over several polls.
+	FetchPullRequests(repoName, token string, since time.Time, limit int) ([]models.PullRequest, error)
+}
+
+// IssueFetcher is implemented by providers that can list the issues of a repository
+type IssueFetcher interface {
+	// FetchIssues returns the issues updated at or after since, a zero since returns every issue
+	FetchIssues(repoName, token string, since time.Time) ([]models.Issue, error)
+}
+
+// Registry resolves the provider instance and credentials responsible for a repository
+type Registry struct {
+	GitHub *GitHubFetcher
+	// LocalRoot is the directory local clones are read from, local repositories are disabled when empty
+	LocalRoot string
+	// CommitFiles enables fetching the changed files of every new commit, which costs one request per commit
+	CommitFiles bool
+	// MaxPages caps the commit pages fetched per call by the providers the registry creates, 0 means no limit
+	MaxPages int
+
+	mu        sync.Mutex
+	tokens    map[string]TokenSource
+	providers map[string]Provider
+}
+
+// NewRegistry creates a registry that serves GitHub repositories with the given fetcher
+func NewRegistry(github *GitHubFetcher) *Registry {
+	return &Registry{
+		GitHub:    github,
+		tokens:    make(map[string]TokenSource),
+		providers: make(map[string]Provider),
+	}
+}
+
+// SetToken configures a static access token for a provider host
+func (r *Registry) SetToken(provider, host, token string) {
+	r.SetTokenSource(provider, host, StaticToken(token))
+}
+
+// SetTokenSource configures where the access tokens of a provider host come from.
+// A source set without a host applies to every host of the provider, except that a
+// host-less GitHub source is only ever used for github.com.
+func (r *Registry) SetTokenSource(provider, host string, source TokenSource) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokens[tokenKey(provider, host)] = source
+}
+
+// Token returns the access token to use for a repository
+func (r *Registry) Token(repo *models.Repository) (string, error) {
+	r.mu.Lock()
+	source, found := r.tokens[tokenKey(repo.Provider, repo.Host)]
+	if !found && (normalizeProvider(repo.Provider) != ProviderGitHub || isDefaultGitHubHost(repo.Host)) {
+		source, found = r.tokens[tokenKey(repo.Provider, "")]
+	}
+	r.mu.Unlock()
+
+	if !found {
+		return "", nil
+	}
+	return source.Token(repo)
+}
+
+// tokenKey identifies the token of a provider host
+func tokenKey(provider, host string) string {
+	provider = normalizeProvider(provider)
+	if provider == ProviderGitHub && isDefaultGitHubHost(host) {
+		host = ""
+	}
+	return provider + "|" + strings.ToLower(strings.TrimSuffix(host, "/"))
+}
+
+// isDefaultGitHubHost reports whether a host refers to github.com
+func isDefaultGitHubHost(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "/"))
+	return host == "" || host == "github.com" || host == "https://github.com"
+}
+
+// CanonicalHost is the form a repository host is stored and looked up in: lower case, without the https scheme or
+// a trailing slash, and empty for the default host of the provider, github.com or gitlab.com
+func CanonicalHost(provider, host string) string {
+	host = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(host), "/"))
+	host = strings.TrimPrefix(host, "https://")
+	switch normalizeProvider(provider) {
+	case ProviderGitHub:
+		if host == "github.com" || host == "api.github.com" {
+			return ""
+		}
+	case ProviderGitLab:
+		if host == "gitlab.com" {
+			return ""
+		}
+	}
+	return host
+}
+
+// Get returns the provider serving repositories of the given kind on the given host
+func (r *Registry) Get(provider, host string) (Provider, error) {
+	provider = normalizeProvider(provider)
+	if provider == ProviderGitHub && isDefaultGitHubHost(host) {
+		return r.GitHub, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := provider + "|" + host
+	if p, found := r.providers[key]; found {
+		return p, nil
+	}
+
+	var p Provider
+	switch provider {
+	case ProviderGitHub:
+		github := NewGitHubEnterpriseFetcher(apiBaseURL(host, "/api/v3"))
+		github.Validators = r.GitHub.Validators
+		github.MaxPages = r.MaxPages
+		p = github
+	case ProviderGitLab:
+		if host == "" {
+			host = "gitlab.com"
+		}
+		gitlab := NewGitLabFetcher(apiBaseURL(host, "/api/v4"))
+		gitlab.MaxPages = r.MaxPages
+		p = gitlab
+	case ProviderGitea:
+		if host == "" {
+			return nil, fmt.Errorf("a host is required for %s repositories", provider)
+		}
+		gitea := NewGiteaFetcher(apiBaseURL(host, "/api/v1"))
+		gitea.MaxPages = r.MaxPages
+		p = gitea
+	case ProviderLocal:
+		if r.LocalRoot == "" {
+			return nil, fmt.Errorf("local repositories are not enabled")
+		}
+		p = NewLocalFetcher(r.LocalRoot)
+	default:
+		return nil, fmt.Errorf("unsupported provider: %s", provider)
+	}
+
+	r.providers[key] = p
+	return p, nil
+}
+
+// AttachCommitFiles fills in the changed files of each commit when enabled and supported by the provider
+func (r *Registry) AttachCommitFiles(provider Provider, repoName, token string, commits []models.Commit) error {
+	files, ok := provider.(CommitFileFetcher)
+	if !r.CommitFiles || !ok {
+		return nil
+	}
+
+	for i := range commits {
+		changed, err := files.FetchCommitFiles(repoName, token, commits[i].CommitHash)
+		if err != nil {
+			return fmt.Errorf("error fetching files of commit %s: %v", commits[i].CommitHash, err)
+		}
+		for j := range changed {
+			changed[j].Directory = path.Dir(changed[j].Filename)
+		}
+		commits[i].Files = changed
+	}
+	return nil
+}
+
+// normalizeProvider maps an empty provider name to GitHub, the default
+func normalizeProvider(provider string) string {
+	provider = strings.ToLower(strings.TrimSpace(provider))
+	if provider == "" {
+		return ProviderGitHub
+	}
+	return provider
+}
+
+// apiBaseURL builds an API base URL from a host, which may include a scheme.
+// Hosts that already carry a path are taken to be a full API base URL.
+func apiBaseURL(host, apiPath string) string {
+	host = strings.TrimSuffix(host, "/")
+	if !strings.Contains(host, "://") {
+		host = "https://" + host
+	}
+	if strings.Contains(host[strings.Index(host, "://")+3:], "/") {
+		return host
+	}
+	return host + apiPath
+}
+
+// fetchTags retrieves every page of a tag listing starting at url
+func fetchTags(request HTTPFetcher, url, token string) ([]models.Tag, error) {
+	tags := make([]models.Tag, 0)
+	for next := url; next != ""; {
+		var refs []RefResponse
+		link, err := fetchJSON(request, next, token, &refs)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching tags: %v", err)
+		}
+		for _, ref := range refs {
+			tags = append(tags, models.Tag{Name: ref.Name, TargetSHA: ref.SHA()})
+		}
+		next = nextPageURL(link)
+	}
+	return tags, nil
+}
+
+// fetchGitHubReleases retrieves every page of a GitHub or Gitea release listing starting at url
+func fetchGitHubReleases(request HTTPFetcher, url, token string) ([]models.Release, error) {
+	releases := make([]models.Release, 0)
+	for next := url; next != ""; {
+		var page []GitHubReleaseResponse
+		link, err := fetchJSON(request, next, token, &page)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching releases: %v", err)
+		}
+		for _, release := range page {
+			releases = append(releases, models.Release{
+				TagName:     release.TagName,
+				Name:        release.Name,
+				Draft:       release.Draft,
+				Prerelease:  release.Prerelease,
+				Notes:       release.Body,
+				URL:         release.HTMLURL,
+				PublishedAt: release.PublishedAt,
+			})
+		}
+		next = nextPageURL(link)
+	}
+	return releases, nil
+}
+
+// fetchGitHubPullRequests retrieves the pull requests of a GitHub or Gitea listing sorted by last update, newest first.
+// The listing is read until the first pull request not updated since the given time. Each pull request is then
+// completed with its size and reviews, which costs two more requests, so only the limit least recently updated ones are
+// completed and returned, oldest first, a limit of 0 completing all of them. Pull requests updated at the same time as
+// the last one of the batch are kept in it, so that the cursor of the next poll moves past them.
+func fetchGitHubPullRequests(request HTTPFetcher, pullsURL, listQuery, token string, since time.Time, limit int) ([]models.PullRequest, error) {
+	listed := make([]GitHubPullRequestResponse, 0)
+	for next := pullsURL + "?" + listQuery; next != ""; {
+		var page []GitHubPullRequestResponse
+		link, err := fetchJSON(request, next, token, &page)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching pull requests: %v", err)
+		}
+
+		next = nextPageURL(link)
+		for _, pull := range page {
+			if pull.UpdatedAt.Before(since) {
+				next = ""
+				break
+			}
+			listed = append(listed, pull)
+		}
+	}
+
+	numbers := make([]int, 0, len(listed))
+	for i := len(listed) - 1; i >= 0; i-- {
+		if limit > 0 && len(numbers) >= limit && listed[i].UpdatedAt.After(listed[i+1].UpdatedAt) {
+			break
+		}
+		numbers = append(numbers, listed[i].Number)
+	}
+
+	pulls := make([]models.PullRequest, 0, len(numbers))
+	for _, number := range numbers {
+		var pull GitHubPullRequestResponse
+		if _, err := fetchJSON(request, fmt.Sprintf("%s/%d", pullsURL, number), token, &pull); err != nil {
+			return nil, fmt.Errorf("error fetching pull request #%d: %v", number, err)
+		}
+
+		pr := models.PullRequest{
+			Number:         pull.Number,
+			Title:          pull.Title,
+			Author:         pull.User.Login,
+			State:          models.PullRequestOpen,
+			BaseBranch:     pull.Base.Ref,
+			HeadBranch:     pull.Head.Ref,
+			Additions:      pull.Additions,
+			Deletions:      pull.Deletions,
+			OpenedAt:       pull.CreatedAt,
+			MergedAt:       pull.MergedAt,
+			ClosedAt:       pull.ClosedAt,
+			LastActivityAt: pull.UpdatedAt,
+		}
+		// Open pull requests report the SHA of a test merge, which never lands on a branch
+		if pull.MergedAt != nil {
+			pr.State = models.PullRequestMerged
+			pr.MergeCommitSHA = pull.MergeCommitSHA
+		} else if pull.State == "closed" {
+			pr.State = models.PullRequestClosed
+		}
+
+		if err := fetchGitHubReviews(request, fmt.Sprintf("%s/%d/reviews", pullsURL, number), token, &pr); err != nil {
+			return nil, err
+		}
+		pulls = append(pulls, pr)
+	}
+	return pulls, nil
+}
+
+// fetchGitHubIssues retrieves every page of a GitHub or Gitea issue listing starting at url, skipping pull requests
+func fetchGitHubIssues(request HTTPFetcher, url, token string) ([]models.Issue, error) {
+	issues := make([]models.Issue, 0)
+	for next := url; next != ""; {
+		var page []GitHubIssueResponse
+		link, err := fetchJSON(request, next, token, &page)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching issues: %v", err)
+		}
+		for _, issue := range page {
+			if issue.PullRequest != nil {
+				continue
+			}
+			labels := make([]string, 0, len(issue.Labels))
+			for _, label := range issue.Labels {
+				labels = append(labels, label.Name)
+			}
+			assignees := make([]string, 0, len(issue.Assignees))
+			for _, assignee := range issue.Assignees {
+				assignees = append(assignees, assignee.Login)
+			}
+
+			state := models.IssueOpen
+			if issue.State == "closed" {
+				state = models.IssueClosed
+			}
+			issues = append(issues, models.Issue{
+				Number:         issue.Number,
+				Title:          issue.Title,
+				Author:         issue.User.Login,
+				State:          state,
+				Labels:         labels,
+				Assignees:      assignees,
+				OpenedAt:       issue.CreatedAt,
+				ClosedAt:       issue.ClosedAt,
+				LastActivityAt: issue.UpdatedAt,
+			})
+		}
+		next = nextPageURL(link)
+	}
+	return issues, nil
+}
+
+// fetchGitHubReviews counts the submitted reviews of a pull request and records when the first one arrived.
+// Pending reviews and reviews by the author of the pull request are not counted.
+func fetchGitHubReviews(request HTTPFetcher, url, token string, pr *models.PullRequest) error {
+	for next := url; next != ""; {
+		var reviews []GitHubReviewResponse
+		link, err := fetchJSON(request, next, token, &reviews)
+		if err != nil {
+			return fmt.Errorf("error fetching reviews of pull request #%d: %v", pr.Number, err)
+		}
+		for _, review := range reviews {
+			if review.SubmittedAt == nil || strings.EqualFold(review.State, "PENDING") || review.User.Login == pr.Author {
+				continue
+			}
+			pr.ReviewCount++
+			if pr.FirstReviewAt == nil || review.SubmittedAt.Before(*pr.FirstReviewAt) {
+				pr.FirstReviewAt = review.SubmittedAt
+			}
+		}
+		next = nextPageURL(link)
+	}
+	return nil
+}
+
+// commitPageFetcher retrieves a page of a commit listing along with its Link header
+type commitPageFetcher func(pageURL string) ([]models.Commit, string, error)
+
+// readCommitPages reads a commit listing, newest first, following the pagination links from firstURL.
+// A listing longer than maxPages is read from its last page backwards instead: the oldest commits of the range are
+// kept, so the sync cursor moves up to the newest of them and the next poll goes on from there without skipping any.
+// Listings whose Link header does not tell their last page are read whole, since stopping early would lose commits.
+func readCommitPages(repoName, firstURL string, maxPages int, fetchPage commitPageFetcher) ([]models.Commit, error) {
+	commits, link, err := fetchPage(firstURL)
+	if err != nil {
+		return nil, err
+	}
+
+	last := linkURL(link, "last")
+	if maxPages > 0 && pageNumber(last) > maxPages {
+		log.Printf("Reading the oldest %d of %d commit pages of %s, the newer ones follow on the next polls",
+			maxPages, pageNumber(last), repoName)
+
+		pages := make([][]models.Commit, 0, maxPages)
+		for prev := last; prev != "" && len(pages) < maxPages; {
+			page, link, err := fetchPage(prev)
+			if err != nil {
+				return nil, err
+			}
+			pages = append(pages, page)
+			prev = linkURL(link, "prev")
+		}
+
+		commits = make([]models.Commit, 0)
+		for i := len(pages) - 1; i >= 0; i-- {
+			commits = append(commits, pages[i]...)
+		}
+		return commits, nil
+	}
+
+	for page, next := 1, nextPageURL(link); next != ""; page++ {
+		if maxPages > 0 && page == maxPages {
+			log.Printf("Commit listing of %s tells no last page, reading past the cap of %d pages", repoName, maxPages)
+		}
+
+		records, link, err := fetchPage(next)
+		if err != nil {
+			return nil, err
+		}
+		commits = append(commits, records...)
+		next = nextPageURL(link)
+	}
+	return commits, nil
+}
+
+// pageNumber returns the page parameter of a listing URL, 0 when it has none
+func pageNumber(pageURL string) int {
+	parsed, err := url.Parse(pageURL)
+	if err != nil {
+		return 0
+	}
+	page, _ := strconv.Atoi(parsed.Query().Get("page"))
+	return page
+}
+
+// fetchJSON retrieves a URL, decodes its JSON body into v and returns the Link header
+func fetchJSON(request HTTPFetcher, url, token string, v interface{}) (string, error) {
+	resp, err := request(url, token, nil)
+	if err != nil {
+		return "", err
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			log.Printf("Error closing response body: %v", err)
+		}
+	}(resp.Body)
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return "", fmt.Errorf("error decoding JSON: %v", err)
+	}
+	return resp.Header.Get("Link"), nil
+}
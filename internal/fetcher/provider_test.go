@@ -0,0 +1,123 @@
+package fetcher
+
+import (
+	"gmonitor/internal/models"
+	"testing"
+)
+
+func TestRegistryGet(t *testing.T) {
+	github := &GitHubFetcher{}
+	registry := NewRegistry(github)
+
+	if p, err := registry.Get("", ""); err != nil || p != Provider(github) {
+		t.Errorf("expected GitHub fetcher for the default provider, got %v (%v)", p, err)
+	}
+
+	p, err := registry.Get(ProviderGitLab, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gitlab, ok := p.(*GitLabFetcher); !ok || gitlab.BaseURL != "https://gitlab.com/api/v4" {
+		t.Errorf("unexpected GitLab provider: %+v", p)
+	}
+	if again, _ := registry.Get(ProviderGitLab, ""); again != p {
+		t.Error("expected provider instances to be reused per host")
+	}
+
+	p, err = registry.Get(ProviderGitea, "http://gitea.local:3000")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gitea, ok := p.(*GiteaFetcher); !ok || gitea.BaseURL != "http://gitea.local:3000/api/v1" {
+		t.Errorf("unexpected Gitea provider: %+v", p)
+	}
+
+	p, err = registry.Get(ProviderGitHub, "ghe.example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ghe, ok := p.(*GitHubFetcher); !ok || ghe == github || ghe.BaseURL != "https://ghe.example.com/api/v3" {
+		t.Errorf("unexpected GitHub Enterprise provider: %+v", p)
+	}
+	p, _ = registry.Get(ProviderGitHub, "https://ghe2.example.com/custom/api")
+	if ghe, ok := p.(*GitHubFetcher); !ok || ghe.BaseURL != "https://ghe2.example.com/custom/api" {
+		t.Errorf("expected a host with a path to be used as base URL, got: %+v", p)
+	}
+
+	if _, err := registry.Get(ProviderGitea, ""); err == nil {
+		t.Error("expected error for Gitea without a host")
+	}
+	if _, err := registry.Get(ProviderLocal, ""); err == nil {
+		t.Error("expected error for local repositories without a root directory")
+	}
+	registry.LocalRoot = "/srv/mirrors"
+	if p, _ := registry.Get(ProviderLocal, ""); p.(*LocalFetcher).Root != "/srv/mirrors" {
+		t.Errorf("unexpected local provider: %+v", p)
+	}
+	if _, err := registry.Get("svn", ""); err == nil {
+		t.Error("expected error for an unsupported provider")
+	}
+}
+
+func TestRegistryGet_AppliesMaxPages(t *testing.T) {
+	registry := NewRegistry(&GitHubFetcher{})
+	registry.MaxPages = 5
+
+	p, _ := registry.Get(ProviderGitLab, "")
+	if gitlab := p.(*GitLabFetcher); gitlab.MaxPages != 5 {
+		t.Errorf("expected GitLab page cap 5, got: %d", gitlab.MaxPages)
+	}
+	p, _ = registry.Get(ProviderGitea, "gitea.local")
+	if gitea := p.(*GiteaFetcher); gitea.MaxPages != 5 {
+		t.Errorf("expected Gitea page cap 5, got: %d", gitea.MaxPages)
+	}
+	p, _ = registry.Get(ProviderGitHub, "ghe.example.com")
+	if ghe := p.(*GitHubFetcher); ghe.MaxPages != 5 {
+		t.Errorf("expected GitHub Enterprise page cap 5, got: %d", ghe.MaxPages)
+	}
+}
+
+func TestRegistryToken(t *testing.T) {
+	registry := NewRegistry(&GitHubFetcher{})
+	registry.SetToken(ProviderGitHub, "", "gh")
+	registry.SetToken(ProviderGitHub, "ghe.example.com", "ghe")
+	registry.SetToken(ProviderGitLab, "", "gl")
+
+	tests := []struct {
+		provider, host, want string
+	}{
+		{"", "", "gh"},
+		{ProviderGitHub, "github.com", "gh"},
+		{ProviderGitHub, "ghe.example.com", "ghe"},
+		{ProviderGitHub, "other.example.com", ""},
+		{"GitLab", "gitlab.example.com", "gl"},
+	}
+	for _, tt := range tests {
+		token, err := registry.Token(&models.Repository{Name: "o/r", Provider: tt.provider, Host: tt.host})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if token != tt.want {
+			t.Errorf("token for %s on %q: expected %q, got %q", tt.provider, tt.host, tt.want, token)
+		}
+	}
+}
+
+func TestCanonicalHost(t *testing.T) {
+	tests := []struct {
+		provider, host, expected string
+	}{
+		{ProviderGitHub, "", ""},
+		{"", "https://GitHub.com/", ""},
+		{ProviderGitHub, "ghe.example.com", "ghe.example.com"},
+		{ProviderGitHub, "https://GHE.example.com/", "ghe.example.com"},
+		{ProviderGitLab, "gitlab.com", ""},
+		{ProviderGitLab, "https://gitlab.example.com", "gitlab.example.com"},
+		{ProviderGitea, "http://gitea.local:3000", "http://gitea.local:3000"},
+	}
+	for _, tt := range tests {
+		if host := CanonicalHost(tt.provider, tt.host); host != tt.expected {
+			t.Errorf("CanonicalHost(%q, %q) = %q, expected %q", tt.provider, tt.host, host, tt.expected)
+		}
+	}
+}
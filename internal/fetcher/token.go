@@ -0,0 +1,211 @@
+package fetcher
+
+import (
+	"errors"
+	"gmonitor/internal/models"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// TokenSource supplies the access token used to fetch a repository
+type TokenSource interface {
+	Token(repo *models.Repository) (string, error)
+}
+
+// StaticToken is a TokenSource that always returns the same token, e.g. a personal access token
+type StaticToken string
+
+func (t StaticToken) Token(*models.Repository) (string, error) {
+	return string(t), nil
+}
+
+// assumedBudget is the budget assumed for a token before GitHub has reported its real one
+const assumedBudget = 5000
+
+// TokenStatus reports the budget and health of a pooled token
+type TokenStatus struct {
+	Token       string    `json:"token"`
+	Remaining   int       `json:"remaining"`
+	Reset       time.Time `json:"reset"`
+	PausedUntil time.Time `json:"paused_until"`
+	Disabled    bool      `json:"disabled"`
+}
+
+// pooledToken is the state the pool keeps for one credential
+type pooledToken struct {
+	token       string
+	remaining   int
+	reset       time.Time
+	pausedUntil time.Time
+	disabled    bool
+}
+
+// TokenPool rotates requests across several tokens, preferring the one with the largest remaining budget.
+// Tokens rejected with 401 are taken out of rotation, exhausted tokens are skipped until their budget resets.
+type TokenPool struct {
+	mu     sync.Mutex
+	tokens []*pooledToken
+}
+
+// NewTokenPool creates a pool from the given tokens, ignoring empty ones
+func NewTokenPool(tokens []string) *TokenPool {
+	pool := &TokenPool{}
+	for _, token := range tokens {
+		if token == "" {
+			continue
+		}
+		pool.tokens = append(pool.tokens, &pooledToken{token: token, remaining: assumedBudget})
+	}
+	return pool
+}
+
+// Token returns the usable token with the largest remaining budget
+func (p *TokenPool) Token(*models.Repository) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	var best *pooledToken
+	for _, t := range p.tokens {
+		if t.disabled || now.Before(t.pausedUntil) {
+			continue
+		}
+		if t.remaining <= 0 && now.Before(t.reset) {
+			continue
+		}
+		if best == nil || t.budget(now) > best.budget(now) {
+			best = t
+		}
+	}
+	if best == nil {
+		return "", errors.New("no usable token left in the pool")
+	}
+
+	// Reserve one request so that concurrent callers spread across tokens
+	best.remaining = best.budget(now) - 1
+	return best.token, nil
+}
+
+// Observe updates the state of a token from the response GitHub sent for it
+func (p *TokenPool) Observe(token string, status int, header http.Header) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	t := p.find(token)
+	if t == nil {
+		return
+	}
+
+	if status == http.StatusUnauthorized {
+		if !t.disabled {
+			log.Printf("Token %s was rejected with 401, taking it out of rotation", maskToken(token))
+		}
+		t.disabled = true
+		return
+	}
+
+	if remaining, err := strconv.Atoi(header.Get("X-RateLimit-Remaining")); err == nil {
+		t.remaining = remaining
+	}
+	if reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		t.reset = time.Unix(reset, 0)
+	}
+}
+
+// Owns reports whether a token belongs to the pool
+func (p *TokenPool) Owns(token string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.find(token) != nil
+}
+
+// Pause holds a token back until the given time
+func (p *TokenPool) Pause(token string, until time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if t := p.find(token); t != nil && until.After(t.pausedUntil) {
+		t.pausedUntil = until
+	}
+}
+
+// PausedUntil returns the earliest time at which a token becomes usable again, zero when one is usable now
+func (p *TokenPool) PausedUntil() time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	var earliest time.Time
+	for _, t := range p.tokens {
+		if t.disabled {
+			continue
+		}
+		until := t.pausedUntil
+		if t.remaining <= 0 && t.reset.After(until) {
+			until = t.reset
+		}
+		if !now.Before(until) {
+			return time.Time{}
+		}
+		if earliest.IsZero() || until.Before(earliest) {
+			earliest = until
+		}
+	}
+	return earliest
+}
+
+// tokenPausedUntil returns the time until which a single token is held back
+func (p *TokenPool) tokenPausedUntil(token string) time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if t := p.find(token); t != nil {
+		return t.pausedUntil
+	}
+	return time.Time{}
+}
+
+// Status reports the state of every pooled token with the token values masked
+func (p *TokenPool) Status() []TokenStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	statuses := make([]TokenStatus, 0, len(p.tokens))
+	for _, t := range p.tokens {
+		statuses = append(statuses, TokenStatus{
+			Token:       maskToken(t.token),
+			Remaining:   t.remaining,
+			Reset:       t.reset,
+			PausedUntil: t.pausedUntil,
+			Disabled:    t.disabled,
+		})
+	}
+	return statuses
+}
+
+// find returns the pooled state of a token, the caller must hold the lock
+func (p *TokenPool) find(token string) *pooledToken {
+	for _, t := range p.tokens {
+		if t.token == token {
+			return t
+		}
+	}
+	return nil
+}
+
+// budget returns the requests a token has left, restoring the full budget once its reset has passed
+func (t *pooledToken) budget(now time.Time) int {
+	if !t.reset.IsZero() && now.After(t.reset) {
+		return assumedBudget
+	}
+	return t.remaining
+}
+
+// maskToken hides all but the last four characters of a token
+func maskToken(token string) string {
+	if len(token) <= 4 {
+		return "****"
+	}
+	return "****" + token[len(token)-4:]
+}
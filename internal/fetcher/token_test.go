@@ -0,0 +1,114 @@
+package fetcher
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTokenPool_PrefersLargestBudget(t *testing.T) {
+	pool := NewTokenPool([]string{"token-a", "token-b", ""})
+	reset := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+
+	pool.Observe("token-a", http.StatusOK, http.Header{"X-Ratelimit-Remaining": []string{"10"}, "X-Ratelimit-Reset": []string{reset}})
+	pool.Observe("token-b", http.StatusOK, http.Header{"X-Ratelimit-Remaining": []string{"4000"}, "X-Ratelimit-Reset": []string{reset}})
+
+	token, err := pool.Token(nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if token != "token-b" {
+		t.Errorf("expected token with the largest budget, got %q", token)
+	}
+	if len(pool.Status()) != 2 {
+		t.Errorf("expected empty tokens to be ignored, got %d tokens", len(pool.Status()))
+	}
+}
+
+func TestTokenPool_SkipsExhaustedAndUnauthorized(t *testing.T) {
+	pool := NewTokenPool([]string{"token-a", "token-b"})
+	reset := time.Now().Add(time.Hour)
+
+	pool.Observe("token-a", http.StatusUnauthorized, nil)
+	pool.Observe("token-b", http.StatusForbidden, http.Header{
+		"X-Ratelimit-Remaining": []string{"0"},
+		"X-Ratelimit-Reset":     []string{strconv.FormatInt(reset.Unix(), 10)},
+	})
+
+	if _, err := pool.Token(nil); err == nil {
+		t.Fatal("expected error when no token is usable")
+	}
+	if until := pool.PausedUntil(); until.Unix() != reset.Unix() {
+		t.Errorf("expected pool to be paused until the reset, got %v", until)
+	}
+
+	status := pool.Status()
+	if !status[0].Disabled || status[1].Disabled {
+		t.Errorf("expected only the unauthorized token to be disabled: %+v", status)
+	}
+	if status[0].Token != "****en-a" {
+		t.Errorf("expected masked token, got %q", status[0].Token)
+	}
+}
+
+func TestClientDo_PoolPausesSingleToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "Bearer limited" {
+			w.Header().Set("Retry-After", "60")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := newTestClient()
+	client.Pool = NewTokenPool([]string{"limited", "healthy"})
+
+	_, err := client.Do(server.URL, "limited", nil)
+	var rateErr *RateLimitError
+	if !errors.As(err, &rateErr) {
+		t.Fatalf("expected rate limit error, got: %v", err)
+	}
+
+	resp, err := client.Do(server.URL, "healthy", nil)
+	if err != nil {
+		t.Fatalf("expected other tokens to keep working, got: %v", err)
+	}
+	resp.Body.Close()
+
+	if !client.PausedUntil().IsZero() {
+		t.Errorf("expected client not to be paused while a token is usable, got %v", client.PausedUntil())
+	}
+	if token, _ := client.Pool.Token(nil); token != "healthy" {
+		t.Errorf("expected pool to hand out the healthy token, got %q", token)
+	}
+}
+
+func TestClientDo_PoolLeavesForeignTokensToClientPause(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	// Installation tokens of a GitHub App are not part of the pool
+	client := newTestClient()
+	client.Pool = NewTokenPool([]string{"pooled"})
+
+	var rateErr *RateLimitError
+	if _, err := client.Do(server.URL, "installation", nil); !errors.As(err, &rateErr) {
+		t.Fatalf("expected rate limit error, got: %v", err)
+	}
+	if _, err := client.Do(server.URL, "installation", nil); !errors.As(err, &rateErr) {
+		t.Fatalf("expected rate limit error while paused, got: %v", err)
+	}
+	if attempts != 1 {
+		t.Errorf("expected the paused token to be held back, got %d attempts", attempts)
+	}
+}
@@ -144,10 +144,240 @@ type GitHubCommitResponse struct {
 	SHA     string `json:"sha"`
 	HTMLURL string `json:"html_url"`
 	Commit  struct {
-		Author struct {
-			Name string    `json:"name"`
-			Date time.Time `json:"date"`
-		} `json:"author"`
-		Message string `json:"message"`
+		Author    GitHubCommitSignature `json:"author"`
+		Committer GitHubCommitSignature `json:"committer"`
+		Message   string                `json:"message"`
+		Tree      struct {
+			SHA string `json:"sha"`
+		} `json:"tree"`
+		Verification struct {
+			Verified bool   `json:"verified"`
+			Reason   string `json:"reason"`
+		} `json:"verification"`
 	} `json:"commit"`
+	// Author is the account the commit author email belongs to, null when it matches none
+	Author *struct {
+		Login string `json:"login"`
+	} `json:"author"`
+	Parents []struct {
+		SHA string `json:"sha"`
+	} `json:"parents"`
+}
+
+// GitHubCommitDetailResponse maps to the JSON response for a single commit
+type GitHubCommitDetailResponse struct {
+	SHA   string `json:"sha"`
+	Files []struct {
+		Filename         string `json:"filename"`
+		PreviousFilename string `json:"previous_filename"`
+		Status           string `json:"status"`
+		Additions        int    `json:"additions"`
+		Deletions        int    `json:"deletions"`
+	} `json:"files"`
+}
+
+// RefResponse maps to a branch or tag as listed by the GitHub, GitLab and Gitea APIs
+type RefResponse struct {
+	Name   string `json:"name"`
+	Commit struct {
+		SHA string `json:"sha"`
+		ID  string `json:"id"`
+	} `json:"commit"`
+}
+
+// SHA returns the commit the ref points to, which providers report as either "sha" or "id"
+func (r *RefResponse) SHA() string {
+	if r.Commit.SHA != "" {
+		return r.Commit.SHA
+	}
+	return r.Commit.ID
+}
+
+// GitHubReleaseResponse maps to the JSON response for a GitHub or Gitea release
+type GitHubReleaseResponse struct {
+	TagName     string     `json:"tag_name"`
+	Name        string     `json:"name"`
+	Body        string     `json:"body"`
+	Draft       bool       `json:"draft"`
+	Prerelease  bool       `json:"prerelease"`
+	HTMLURL     string     `json:"html_url"`
+	PublishedAt *time.Time `json:"published_at"`
+}
+
+// GitHubPullRequestResponse maps to the JSON response for a GitHub or Gitea pull request
+type GitHubPullRequestResponse struct {
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	State  string `json:"state"`
+	User   struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	Base struct {
+		Ref string `json:"ref"`
+	} `json:"base"`
+	Head struct {
+		Ref string `json:"ref"`
+	} `json:"head"`
+	MergeCommitSHA string     `json:"merge_commit_sha"`
+	Additions      int        `json:"additions"`
+	Deletions      int        `json:"deletions"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	MergedAt       *time.Time `json:"merged_at"`
+	ClosedAt       *time.Time `json:"closed_at"`
+}
+
+// GitHubIssueResponse maps to the JSON response for a GitHub or Gitea issue
+type GitHubIssueResponse struct {
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	State  string `json:"state"`
+	User   struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	Labels []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+	Assignees []struct {
+		Login string `json:"login"`
+	} `json:"assignees"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	ClosedAt  *time.Time `json:"closed_at"`
+	// PullRequest is set when the issue is a pull request
+	PullRequest *struct{} `json:"pull_request"`
+}
+
+// GitHubReviewResponse maps to the JSON response for a review of a GitHub or Gitea pull request
+type GitHubReviewResponse struct {
+	State string `json:"state"`
+	User  struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	SubmittedAt *time.Time `json:"submitted_at"`
+}
+
+// GitHubCompareResponse maps to the JSON response for a comparison of two commits on GitHub and Gitea
+type GitHubCompareResponse struct {
+	Status  string `json:"status"`
+	Commits []struct {
+		SHA string `json:"sha"`
+	} `json:"commits"`
+}
+
+// GitHubCommitSignature is the git author or committer of a commit
+type GitHubCommitSignature struct {
+	Name  string    `json:"name"`
+	Email string    `json:"email"`
+	Date  time.Time `json:"date"`
+}
+
+// GitLabProjectResponse maps to the JSON response for a GitLab project
+type GitLabProjectResponse struct {
+	ID                int       `json:"id"`
+	PathWithNamespace string    `json:"path_with_namespace"`
+	Description       string    `json:"description"`
+	WebURL            string    `json:"web_url"`
+	DefaultBranch     string    `json:"default_branch"`
+	ForksCount        int       `json:"forks_count"`
+	StarCount         int       `json:"star_count"`
+	OpenIssuesCount   int       `json:"open_issues_count"`
+	CreatedAt         time.Time `json:"created_at"`
+	LastActivityAt    time.Time `json:"last_activity_at"`
+}
+
+// GitLabCommitResponse maps to the JSON response for GitLab commits
+type GitLabCommitResponse struct {
+	ID             string    `json:"id"`
+	Title          string    `json:"title"`
+	Message        string    `json:"message"`
+	AuthorName     string    `json:"author_name"`
+	AuthorEmail    string    `json:"author_email"`
+	AuthoredDate   time.Time `json:"authored_date"`
+	CommitterName  string    `json:"committer_name"`
+	CommitterEmail string    `json:"committer_email"`
+	CommittedDate  time.Time `json:"committed_date"`
+	ParentIDs      []string  `json:"parent_ids"`
+	WebURL         string    `json:"web_url"`
+}
+
+// GitLabCompareResponse maps to the JSON response for a comparison of two GitLab commits
+type GitLabCompareResponse struct {
+	Commits []GitLabCommitResponse `json:"commits"`
+}
+
+// GitLabReleaseResponse maps to the JSON response for a GitLab release
+type GitLabReleaseResponse struct {
+	TagName         string     `json:"tag_name"`
+	Name            string     `json:"name"`
+	Description     string     `json:"description"`
+	ReleasedAt      *time.Time `json:"released_at"`
+	UpcomingRelease bool       `json:"upcoming_release"`
+	Commit          struct {
+		ID string `json:"id"`
+	} `json:"commit"`
+	Links struct {
+		Self string `json:"self"`
+	} `json:"_links"`
+}
+
+// GitLabMergeRequestResponse maps to the JSON response for a GitLab merge request
+type GitLabMergeRequestResponse struct {
+	IID    int    `json:"iid"`
+	Title  string `json:"title"`
+	State  string `json:"state"`
+	Author struct {
+		Username string `json:"username"`
+	} `json:"author"`
+	SourceBranch    string     `json:"source_branch"`
+	TargetBranch    string     `json:"target_branch"`
+	MergeCommitSHA  string     `json:"merge_commit_sha"`
+	SquashCommitSHA string     `json:"squash_commit_sha"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	MergedAt        *time.Time `json:"merged_at"`
+	ClosedAt        *time.Time `json:"closed_at"`
+}
+
+// GitLabIssueResponse maps to the JSON response for a GitLab issue
+type GitLabIssueResponse struct {
+	IID    int      `json:"iid"`
+	Title  string   `json:"title"`
+	State  string   `json:"state"`
+	Labels []string `json:"labels"`
+	Author struct {
+		Username string `json:"username"`
+	} `json:"author"`
+	Assignees []struct {
+		Username string `json:"username"`
+	} `json:"assignees"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	ClosedAt  *time.Time `json:"closed_at"`
+}
+
+// GitLabDiffResponse maps to the JSON response for a file of a GitLab commit diff
+type GitLabDiffResponse struct {
+	OldPath     string `json:"old_path"`
+	NewPath     string `json:"new_path"`
+	NewFile     bool   `json:"new_file"`
+	RenamedFile bool   `json:"renamed_file"`
+	DeletedFile bool   `json:"deleted_file"`
+	Diff        string `json:"diff"`
+}
+
+// GiteaRepositoryResponse maps to the JSON response for a Gitea repository
+type GiteaRepositoryResponse struct {
+	ID              int       `json:"id"`
+	FullName        string    `json:"full_name"`
+	Description     string    `json:"description"`
+	HTMLURL         string    `json:"html_url"`
+	Language        string    `json:"language"`
+	DefaultBranch   string    `json:"default_branch"`
+	ForksCount      int       `json:"forks_count"`
+	StarsCount      int       `json:"stars_count"`
+	OpenIssuesCount int       `json:"open_issues_count"`
+	WatchersCount   int       `json:"watchers_count"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
 }
@@ -0,0 +1,120 @@
+package fetcher
+
+import (
+	"encoding/json"
+	"gmonitor/pkg/cache"
+	"log"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Validators holds the cache validators GitHub returned for a resource along with the response they validate,
+// which is replayed when GitHub answers 304 Not Modified
+type Validators struct {
+	// URL is the exact URL the validators were returned for, cursor included
+	URL          string `json:"url,omitempty"`
+	ETag         string `json:"etag"`
+	LastModified string `json:"last_modified"`
+	Link         string `json:"link,omitempty"`
+	Body         []byte `json:"body,omitempty"`
+}
+
+// maxValidatedBody caps the size of the responses stored along with their validators, larger ones are always
+// requested in full so that a single listing cannot fill the cache
+const maxValidatedBody = 1 << 20
+
+// ValidatorStore remembers validators per resource so that unchanged data can be requested conditionally.
+// Resources are identified by their URL without the sync cursor, see resourceKey.
+type ValidatorStore interface {
+	Get(url string) (Validators, bool)
+	Set(url string, validators Validators)
+}
+
+// MemoryValidatorStore keeps validators in process memory
+type MemoryValidatorStore struct {
+	mu      sync.Mutex
+	entries map[string]Validators
+}
+
+// NewMemoryValidatorStore creates an empty in-memory validator store
+func NewMemoryValidatorStore() *MemoryValidatorStore {
+	return &MemoryValidatorStore{entries: make(map[string]Validators)}
+}
+
+func (s *MemoryValidatorStore) Get(url string) (Validators, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	validators, found := s.entries[url]
+	return validators, found
+}
+
+func (s *MemoryValidatorStore) Set(url string, validators Validators) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[url] = validators
+}
+
+// CacheValidatorStore keeps validators in the shared cache so they survive restarts
+type CacheValidatorStore struct {
+	cache *cache.Cache
+	ttl   time.Duration
+}
+
+// NewCacheValidatorStore creates a validator store backed by the given cache
+func NewCacheValidatorStore(cache *cache.Cache, ttl time.Duration) *CacheValidatorStore {
+	return &CacheValidatorStore{cache: cache, ttl: ttl}
+}
+
+func (s *CacheValidatorStore) Get(url string) (Validators, bool) {
+	val, found, err := s.cache.Get(validatorKey(url))
+	if err != nil {
+		log.Printf("cache get error for validators of '%s': %v", url, err)
+		return Validators{}, false
+	}
+	if !found {
+		return Validators{}, false
+	}
+
+	var validators Validators
+	if err := json.Unmarshal([]byte(val), &validators); err != nil {
+		log.Printf("invalid validators cached for '%s': %v", url, err)
+		return Validators{}, false
+	}
+	return validators, true
+}
+
+func (s *CacheValidatorStore) Set(url string, validators Validators) {
+	data, err := json.Marshal(validators)
+	if err != nil {
+		log.Printf("failed to encode validators for '%s': %v", url, err)
+		return
+	}
+	if err := s.cache.Set(validatorKey(url), string(data), s.ttl); err != nil {
+		log.Printf("cache set error for validators of '%s': %v", url, err)
+	}
+}
+
+// validatorKey namespaces validator entries within the shared cache
+func validatorKey(url string) string {
+	return "validators:" + url
+}
+
+// cursorParams are the query parameters that move with the sync cursor of a repository
+var cursorParams = []string{"since", "until"}
+
+// resourceKey identifies the resource a URL requests regardless of the cursor it is requested from. Only the ETag of a
+// single page response is sent for another cursor: it matches the page content, while Last-Modified and the Link header
+// of a paginated listing depend on the window the cursor selects.
+func resourceKey(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	query := parsed.Query()
+	for _, param := range cursorParams {
+		query.Del(param)
+	}
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// Branch holds the sync state of a tracked branch of a repository
+type Branch struct {
+	gorm.Model
+	RepoID         uint   `gorm:"not null;uniqueIndex:idx_repo_id_branch_name"`
+	Name           string `gorm:"not null;size:255;uniqueIndex:idx_repo_id_branch_name"`
+	LastCommitSHA  string `gorm:"size:40"`
+	LastCommitDate *time.Time
+	LastSyncedAt   *time.Time
+	// HeadSHA is the commit the branch pointed to at the last poll, used to detect force-pushes
+	HeadSHA string `gorm:"size:40"`
+}
+
+// CommitBranch records that a commit was seen on a branch of its repository
+type CommitBranch struct {
+	gorm.Model
+	CommitID uint   `gorm:"not null;uniqueIndex:idx_commit_id_branch"`
+	Branch   string `gorm:"not null;size:255;uniqueIndex:idx_commit_id_branch;index"`
+}
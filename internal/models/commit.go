@@ -7,10 +7,42 @@ import (
 
 type Commit struct {
 	gorm.Model
-	RepoID     uint      `gorm:"not null;index:idx_repo_id_commit_hash;type:INTEGER"`
-	CommitHash string    `gorm:"unique;not null;size:40"`
-	Author     string    `gorm:"not null;size:255"`
-	Message    string    `gorm:"not null;type:TEXT"`
-	CommitDate time.Time `gorm:"not null;type:DATETIME DEFAULT CURRENT_TIMESTAMP"`
-	CommitURL  string    `gorm:"not null;size:255"`
+	// A commit is identified by its repository and hash, forks share hashes with their upstream
+	RepoID      uint      `gorm:"not null;uniqueIndex:idx_repo_id_commit_hash,priority:1;index:idx_repo_id_commit_date,priority:1"`
+	CommitHash  string    `gorm:"not null;size:40;uniqueIndex:idx_repo_id_commit_hash,priority:2"`
+	Author      string    `gorm:"not null;size:255"`
+	AuthorEmail string    `gorm:"size:255"`
+	AuthorLogin string    `gorm:"size:100;index"`
+	Message     string    `gorm:"not null;type:TEXT"`
+	CommitDate  time.Time `gorm:"not null;index:idx_repo_id_commit_date,priority:2"`
+	CommitURL   string    `gorm:"not null;size:255"`
+
+	CommitterName  string `gorm:"size:255"`
+	CommitterEmail string `gorm:"size:255"`
+	CommitterDate  time.Time
+	// Parents holds the parent SHAs, merge commits have more than one
+	Parents []string `gorm:"serializer:json;type:TEXT"`
+	TreeSHA string   `gorm:"size:40"`
+
+	// Signature verification as reported by the provider
+	Verified           bool   `gorm:"default:false"`
+	VerificationReason string `gorm:"size:50"`
+
+	// Orphaned commits were dropped from every branch they were seen on by a history rewrite
+	Orphaned   bool `gorm:"default:false;index"`
+	OrphanedAt *time.Time
+
+	// ContributorID is the canonical identity of the author
+	ContributorID *uint `gorm:"index"`
+
+	// FirstReleaseID is the earliest release that shipped the commit, prereleases and drafts aside
+	FirstReleaseID *uint `gorm:"index"`
+
+	// Files is only filled in when per-commit file statistics are fetched
+	Files []CommitFile `gorm:"foreignKey:CommitID" json:",omitempty"`
+}
+
+// IsMerge reports whether the commit has more than one parent
+func (c *Commit) IsMerge() bool {
+	return len(c.Parents) > 1
 }
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// CommitFile records the changes a commit made to a single file
+type CommitFile struct {
+	gorm.Model
+	CommitID         uint   `gorm:"not null;index"`
+	Filename         string `gorm:"not null;size:1024"`
+	PreviousFilename string `gorm:"size:1024"`
+	// Directory is the parent directory of Filename, "." for files at the repository root.
+	// MySQL indexes a prefix of it, a full utf8mb4 key would exceed InnoDB's 3072 byte limit.
+	Directory string `gorm:"not null;size:1024;index:,length:255"`
+	Status    string `gorm:"size:20"`
+	Additions int    `gorm:"default:0"`
+	Deletions int    `gorm:"default:0"`
+}
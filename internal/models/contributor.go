@@ -0,0 +1,30 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// Kinds of identity a contributor alias can match
+const (
+	AliasEmail = "email"
+	AliasLogin = "login"
+	AliasName  = "name"
+)
+
+// Contributor is the canonical identity behind the author names, emails and logins of commits
+type Contributor struct {
+	gorm.Model
+	Name    string             `gorm:"size:255;index"`
+	Email   string             `gorm:"size:255"`
+	Login   string             `gorm:"size:100"`
+	Aliases []ContributorAlias `gorm:"foreignKey:ContributorID" json:",omitempty"`
+}
+
+// ContributorAlias maps an email, login or, for commits carrying neither, an author name to a contributor.
+// Values are stored lower case.
+type ContributorAlias struct {
+	gorm.Model
+	ContributorID uint   `gorm:"not null;index"`
+	Kind          string `gorm:"not null;size:10;uniqueIndex:idx_kind_value"`
+	Value         string `gorm:"not null;size:255;uniqueIndex:idx_kind_value"`
+}
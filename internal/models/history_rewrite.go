@@ -0,0 +1,18 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// HistoryRewrite records a force-push that dropped commits from a branch
+type HistoryRewrite struct {
+	gorm.Model
+	RepoID     uint      `gorm:"not null;index"`
+	Branch     string    `gorm:"not null;size:255"`
+	OldHeadSHA string    `gorm:"not null;size:40"`
+	NewHeadSHA string    `gorm:"not null;size:40"`
+	DetectedAt time.Time `gorm:"not null;index"`
+	// LostSHAs holds the commits that are no longer reachable from the branch
+	LostSHAs []string `gorm:"serializer:json;type:TEXT"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// States of an issue
+const (
+	IssueOpen   = "open"
+	IssueClosed = "closed"
+)
+
+// Issue is an issue of a repository, pull requests are stored separately
+type Issue struct {
+	gorm.Model
+	RepoID    uint      `gorm:"not null;uniqueIndex:idx_repo_id_issue_number"`
+	Number    int       `gorm:"not null;uniqueIndex:idx_repo_id_issue_number"`
+	Title     string    `gorm:"type:TEXT"`
+	Author    string    `gorm:"size:255;index"`
+	State     string    `gorm:"size:20;index"`
+	Labels    []string  `gorm:"serializer:json;type:TEXT"`
+	Assignees []string  `gorm:"serializer:json;type:TEXT"`
+	OpenedAt  time.Time `gorm:"index"`
+	ClosedAt  *time.Time
+	// LastActivityAt is when the issue was last updated on its provider
+	LastActivityAt time.Time
+}
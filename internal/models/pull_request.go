@@ -0,0 +1,35 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// States of a pull request
+const (
+	PullRequestOpen   = "open"
+	PullRequestClosed = "closed"
+	PullRequestMerged = "merged"
+)
+
+// PullRequest is a pull or merge request of a repository along with its review lifecycle
+type PullRequest struct {
+	gorm.Model
+	RepoID         uint      `gorm:"not null;uniqueIndex:idx_repo_id_number"`
+	Number         int       `gorm:"not null;uniqueIndex:idx_repo_id_number"`
+	Title          string    `gorm:"type:TEXT"`
+	Author         string    `gorm:"size:255;index"`
+	State          string    `gorm:"size:20;index"`
+	BaseBranch     string    `gorm:"size:255"`
+	HeadBranch     string    `gorm:"size:255"`
+	MergeCommitSHA string    `gorm:"size:40"`
+	Additions      int       `gorm:"default:0"`
+	Deletions      int       `gorm:"default:0"`
+	ReviewCount    int       `gorm:"default:0"`
+	OpenedAt       time.Time `gorm:"index"`
+	FirstReviewAt  *time.Time
+	MergedAt       *time.Time
+	ClosedAt       *time.Time
+	// LastActivityAt is when the pull request was last updated on its provider
+	LastActivityAt time.Time
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// Tag is a git tag of a repository
+type Tag struct {
+	gorm.Model
+	RepoID    uint   `gorm:"not null;uniqueIndex:idx_repo_id_tag_name"`
+	Name      string `gorm:"not null;size:255;uniqueIndex:idx_repo_id_tag_name"`
+	TargetSHA string `gorm:"size:40;index"`
+}
+
+// Release is a release of a repository as published on its provider
+type Release struct {
+	gorm.Model
+	RepoID      uint       `gorm:"not null;uniqueIndex:idx_repo_id_release_tag"`
+	TagName     string     `gorm:"not null;size:255;uniqueIndex:idx_repo_id_release_tag"`
+	Name        string     `gorm:"size:255"`
+	TargetSHA   string     `gorm:"size:40"`
+	Draft       bool       `gorm:"default:false"`
+	Prerelease  bool       `gorm:"default:false"`
+	Notes       string     `gorm:"type:TEXT"`
+	URL         string     `gorm:"size:255"`
+	PublishedAt *time.Time `gorm:"index"`
+	// CommitsResolved is set once the commits first shipped in the release point to it
+	CommitsResolved bool `gorm:"default:false"`
+}
@@ -5,10 +5,14 @@ import (
 	"time"
 )
 
-// Repository represents a GitHub repository
+// Repository represents a repository of a source hosting service, identified by its provider, host and name
 type Repository struct {
 	gorm.Model
-	Name            string         `gorm:"unique;not null;size:255"`
+	Name     string `gorm:"not null;size:255;uniqueIndex:idx_repository_identity,priority:3"`
+	Provider string `gorm:"not null;size:20;default:github;uniqueIndex:idx_repository_identity,priority:1"`
+	// Host is stored canonical, see fetcher.CanonicalHost, so it is empty for the default host of the provider
+	Host            string         `gorm:"size:255;uniqueIndex:idx_repository_identity,priority:2"`
+	InstallationID  int64          `gorm:"default:0"`
 	Description     string         `gorm:"type:TEXT"`
 	URL             string         `gorm:"not null;size:255"`
 	Language        string         `gorm:"size:50"`
@@ -16,8 +20,33 @@ type Repository struct {
 	StarsCount      int            `gorm:"default:0"`
 	OpenIssuesCount int            `gorm:"default:0"`
 	WatchersCount   int            `gorm:"default:0"`
-	CreatedAt       time.Time      `gorm:"not null;type:DATETIME DEFAULT CURRENT_TIMESTAMP"`
-	UpdatedAt       time.Time      `gorm:"not null;type:DATETIME DEFAULT CURRENT_TIMESTAMP"`
+	DefaultBranch   string         `gorm:"size:255"`
+	CreatedAt       time.Time      `gorm:"not null"`
+	UpdatedAt       time.Time      `gorm:"not null"`
 	DeletedAt       gorm.DeletedAt `gorm:"index"`
 	Commits         []Commit       `gorm:"foreignKey:RepoID;references:ID"`
+
+	// TrackedBranches lists the branch names or glob patterns synced besides the default branch, "*" tracks every branch
+	TrackedBranches []string `gorm:"serializer:json;type:TEXT"`
+
+	// Sync state, used by the monitor to resume polling where it left off
+	LastCommitSHA  string `gorm:"size:40"`
+	LastCommitDate *time.Time
+	LastSyncedAt   *time.Time
+	LastSyncError  string `gorm:"type:TEXT"`
+	// SyncFrom is the date commits were first requested from, newly tracked branches are read from there
+	SyncFrom *time.Time
+	// MetadataSyncedAt is when the metadata and counters of the repository were last fetched
+	MetadataSyncedAt *time.Time
+	// ReleasesSyncedAt is when the tags and releases of the repository were last fetched
+	ReleasesSyncedAt *time.Time
+	// ReleasesResolvedUpTo is the newest commit ID covered by the last attribution of commits to releases,
+	// 0 attributes the whole history again
+	ReleasesResolvedUpTo uint `gorm:"not null;default:0"`
+	// PullRequestsUpdatedAt is the latest update of the stored pull requests, newer updates are fetched on the next poll
+	PullRequestsUpdatedAt *time.Time
+	// IssuesUpdatedAt is the latest update of the stored issues, newer updates are fetched on the next poll
+	IssuesUpdatedAt *time.Time
+	// LastWebhookAt is when the last webhook delivery for the repository arrived
+	LastWebhookAt *time.Time
 }
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// RepositorySnapshot records the counters of a repository at the time its metadata was fetched
+type RepositorySnapshot struct {
+	gorm.Model
+	RepoID     uint      `gorm:"not null;index:idx_repo_id_recorded_at"`
+	RecordedAt time.Time `gorm:"not null;index:idx_repo_id_recorded_at"`
+	Stars      int       `gorm:"default:0"`
+	Forks      int       `gorm:"default:0"`
+	Watchers   int       `gorm:"default:0"`
+	OpenIssues int       `gorm:"default:0"`
+}
@@ -6,74 +6,579 @@ import (
 	"errors"
 	"fmt"
 	"gmonitor/internal/fetcher"
+	"gmonitor/internal/models"
 	"gmonitor/internal/repository"
 	"gorm.io/gorm"
 	"log"
 	"time"
 )
 
+// webhookStaleAfter is how long without deliveries before a repository webhook is no longer trusted
+const webhookStaleAfter = 7 * 24 * time.Hour
+
+// pullRequestBatch is the most pull requests a poll completes with their size and reviews, a backfill of the pull
+// requests of a repository continues from the cursor on the next polls
+const pullRequestBatch = 50
+
 // Monitor is responsible for tracking repositories and fetching new commits
 type Monitor struct {
-	DB             *gorm.DB
-	GitHubToken    string
-	Interval       time.Duration
-	RepositoryRepo repository.RepositoryRepo
-	CommitRepo     repository.CommitRepo
-	Fetcher        fetcher.GitHubFetcher
+	DB              *gorm.DB
+	Interval        time.Duration
+	RepositoryRepo  repository.RepositoryRepo
+	CommitRepo      repository.CommitRepo
+	ReleaseRepo     repository.ReleaseRepo
+	PullRequestRepo repository.PullRequestRepo
+	IssueRepo       repository.IssueRepo
+	Providers       *fetcher.Registry
+	// ReconcileInterval is how often the commits of repositories with a working webhook are still polled, 0 disables the slowdown
+	ReconcileInterval time.Duration
+	// ReleaseInterval is how often tags and releases are fetched, 0 fetches them on every poll
+	ReleaseInterval time.Duration
+	// MetadataInterval is how often repository metadata is fetched and snapshotted, 0 fetches it on every poll
+	MetadataInterval time.Duration
 }
 
 // NewMonitor initializes a new Monitor instance
-func NewMonitor(db *gorm.DB, githubToken string, interval time.Duration, repo repository.RepositoryRepo, commitRepo repository.CommitRepo, fetcher fetcher.GitHubFetcher) *Monitor {
+func NewMonitor(
+	db *gorm.DB,
+	interval time.Duration,
+	repo repository.RepositoryRepo,
+	commitRepo repository.CommitRepo,
+	releaseRepo repository.ReleaseRepo,
+	pullRequestRepo repository.PullRequestRepo,
+	issueRepo repository.IssueRepo,
+	providers *fetcher.Registry,
+) *Monitor {
 	return &Monitor{
-		DB:             db,
-		GitHubToken:    githubToken,
-		Interval:       interval,
-		RepositoryRepo: repo,
-		CommitRepo:     commitRepo,
-		Fetcher:        fetcher,
+		DB:              db,
+		Interval:        interval,
+		RepositoryRepo:  repo,
+		CommitRepo:      commitRepo,
+		ReleaseRepo:     releaseRepo,
+		PullRequestRepo: pullRequestRepo,
+		IssueRepo:       issueRepo,
+		Providers:       providers,
 	}
 }
 
-// FetchNewCommits retrieves new commits for a given repository and updates the database
-func (m *Monitor) FetchNewCommits(repoName string, ctx context.Context) error {
-	log.Printf("Checking for new commits in repository: %s", repoName)
+// DueForCommitPoll reports whether the commits of a repository should be polled now.
+// Pushes to repositories with a working webhook are delivered as they happen, so their commits are only polled every
+// ReconcileInterval to catch missed deliveries. Webhooks do not cover the other syncs, which keep their own intervals.
+func (m *Monitor) DueForCommitPoll(repo *models.Repository, now time.Time) bool {
+	if m.ReconcileInterval <= 0 || repo.LastWebhookAt == nil || repo.LastSyncedAt == nil {
+		return true
+	}
+	if now.Sub(*repo.LastWebhookAt) > webhookStaleAfter {
+		return true
+	}
+	return now.Sub(*repo.LastSyncedAt) >= m.ReconcileInterval
+}
 
-	// Create a context with timeout for GitHub API calls
-	githubCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+// PausedUntil returns the time until which the provider of a repository is held back by a rate limit
+func (m *Monitor) PausedUntil(repo *models.Repository) time.Time {
+	provider, err := m.Providers.Get(repo.Provider, repo.Host)
+	if err != nil {
+		return time.Time{}
+	}
+	if limited, ok := provider.(fetcher.RateLimited); ok {
+		return limited.PausedUntil()
+	}
+	return time.Time{}
+}
+
+// FetchNewCommits retrieves new commits for a given repository and updates the database
+func (m *Monitor) FetchNewCommits(repoID uint, ctx context.Context) error {
+	// Create a context with timeout for provider API calls
+	providerCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
 	// Get repository details
-	repo, err := m.RepositoryRepo.GetRepository(githubCtx, repoName)
+	repo, err := m.RepositoryRepo.GetRepositoryByID(providerCtx, repoID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			log.Printf("RepositoryRepo %s not found in the database. Skipping.", repoName)
+			log.Printf("Repository %d not found in the database. Skipping.", repoID)
 			return nil
 		}
 		return fmt.Errorf("failed to get repository: %v\n", err)
 	}
+	log.Printf("Checking for new commits in repository: %s", repo.Name)
+
+	provider, err := m.Providers.Get(repo.Provider, repo.Host)
+	if err != nil {
+		return m.syncFailed(ctx, repo, err)
+	}
 
-	//Get the last fetched commit
-	commitDate, err := m.CommitRepo.GetLatestCommitDate(ctx)
+	token, err := m.token(ctx, repo)
 	if err != nil {
-		return fmt.Errorf("failed to get latest commit: %v\n", err)
+		return m.syncFailed(ctx, repo, fmt.Errorf("failed to get access token: %v", err))
+	}
+
+	// Repositories tracking more than the default branch keep a cursor per branch
+	if branches, ok := provider.(fetcher.BranchFetcher); ok && len(repo.TrackedBranches) > 0 {
+		return m.fetchBranches(ctx, repo, provider, branches, token)
 	}
 
-	// Fetch latest commits from GitHub API and ensure to fetch commits from the next second to avoid same fetching commits again
-	commits, err := m.Fetcher.FetchCommits(repoName, m.GitHubToken, commitDate.Add(time.Hour).Format(time.RFC3339))
+	head, err := m.detectRewrite(ctx, repo, provider, token, repo.DefaultBranch)
 	if err != nil {
-		return fmt.Errorf("failed to fetch commits: %v\n", err)
+		return m.syncFailed(ctx, repo, err)
+	}
+
+	// Resume from the repository's own sync cursor
+	since := m.resumeFrom(ctx, repo)
+
+	// Fetch latest commits from the provider API
+	commits, err := provider.FetchCommits(repo.Name, token, since.Format(time.RFC3339), "")
+	if err != nil {
+		return m.syncFailed(ctx, repo, fmt.Errorf("failed to fetch commits: %v", err))
+	}
+
+	// Webhook deliveries may already have stored some of the commits, without their parents and verification
+	newCommits, err := m.CommitRepo.FilterNewCommits(ctx, repo.ID, commits)
+	if err != nil {
+		return m.syncFailed(ctx, repo, err)
+	}
+	if _, err := m.CommitRepo.CompleteCommits(ctx, repo.ID, commits); err != nil {
+		return m.syncFailed(ctx, repo, err)
+	}
+	if err := m.Providers.AttachCommitFiles(provider, repo.Name, token, newCommits); err != nil {
+		return m.syncFailed(ctx, repo, err)
 	}
 
 	// Save new commits to database
-	if len(commits) > 0 {
-		err := m.CommitRepo.SaveCommits(ctx, repo.ID, commits)
+	if len(newCommits) > 0 {
+		err := m.CommitRepo.SaveCommits(ctx, repo.ID, newCommits)
 		if err != nil {
-			return fmt.Errorf("failed to save commits: %v\n\n", err)
+			return m.syncFailed(ctx, repo, fmt.Errorf("failed to save commits: %v", err))
 		}
-		log.Printf("Added %d new commits for repository %s\n\n", len(commits), repoName)
+		log.Printf("Added %d new commits for repository %s\n\n", len(newCommits), repo.Name)
 	} else {
-		log.Printf("No new commits found for repository %s\n\n", repoName)
+		log.Printf("No new commits found for repository %s\n\n", repo.Name)
+	}
+
+	// Move the head and the cursor forward only once the commits are safely stored
+	err = m.RepositoryRepo.InTransaction(ctx, func(repos *repository.RepositoryRepo, commitRepo *repository.CommitRepo) error {
+		if err := commitRepo.RecordBranch(ctx, repo.ID, repo.DefaultBranch, commits); err != nil {
+			return err
+		}
+		if err := head.record(ctx, repos, commitRepo, repo, repo.DefaultBranch); err != nil {
+			return err
+		}
+		return repos.MarkSynced(ctx, repo.ID, commits, time.Now())
+	})
+	if err != nil {
+		return m.syncFailed(ctx, repo, fmt.Errorf("failed to update sync state: %v", err))
+	}
+
+	return nil
+}
+
+// fetchBranches syncs every tracked branch of a repository from its own cursor
+func (m *Monitor) fetchBranches(
+	ctx context.Context,
+	repo *models.Repository,
+	provider fetcher.Provider,
+	branches fetcher.BranchFetcher,
+	token string,
+) error {
+	names, err := branches.FetchBranches(repo.Name, token)
+	if err != nil {
+		return m.syncFailed(ctx, repo, fmt.Errorf("failed to fetch branches: %v", err))
+	}
+
+	// The repository cursor keeps following the default branch only
+	var defaultCommits []models.Commit
+	for _, name := range fetcher.MatchBranches(repo.TrackedBranches, repo.DefaultBranch, names) {
+		head, err := m.detectRewrite(ctx, repo, provider, token, name)
+		if err != nil {
+			return m.syncFailed(ctx, repo, err)
+		}
+
+		branch, err := m.RepositoryRepo.GetBranch(ctx, repo.ID, name)
+		if err != nil {
+			return m.syncFailed(ctx, repo, err)
+		}
+		since := m.branchResumeFrom(ctx, repo, branch)
+
+		commits, err := branches.FetchBranchCommits(repo.Name, token, name, since.Format(time.RFC3339), "")
+		if err != nil {
+			return m.syncFailed(ctx, repo, fmt.Errorf("failed to fetch commits of branch %s: %v", name, err))
+		}
+
+		// Commits merged from other branches are already stored and only gain the branch
+		newCommits, err := m.CommitRepo.FilterNewCommits(ctx, repo.ID, commits)
+		if err != nil {
+			return m.syncFailed(ctx, repo, err)
+		}
+		if _, err := m.CommitRepo.CompleteCommits(ctx, repo.ID, commits); err != nil {
+			return m.syncFailed(ctx, repo, err)
+		}
+		if err := m.Providers.AttachCommitFiles(provider, repo.Name, token, newCommits); err != nil {
+			return m.syncFailed(ctx, repo, err)
+		}
+		if err := m.CommitRepo.SaveCommits(ctx, repo.ID, newCommits); err != nil {
+			return m.syncFailed(ctx, repo, fmt.Errorf("failed to save commits: %v", err))
+		}
+		err = m.RepositoryRepo.InTransaction(ctx, func(repos *repository.RepositoryRepo, commitRepo *repository.CommitRepo) error {
+			if err := commitRepo.RecordBranch(ctx, repo.ID, name, commits); err != nil {
+				return err
+			}
+			if err := head.record(ctx, repos, commitRepo, repo, name); err != nil {
+				return err
+			}
+			return repos.MarkBranchSynced(ctx, repo.ID, name, commits, time.Now())
+		})
+		if err != nil {
+			return m.syncFailed(ctx, repo, fmt.Errorf("failed to update sync state of branch %s: %v", name, err))
+		}
+
+		if len(newCommits) > 0 {
+			log.Printf("Added %d new commits for repository %s on branch %s", len(newCommits), repo.Name, name)
+		}
+		if name == repo.DefaultBranch {
+			defaultCommits = commits
+		}
+	}
+
+	if err := m.RepositoryRepo.MarkSynced(ctx, repo.ID, defaultCommits, time.Now()); err != nil {
+		return fmt.Errorf("failed to update sync state: %v\n", err)
+	}
+
+	return nil
+}
+
+// FetchMetadata refreshes the metadata of a repository once every MetadataInterval
+// and records a snapshot of its stars, forks, watchers and open issues
+func (m *Monitor) FetchMetadata(repoID uint, ctx context.Context) error {
+	repo, err := m.RepositoryRepo.GetRepositoryByID(ctx, repoID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return fmt.Errorf("failed to get repository: %v", err)
+	}
+
+	// Local clones have no counters to record
+	if repo.Provider == fetcher.ProviderLocal {
+		return nil
+	}
+	if repo.MetadataSyncedAt != nil && time.Since(*repo.MetadataSyncedAt) < m.MetadataInterval {
+		return nil
+	}
+
+	provider, err := m.Providers.Get(repo.Provider, repo.Host)
+	if err != nil {
+		return m.syncFailed(ctx, repo, err)
+	}
+
+	token, err := m.token(ctx, repo)
+	if err != nil {
+		return m.syncFailed(ctx, repo, fmt.Errorf("failed to get access token: %v", err))
 	}
 
+	fetched, err := provider.FetchRepository(repo.Name, token)
+	if err != nil {
+		return m.syncFailed(ctx, repo, fmt.Errorf("failed to fetch repository: %v", err))
+	}
+	if fetched.Name == "" {
+		fetched.Name = repo.Name
+	}
+
+	if err := m.RepositoryRepo.RecordMetadata(ctx, repo.ID, fetched, time.Now()); err != nil {
+		return m.syncFailed(ctx, repo, err)
+	}
+	if fetched.Name != repo.Name {
+		log.Printf("Repository %s was renamed to %s", repo.Name, fetched.Name)
+	}
+	return nil
+}
+
+// FetchReleases refreshes the tags and releases of a repository once every ReleaseInterval. The worker runs it after
+// every commit sync, so commits stored since the last call are pointed to the release that first shipped them.
+func (m *Monitor) FetchReleases(repoID uint, ctx context.Context) error {
+	repo, err := m.RepositoryRepo.GetRepositoryByID(ctx, repoID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return fmt.Errorf("failed to get repository: %v", err)
+	}
+
+	if repo.ReleasesSyncedAt == nil || time.Since(*repo.ReleasesSyncedAt) >= m.ReleaseInterval {
+		if err := m.syncReleases(ctx, repo); err != nil {
+			return m.syncFailed(ctx, repo, err)
+		}
+	}
+
+	changed, err := m.ReleaseRepo.ResolveReleaseCommits(ctx, repo.ID)
+	if err != nil {
+		return m.syncFailed(ctx, repo, err)
+	}
+	if changed > 0 {
+		log.Printf("Attributed %d commits of %s to releases", changed, repo.Name)
+	}
 	return nil
 }
+
+// syncReleases stores the current tags and releases of a repository
+func (m *Monitor) syncReleases(ctx context.Context, repo *models.Repository) error {
+	provider, err := m.Providers.Get(repo.Provider, repo.Host)
+	if err != nil {
+		return err
+	}
+	releaseFetcher, ok := provider.(fetcher.ReleaseFetcher)
+	if !ok {
+		return nil
+	}
+
+	token, err := m.token(ctx, repo)
+	if err != nil {
+		return fmt.Errorf("failed to get access token: %v", err)
+	}
+
+	tags, err := releaseFetcher.FetchTags(repo.Name, token)
+	if err != nil {
+		return fmt.Errorf("failed to fetch tags: %v", err)
+	}
+	releases, err := releaseFetcher.FetchReleases(repo.Name, token)
+	if err != nil {
+		return fmt.Errorf("failed to fetch releases: %v", err)
+	}
+
+	// Releases only name their tag, the commit comes from the tag
+	targets := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		targets[tag.Name] = tag.TargetSHA
+	}
+	for i := range releases {
+		if releases[i].TargetSHA == "" {
+			releases[i].TargetSHA = targets[releases[i].TagName]
+		}
+	}
+
+	if err := m.ReleaseRepo.SaveTags(ctx, repo.ID, tags); err != nil {
+		return err
+	}
+	if err := m.ReleaseRepo.SaveReleases(ctx, repo.ID, releases); err != nil {
+		return err
+	}
+	return m.ReleaseRepo.MarkReleasesSynced(ctx, repo.ID, time.Now())
+}
+
+// FetchPullRequests stores the pull requests of a repository updated since the previous poll.
+// The first polls of a repository backfill every pull request, a batch at a time.
+func (m *Monitor) FetchPullRequests(repoID uint, ctx context.Context) error {
+	repo, err := m.RepositoryRepo.GetRepositoryByID(ctx, repoID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return fmt.Errorf("failed to get repository: %v", err)
+	}
+
+	provider, err := m.Providers.Get(repo.Provider, repo.Host)
+	if err != nil {
+		return m.syncFailed(ctx, repo, err)
+	}
+	pullFetcher, ok := provider.(fetcher.PullRequestFetcher)
+	if !ok {
+		return nil
+	}
+
+	token, err := m.token(ctx, repo)
+	if err != nil {
+		return m.syncFailed(ctx, repo, fmt.Errorf("failed to get access token: %v", err))
+	}
+
+	pulls, err := pullFetcher.FetchPullRequests(repo.Name, token, m.pullRequestsFrom(repo), pullRequestBatch)
+	if err != nil {
+		return m.syncFailed(ctx, repo, fmt.Errorf("failed to fetch pull requests: %v", err))
+	}
+	if err := m.PullRequestRepo.SavePullRequests(ctx, repo.ID, pulls); err != nil {
+		return m.syncFailed(ctx, repo, err)
+	}
+	if len(pulls) > 0 {
+		log.Printf("Updated %d pull requests of repository %s", len(pulls), repo.Name)
+	}
+
+	// Move the cursor forward only once the pull requests are safely stored
+	return m.PullRequestRepo.MarkPullRequestsSynced(ctx, repo, pulls)
+}
+
+// pullRequestsFrom returns the point in time from which updated pull requests of a repository should be fetched.
+// Repositories without a cursor yet start with every pull request.
+func (m *Monitor) pullRequestsFrom(repo *models.Repository) time.Time {
+	if repo.PullRequestsUpdatedAt != nil {
+		return *repo.PullRequestsUpdatedAt
+	}
+	return time.Time{}
+}
+
+// FetchIssues stores the issues of a repository updated since the previous poll.
+// The first poll of a repository fetches every issue, so the open issue count starts out right.
+func (m *Monitor) FetchIssues(repoID uint, ctx context.Context) error {
+	repo, err := m.RepositoryRepo.GetRepositoryByID(ctx, repoID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return fmt.Errorf("failed to get repository: %v", err)
+	}
+
+	provider, err := m.Providers.Get(repo.Provider, repo.Host)
+	if err != nil {
+		return m.syncFailed(ctx, repo, err)
+	}
+	issueFetcher, ok := provider.(fetcher.IssueFetcher)
+	if !ok {
+		return nil
+	}
+
+	token, err := m.token(ctx, repo)
+	if err != nil {
+		return m.syncFailed(ctx, repo, fmt.Errorf("failed to get access token: %v", err))
+	}
+
+	var since time.Time
+	if repo.IssuesUpdatedAt != nil {
+		since = *repo.IssuesUpdatedAt
+	}
+	issues, err := issueFetcher.FetchIssues(repo.Name, token, since)
+	if err != nil {
+		return m.syncFailed(ctx, repo, fmt.Errorf("failed to fetch issues: %v", err))
+	}
+	if err := m.IssueRepo.SaveIssues(ctx, repo.ID, issues); err != nil {
+		return m.syncFailed(ctx, repo, err)
+	}
+	if len(issues) > 0 {
+		log.Printf("Updated %d issues of repository %s", len(issues), repo.Name)
+	}
+
+	// Move the cursor forward only once the issues are safely stored
+	return m.IssueRepo.MarkIssuesSynced(ctx, repo, issues)
+}
+
+// token returns the access token of a repository, persisting the app installation it was mapped to
+func (m *Monitor) token(ctx context.Context, repo *models.Repository) (string, error) {
+	installationID := repo.InstallationID
+	token, err := m.Providers.Token(repo)
+	if err != nil {
+		return "", err
+	}
+
+	if repo.InstallationID != installationID {
+		if err := m.RepositoryRepo.SetInstallationID(ctx, repo.ID, repo.InstallationID); err != nil {
+			log.Printf("Failed to record installation of %s: %v", repo.Name, err)
+		}
+	}
+	return token, nil
+}
+
+// resumeFrom returns the point in time from which commits of a repository should be fetched.
+// The cursor itself is included, so commits made in the same second as the last seen one are not skipped. The last
+// seen commits are fetched again and dropped by FilterNewCommits.
+func (m *Monitor) resumeFrom(ctx context.Context, repo *models.Repository) time.Time {
+	if repo.LastCommitDate != nil {
+		return *repo.LastCommitDate
+	}
+
+	// Repositories without a cursor yet fall back to their own latest stored commit
+	latest, err := m.CommitRepo.GetLatestCommitDate(ctx, repo.ID)
+	if err == nil && !latest.IsZero() {
+		return latest
+	}
+
+	// A failed import of the history the repository was added with is retried from its start
+	if repo.SyncFrom != nil {
+		return *repo.SyncFrom
+	}
+	if repo.LastSyncedAt != nil {
+		return *repo.LastSyncedAt
+	}
+	return time.Now().Add(-m.Interval)
+}
+
+// branchHead is the current head of a branch along with the history rewrite that led to it
+type branchHead struct {
+	SHA     string
+	Rewrite *models.HistoryRewrite
+}
+
+// detectRewrite compares the stored head of a branch with its current head and returns the head to record once the
+// commits up to it are stored, along with the commits a force-push dropped. A nil head leaves the stored one as is.
+func (m *Monitor) detectRewrite(ctx context.Context, repo *models.Repository, provider fetcher.Provider, token, name string) (*branchHead, error) {
+	history, ok := provider.(fetcher.HistoryFetcher)
+	if !ok || name == "" {
+		return nil, nil
+	}
+
+	sha, err := history.FetchBranchHead(repo.Name, token, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch head of branch %s: %v", name, err)
+	}
+	if sha == "" {
+		return nil, nil
+	}
+
+	branch, err := m.RepositoryRepo.GetBranch(ctx, repo.ID, name)
+	if err != nil {
+		return nil, err
+	}
+	if branch.HeadSHA == sha {
+		return nil, nil
+	}
+
+	head := &branchHead{SHA: sha}
+	if branch.HeadSHA != "" {
+		lost, err := history.FetchUnreachable(repo.Name, token, branch.HeadSHA, sha)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compare heads of branch %s: %v", name, err)
+		}
+		if len(lost) > 0 {
+			head.Rewrite = &models.HistoryRewrite{
+				RepoID:     repo.ID,
+				Branch:     name,
+				OldHeadSHA: branch.HeadSHA,
+				NewHeadSHA: sha,
+				DetectedAt: time.Now(),
+				LostSHAs:   lost,
+			}
+		}
+	}
+	return head, nil
+}
+
+// record stores the history rewrite and moves the stored head of a branch through the given stores.
+// Until then a failed sync keeps comparing from the old head, so the rewrite is neither lost nor recorded twice.
+func (h *branchHead) record(ctx context.Context, repos *repository.RepositoryRepo, commits *repository.CommitRepo, repo *models.Repository, name string) error {
+	if h == nil {
+		return nil
+	}
+	if h.Rewrite != nil {
+		if err := commits.RecordHistoryRewrite(ctx, h.Rewrite); err != nil {
+			return err
+		}
+		log.Printf("History of %s rewritten on branch %s, %d commits dropped", repo.Name, name, len(h.Rewrite.LostSHAs))
+	}
+	return repos.SetBranchHead(ctx, repo.ID, name, h.SHA)
+}
+
+// branchResumeFrom returns the point in time from which commits of a branch should be fetched.
+// Like the repository cursor, the branch cursor is included. Branches without a cursor yet are read from the date the
+// repository was added with.
+func (m *Monitor) branchResumeFrom(ctx context.Context, repo *models.Repository, branch *models.Branch) time.Time {
+	if branch.LastCommitDate != nil {
+		return *branch.LastCommitDate
+	}
+	if repo.SyncFrom != nil {
+		return *repo.SyncFrom
+	}
+	return m.resumeFrom(ctx, repo)
+}
+
+// syncFailed records a failed poll on the repository and returns the original error
+func (m *Monitor) syncFailed(ctx context.Context, repo *models.Repository, syncErr error) error {
+	if err := m.RepositoryRepo.MarkSyncFailed(ctx, repo.ID, syncErr); err != nil {
+		log.Printf("Failed to record sync error for %s: %v", repo.Name, err)
+	}
+	return syncErr
+}
@@ -0,0 +1,127 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"github.com/glebarez/sqlite"
+	"gmonitor/internal/fetcher"
+	"gmonitor/internal/models"
+	"gmonitor/internal/repository"
+	"gorm.io/gorm"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func setupMonitorTest(t *testing.T, github *fetcher.GitHubFetcher) (*Monitor, *gorm.DB) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "gmonitor.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to connect test db: %v", err)
+	}
+	if err := db.AutoMigrate(
+		&models.Repository{}, &models.Commit{}, &models.CommitFile{}, &models.Branch{}, &models.CommitBranch{},
+		&models.Contributor{}, &models.ContributorAlias{}, &models.HistoryRewrite{}, &models.Tag{}, &models.Release{},
+	); err != nil {
+		t.Fatalf("failed to migrate schema: %v", err)
+	}
+
+	m := NewMonitor(db, time.Minute,
+		*repository.NewRepositoryRepo(db), *repository.NewCommitRepo(db), *repository.NewReleaseRepo(db),
+		*repository.NewPullRequestRepo(db), *repository.NewIssueRepo(db), fetcher.NewRegistry(github))
+	return m, db
+}
+
+// commitListing serves the commits committed at or after the since parameter, one per page, newest first
+func commitListing(t *testing.T, dates []time.Time) func(string, string, http.Header) (*http.Response, error) {
+	return func(rawURL, token string, header http.Header) (*http.Response, error) {
+		parsed, _ := url.Parse(rawURL)
+		since, err := time.Parse(time.RFC3339, parsed.Query().Get("since"))
+		if err != nil {
+			t.Fatalf("unexpected since in %s: %v", rawURL, err)
+		}
+
+		var listed []int
+		for i := len(dates) - 1; i >= 0; i-- {
+			if !dates[i].Before(since) {
+				listed = append(listed, i)
+			}
+		}
+		page, _ := strconv.Atoi(parsed.Query().Get("page"))
+		if page == 0 {
+			page = 1
+		}
+
+		pageURL := func(n int) string {
+			query := parsed.Query()
+			query.Set("page", strconv.Itoa(n))
+			return "https://api.github.com/repos/octo/widgets/commits?" + query.Encode()
+		}
+		links := []string{fmt.Sprintf(`<%s>; rel="last"`, pageURL(len(listed)))}
+		if page > 1 {
+			links = append(links, fmt.Sprintf(`<%s>; rel="prev"`, pageURL(page-1)))
+		}
+		if page < len(listed) {
+			links = append(links, fmt.Sprintf(`<%s>; rel="next"`, pageURL(page+1)))
+		}
+
+		body := "[]"
+		if page <= len(listed) {
+			i := listed[page-1]
+			body = fmt.Sprintf(`[{"sha": "c%d", "commit": {"message": "m", "author": {"name": "Jane", "date": %q}}}]`,
+				i, dates[i].Format(time.RFC3339))
+		}
+		resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}
+		resp.Header.Set("Link", strings.Join(links, ", "))
+		return resp, nil
+	}
+}
+
+func TestFetchNewCommits_PageCapLosesNothing(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	dates := []time.Time{start.Add(time.Hour), start.Add(2 * time.Hour), start.Add(3 * time.Hour)}
+	github := &fetcher.GitHubFetcher{MaxPages: 2}
+	github.Request = commitListing(t, dates)
+	m, db := setupMonitorTest(t, github)
+
+	repo := models.Repository{Name: "octo/widgets", URL: "https://github.com/octo/widgets", LastCommitDate: &start}
+	db.Create(&repo)
+
+	for poll := 1; poll <= 2; poll++ {
+		if err := m.FetchNewCommits(repo.ID, context.Background()); err != nil {
+			t.Fatalf("poll %d failed: %v", poll, err)
+		}
+	}
+
+	var hashes []string
+	db.Model(&models.Commit{}).Order("commit_date").Pluck("commit_hash", &hashes)
+	if strings.Join(hashes, ",") != "c0,c1,c2" {
+		t.Errorf("expected every commit after two capped polls, got: %v", hashes)
+	}
+}
+
+func TestFetchNewCommits_RetriesFailedImportFromSyncFrom(t *testing.T) {
+	syncFrom := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	github := &fetcher.GitHubFetcher{}
+	github.Request = commitListing(t, []time.Time{syncFrom.Add(time.Hour)})
+	m, db := setupMonitorTest(t, github)
+
+	// The background import failed, leaving neither a cursor nor commits
+	failedAt := time.Now()
+	repo := models.Repository{Name: "octo/widgets", URL: "https://github.com/octo/widgets", SyncFrom: &syncFrom, LastSyncedAt: &failedAt}
+	db.Create(&repo)
+
+	if err := m.FetchNewCommits(repo.ID, context.Background()); err != nil {
+		t.Fatalf("poll failed: %v", err)
+	}
+
+	var count int64
+	db.Model(&models.Commit{}).Count(&count)
+	if count != 1 {
+		t.Errorf("expected the requested history to be imported by the poll, got %d commits", count)
+	}
+}
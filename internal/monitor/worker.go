@@ -26,6 +26,9 @@ func (w *Worker) Start(ctx context.Context) {
 	ticker := time.NewTicker(w.Monitor.Interval)
 	defer ticker.Stop()
 
+	// resume fires once a rate limit pause is over, so skipped repositories are polled as soon as possible
+	var resume <-chan time.Time
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -33,30 +36,75 @@ func (w *Worker) Start(ctx context.Context) {
 			return
 
 		case <-ticker.C:
-			log.Println("Starting repository monitoring cycle...")
-			if err := w.processRepositories(ctx); err != nil {
-				log.Printf("Worker: Error processing repositories: %v", err)
+			if next := w.runCycle(ctx); next != nil {
+				resume = next
 			}
+
+		case <-resume:
+			resume = w.runCycle(ctx)
 		}
 	}
 }
 
-// processRepositories handles repository processing with error handling
-func (w *Worker) processRepositories(ctx context.Context) error {
+// runCycle processes all repositories once and returns a timer for the repositories postponed by a rate limit
+func (w *Worker) runCycle(ctx context.Context) <-chan time.Time {
+	log.Println("Starting repository monitoring cycle...")
+	resumeAt, err := w.processRepositories(ctx)
+	if err != nil {
+		log.Printf("Worker: Error processing repositories: %v", err)
+	}
+
+	if resumeAt.IsZero() {
+		return nil
+	}
+	log.Printf("Worker: Rate limit hit, rescheduling postponed repositories to %s", resumeAt.Format(time.RFC3339))
+	return time.After(time.Until(resumeAt))
+}
+
+// processRepositories handles repository processing with error handling.
+// It returns the earliest time at which repositories skipped because of a rate limit can be retried.
+func (w *Worker) processRepositories(ctx context.Context) (time.Time, error) {
 	repos, err := w.Repository.GetAllRepositories(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to fetch repositories: %v", err)
+		return time.Time{}, fmt.Errorf("failed to fetch repositories: %v", err)
 	}
 
 	var wg sync.WaitGroup
+	var resumeAt time.Time
 	errChan := make(chan error, len(repos))
 
+	now := time.Now()
 	for _, repo := range repos {
+		if until := w.Monitor.PausedUntil(repo); time.Now().Before(until) {
+			if resumeAt.IsZero() || until.Before(resumeAt) {
+				resumeAt = until
+			}
+			continue
+		}
+
+		pollCommits := w.Monitor.DueForCommitPoll(repo, now)
+
 		wg.Add(1)
 		go func(repo models.Repository) {
 			defer wg.Done()
-			if err := w.Monitor.FetchNewCommits(repo.Name, ctx); err != nil {
-				errChan <- fmt.Errorf("error updating commits for %s: %v", repo.Name, err)
+			// Syncs look the repository up by ID, so a rename recorded with the metadata applies to the syncs following it
+			if err := w.Monitor.FetchMetadata(repo.ID, ctx); err != nil {
+				errChan <- fmt.Errorf("error updating metadata for %s: %v", repo.Name, err)
+			}
+			if pollCommits {
+				if err := w.Monitor.FetchNewCommits(repo.ID, ctx); err != nil {
+					errChan <- fmt.Errorf("error updating commits for %s: %v", repo.Name, err)
+					return
+				}
+			}
+			if err := w.Monitor.FetchReleases(repo.ID, ctx); err != nil {
+				errChan <- fmt.Errorf("error updating releases for %s: %v", repo.Name, err)
+			}
+			if err := w.Monitor.FetchPullRequests(repo.ID, ctx); err != nil {
+				errChan <- fmt.Errorf("error updating pull requests for %s: %v", repo.Name, err)
+			}
+			if err := w.Monitor.FetchIssues(repo.ID, ctx); err != nil {
+				errChan <- fmt.Errorf("error updating issues for %s: %v", repo.Name, err)
 			}
 		}(*repo)
 	}
@@ -77,5 +125,5 @@ func (w *Worker) processRepositories(ctx context.Context) error {
 		}
 	}
 
-	return firstError
+	return resumeAt, firstError
 }
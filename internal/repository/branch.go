@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gmonitor/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// GetBranch retrieves the sync state of a branch, a branch that was never synced has no cursor yet
+func (r *RepositoryRepo) GetBranch(ctx context.Context, repoID uint, name string) (*models.Branch, error) {
+	var branch models.Branch
+
+	err := r.db.WithContext(ctx).
+		Where("repo_id = ? AND name = ?", repoID, name).
+		First(&branch).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &models.Branch{RepoID: repoID, Name: name}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get branch %q: %w", name, err)
+	}
+
+	return &branch, nil
+}
+
+// GetBranches retrieves the sync state of every branch of a repository seen so far
+func (r *RepositoryRepo) GetBranches(ctx context.Context, repoID uint) ([]models.Branch, error) {
+	var branches []models.Branch
+
+	err := r.db.WithContext(ctx).
+		Where("repo_id = ?", repoID).
+		Order("name").
+		Find(&branches).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get branches: %w", err)
+	}
+	return branches, nil
+}
+
+// MarkBranchSynced records a successful poll of a branch and moves its cursor forward to the newest of the saved commits
+func (r *RepositoryRepo) MarkBranchSynced(ctx context.Context, repoID uint, name string, commits []models.Commit, syncedAt time.Time) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		branch := models.Branch{RepoID: repoID, Name: name}
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&branch).Error
+		if err != nil {
+			return fmt.Errorf("failed to create branch %q: %w", name, err)
+		}
+		if err := tx.Where("repo_id = ? AND name = ?", repoID, name).First(&branch).Error; err != nil {
+			return fmt.Errorf("failed to load branch %q: %w", name, err)
+		}
+
+		updates := map[string]interface{}{"last_synced_at": syncedAt}
+
+		// Only ever move the cursor forward
+		for _, commit := range commits {
+			if branch.LastCommitDate != nil && !commit.CommitDate.After(*branch.LastCommitDate) {
+				continue
+			}
+			commitDate := commit.CommitDate
+			branch.LastCommitDate = &commitDate
+			updates["last_commit_sha"] = commit.CommitHash
+			updates["last_commit_date"] = commitDate
+		}
+
+		if err := tx.Model(&models.Branch{}).Where("id = ?", branch.ID).UpdateColumns(updates).Error; err != nil {
+			return fmt.Errorf("failed to update branch %q: %w", name, err)
+		}
+		return nil
+	})
+}
+
+// SetBranchHead records the commit a branch currently points to
+func (r *RepositoryRepo) SetBranchHead(ctx context.Context, repoID uint, name, head string) error {
+	branch := models.Branch{RepoID: repoID, Name: name, HeadSHA: head}
+
+	err := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "repo_id"}, {Name: "name"}},
+			DoUpdates: clause.AssignmentColumns([]string{"head_sha", "updated_at"}),
+		}).
+		Create(&branch).Error
+
+	if err != nil {
+		return fmt.Errorf("failed to set head of branch %q: %w", name, err)
+	}
+	return nil
+}
+
+// DeleteBranch removes the sync state of a branch deleted on the provider, a branch created again is read like a new one.
+// The commits seen on the branch keep recording it.
+func (r *RepositoryRepo) DeleteBranch(ctx context.Context, repoID uint, name string) error {
+	err := r.db.WithContext(ctx).
+		Unscoped().
+		Where("repo_id = ? AND name = ?", repoID, name).
+		Delete(&models.Branch{}).Error
+
+	if err != nil {
+		return fmt.Errorf("failed to delete branch %q: %w", name, err)
+	}
+	return nil
+}
+
+// SetTrackedBranches replaces the branch names and patterns tracked besides the default branch of a repository
+func (r *RepositoryRepo) SetTrackedBranches(ctx context.Context, repoID uint, patterns []string) error {
+	repo := models.Repository{}
+	repo.ID = repoID
+
+	// Updating through the struct applies the JSON serializer of the column
+	err := r.db.WithContext(ctx).
+		Model(&repo).
+		Select("tracked_branches").
+		Updates(&models.Repository{TrackedBranches: patterns}).Error
+
+	if err != nil {
+		return fmt.Errorf("failed to set tracked branches: %w", err)
+	}
+	return nil
+}
+
+// RecordBranch records that the given commits of a repository were seen on a branch
+func (r *CommitRepo) RecordBranch(ctx context.Context, repoID uint, branch string, commits []models.Commit) error {
+	if branch == "" || len(commits) == 0 {
+		return nil
+	}
+
+	for start := 0; start < len(commits); start += hashLookupBatch {
+		end := min(start+hashLookupBatch, len(commits))
+		hashes := make([]string, 0, end-start)
+		for _, commit := range commits[start:end] {
+			hashes = append(hashes, commit.CommitHash)
+		}
+
+		var ids []uint
+		err := r.db.WithContext(ctx).
+			Model(&models.Commit{}).
+			Where("repo_id = ? AND commit_hash IN ?", repoID, hashes).
+			Pluck("id", &ids).Error
+
+		if err != nil {
+			return fmt.Errorf("failed to look up commits of branch %q: %w", branch, err)
+		}
+		if len(ids) == 0 {
+			continue
+		}
+
+		rows := make([]models.CommitBranch, 0, len(ids))
+		for _, id := range ids {
+			rows = append(rows, models.CommitBranch{CommitID: id, Branch: branch})
+		}
+		err = r.db.WithContext(ctx).
+			Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&rows).Error
+
+		if err != nil {
+			return fmt.Errorf("failed to record commits of branch %q: %w", branch, err)
+		}
+
+		// Commits pushed back after a history rewrite are no longer orphaned
+		err = r.db.WithContext(ctx).
+			Model(&models.Commit{}).
+			Where("id IN ? AND orphaned = ?", ids, true).
+			UpdateColumns(map[string]interface{}{"orphaned": false, "orphaned_at": nil}).Error
+
+		if err != nil {
+			return fmt.Errorf("failed to restore orphaned commits of branch %q: %w", branch, err)
+		}
+	}
+	return nil
+}
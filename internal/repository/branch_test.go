@@ -0,0 +1,165 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"gmonitor/internal/models"
+	"gmonitor/internal/repository"
+	"testing"
+	"time"
+)
+
+func TestMarkBranchSynced_MovesCursorForward(t *testing.T) {
+	db := setupRepoTestDB(t)
+	repoStore := repository.NewRepositoryRepo(db)
+
+	now := time.Now().UTC()
+	commits := []models.Commit{
+		{CommitHash: "new", CommitDate: now},
+		{CommitHash: "old", CommitDate: now.Add(-time.Hour)},
+	}
+	if err := repoStore.MarkBranchSynced(context.Background(), 1, "release/1.0", commits, now); err != nil {
+		t.Fatalf("failed to mark branch synced: %v", err)
+	}
+	older := []models.Commit{{CommitHash: "older", CommitDate: now.Add(-2 * time.Hour)}}
+	if err := repoStore.MarkBranchSynced(context.Background(), 1, "release/1.0", older, now); err != nil {
+		t.Fatalf("failed to mark branch synced: %v", err)
+	}
+
+	branch, err := repoStore.GetBranch(context.Background(), 1, "release/1.0")
+	if err != nil {
+		t.Fatalf("failed to get branch: %v", err)
+	}
+	if branch.LastCommitSHA != "new" || branch.LastCommitDate == nil || !branch.LastCommitDate.Equal(now) {
+		t.Errorf("unexpected branch cursor: %+v", branch)
+	}
+
+	unknown, err := repoStore.GetBranch(context.Background(), 1, "develop")
+	if err != nil || unknown.LastCommitDate != nil {
+		t.Errorf("expected a branch without cursor, got %+v (%v)", unknown, err)
+	}
+}
+
+func TestDeleteBranch_RecreatedBranchStartsOver(t *testing.T) {
+	db := setupRepoTestDB(t)
+	repoStore := repository.NewRepositoryRepo(db)
+
+	now := time.Now().UTC()
+	if err := repoStore.MarkBranchSynced(context.Background(), 1, "feature", []models.Commit{{CommitHash: "old", CommitDate: now}}, now); err != nil {
+		t.Fatalf("failed to mark branch synced: %v", err)
+	}
+	if err := repoStore.DeleteBranch(context.Background(), 1, "feature"); err != nil {
+		t.Fatalf("failed to delete branch: %v", err)
+	}
+
+	deleted, err := repoStore.GetBranch(context.Background(), 1, "feature")
+	if err != nil || deleted.LastCommitDate != nil {
+		t.Errorf("expected the deleted branch to have no cursor, got %+v (%v)", deleted, err)
+	}
+
+	// A branch created again under the same name is synced like a new one
+	earlier := now.Add(-time.Hour)
+	if err := repoStore.MarkBranchSynced(context.Background(), 1, "feature", []models.Commit{{CommitHash: "new", CommitDate: earlier}}, now); err != nil {
+		t.Fatalf("failed to mark recreated branch synced: %v", err)
+	}
+	recreated, _ := repoStore.GetBranch(context.Background(), 1, "feature")
+	if recreated.LastCommitSHA != "new" {
+		t.Errorf("unexpected cursor of the recreated branch: %+v", recreated)
+	}
+}
+
+func TestSetTrackedBranches(t *testing.T) {
+	db := setupRepoTestDB(t)
+	repoStore := repository.NewRepositoryRepo(db)
+
+	repo := &models.Repository{Name: "branches"}
+	if err := repoStore.SaveRepository(context.Background(), repo); err != nil {
+		t.Fatalf("failed to save repository: %v", err)
+	}
+	if err := repoStore.SetTrackedBranches(context.Background(), repo.ID, []string{"release/*"}); err != nil {
+		t.Fatalf("failed to set tracked branches: %v", err)
+	}
+
+	stored, _ := repoStore.GetRepository(context.Background(), repository.RepositoryName("branches"))
+	if len(stored.TrackedBranches) != 1 || stored.TrackedBranches[0] != "release/*" {
+		t.Errorf("unexpected tracked branches: %v", stored.TrackedBranches)
+	}
+}
+
+func TestGetCommitsByRepository_BranchFilter(t *testing.T) {
+	db := setupTestDB(t)
+	commitRepo := repository.NewCommitRepo(db)
+
+	r := models.Repository{Name: "branch-repo"}
+	db.Create(&r)
+
+	commits := []models.Commit{
+		{CommitHash: "m1", Author: "A", CommitDate: time.Now()},
+		{CommitHash: "r1", Author: "B", CommitDate: time.Now()},
+	}
+	if err := commitRepo.SaveCommits(context.Background(), r.ID, commits); err != nil {
+		t.Fatalf("failed to save commits: %v", err)
+	}
+	if err := commitRepo.RecordBranch(context.Background(), r.ID, "main", commits[:1]); err != nil {
+		t.Fatalf("failed to record branch: %v", err)
+	}
+	// Recording a commit twice keeps a single membership
+	if err := commitRepo.RecordBranch(context.Background(), r.ID, "release/1.0", commits); err != nil {
+		t.Fatalf("failed to record branch: %v", err)
+	}
+	if err := commitRepo.RecordBranch(context.Background(), r.ID, "release/1.0", commits[1:]); err != nil {
+		t.Fatalf("failed to record branch: %v", err)
+	}
+
+	found, err := commitRepo.GetCommitsByRepository(context.Background(), repository.RepositoryName("branch-repo"), "main", 20, 0)
+	if err != nil {
+		t.Fatalf("failed to get commits: %v", err)
+	}
+	if len(found) != 1 || found[0].CommitHash != "m1" {
+		t.Errorf("unexpected commits on main: %+v", found)
+	}
+
+	found, _ = commitRepo.GetCommitsByRepository(context.Background(), repository.RepositoryName("branch-repo"), "release/1.0", 20, 0)
+	if len(found) != 2 {
+		t.Errorf("expected 2 commits on the release branch, got %d", len(found))
+	}
+}
+
+func TestSetBranchHead(t *testing.T) {
+	db := setupRepoTestDB(t)
+	repoStore := repository.NewRepositoryRepo(db)
+
+	for _, head := range []string{"first", "second"} {
+		if err := repoStore.SetBranchHead(context.Background(), 1, "main", head); err != nil {
+			t.Fatalf("failed to set branch head: %v", err)
+		}
+	}
+
+	branch, err := repoStore.GetBranch(context.Background(), 1, "main")
+	if err != nil || branch.HeadSHA != "second" {
+		t.Errorf("expected head 'second', got %+v (%v)", branch, err)
+	}
+}
+
+func TestInTransaction_RollsBackBranchHead(t *testing.T) {
+	db := setupRepoTestDB(t)
+	repoStore := repository.NewRepositoryRepo(db)
+	ctx := context.Background()
+
+	_ = repoStore.SetBranchHead(ctx, 1, "main", "first")
+	failed := errors.New("sync failed")
+	err := repoStore.InTransaction(ctx, func(repos *repository.RepositoryRepo, commits *repository.CommitRepo) error {
+		if err := repos.SetBranchHead(ctx, 1, "main", "second"); err != nil {
+			return err
+		}
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("expected the error of the transaction, got: %v", err)
+	}
+
+	branch, err := repoStore.GetBranch(ctx, 1, "main")
+	if err != nil || branch.HeadSHA != "first" {
+		t.Errorf("expected the head to stay at 'first', got %+v (%v)", branch, err)
+	}
+}
@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"gmonitor/internal/models"
+	"gorm.io/gorm"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Churn sums up the lines added and removed by a group of commits
+type Churn struct {
+	Name      string
+	Commits   int
+	Additions int
+	Deletions int
+}
+
+// GetChurnByRepository retrieves the lines changed per repository between since and until
+func (r *CommitRepo) GetChurnByRepository(ctx context.Context, since, until time.Time) ([]Churn, error) {
+	var results []Churn
+
+	err := r.churnQuery(ctx, RepositoryKey{}, since, until).
+		Select("repositories.name AS name, COUNT(DISTINCT commits.id) AS commits, " +
+			"SUM(commit_files.additions) AS additions, SUM(commit_files.deletions) AS deletions").
+		Group("repositories.id, repositories.name").
+		Order("SUM(commit_files.additions) + SUM(commit_files.deletions) DESC").
+		Scan(&results).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get churn by repository: %w", err)
+	}
+	return results, nil
+}
+
+// GetChurnByAuthor retrieves the lines changed per contributor of a repository between since and until
+func (r *CommitRepo) GetChurnByAuthor(ctx context.Context, repo RepositoryKey, since, until time.Time) ([]Churn, error) {
+	var results []Churn
+
+	err := r.churnQuery(ctx, repo, since, until).
+		Select("COALESCE(MAX(contributors.name), MAX(commits.author)) AS name, COUNT(DISTINCT commits.id) AS commits, " +
+			"SUM(commit_files.additions) AS additions, SUM(commit_files.deletions) AS deletions").
+		Joins("LEFT JOIN contributors ON commits.contributor_id = contributors.id").
+		Group(contributorGroup).
+		Order("SUM(commit_files.additions) + SUM(commit_files.deletions) DESC").
+		Scan(&results).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get churn by author: %w", err)
+	}
+	return results, nil
+}
+
+// GetChurnByDirectory retrieves the lines changed per directory of a repository between since and until.
+// Directories are cut to the given depth, so a depth of 1 groups by top-level directory.
+func (r *CommitRepo) GetChurnByDirectory(ctx context.Context, repo RepositoryKey, since, until time.Time, depth int) ([]Churn, error) {
+	var rows []struct {
+		Directory string
+		CommitID  uint
+		Additions int
+		Deletions int
+	}
+
+	err := r.churnQuery(ctx, repo, since, until).
+		Select("commit_files.directory AS directory, commits.id AS commit_id, " +
+			"SUM(commit_files.additions) AS additions, SUM(commit_files.deletions) AS deletions").
+		Group("commit_files.directory, commits.id").
+		Scan(&rows).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get churn by directory: %w", err)
+	}
+
+	// Fold the directories to the requested depth, counting each commit once per directory
+	byDirectory := make(map[string]*Churn)
+	seen := make(map[string]map[uint]bool)
+	for _, row := range rows {
+		dir := truncateDirectory(row.Directory, depth)
+		churn, found := byDirectory[dir]
+		if !found {
+			churn = &Churn{Name: dir}
+			byDirectory[dir] = churn
+			seen[dir] = make(map[uint]bool)
+		}
+		if !seen[dir][row.CommitID] {
+			seen[dir][row.CommitID] = true
+			churn.Commits++
+		}
+		churn.Additions += row.Additions
+		churn.Deletions += row.Deletions
+	}
+
+	results := make([]Churn, 0, len(byDirectory))
+	for _, churn := range byDirectory {
+		results = append(results, *churn)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if a, b := results[i].Additions+results[i].Deletions, results[j].Additions+results[j].Deletions; a != b {
+			return a > b
+		}
+		return results[i].Name < results[j].Name
+	})
+	return results, nil
+}
+
+// churnQuery joins the file statistics to their commits and repositories within the time range.
+// A key without a name covers every repository, zero times leave the range open.
+func (r *CommitRepo) churnQuery(ctx context.Context, repo RepositoryKey, since, until time.Time) *gorm.DB {
+	query := r.db.WithContext(ctx).
+		Model(&models.CommitFile{}).
+		Joins("JOIN commits ON commit_files.commit_id = commits.id").
+		Joins("JOIN repositories ON commits.repo_id = repositories.id").
+		Where("commits.deleted_at IS NULL")
+
+	if repo.Name != "" {
+		query = query.Scopes(inRepository(repo))
+	}
+	if !since.IsZero() {
+		query = query.Where("commits.commit_date >= ?", since)
+	}
+	if !until.IsZero() {
+		query = query.Where("commits.commit_date <= ?", until)
+	}
+	return query
+}
+
+// truncateDirectory cuts a slash separated directory down to its first depth components
+func truncateDirectory(dir string, depth int) string {
+	if depth <= 0 || dir == "." {
+		return dir
+	}
+	parts := strings.Split(dir, "/")
+	if len(parts) <= depth {
+		return dir
+	}
+	return strings.Join(parts[:depth], "/")
+}
@@ -0,0 +1,80 @@
+package repository_test
+
+import (
+	"context"
+	"gmonitor/internal/models"
+	"gmonitor/internal/repository"
+	"testing"
+	"time"
+)
+
+func seedChurn(t *testing.T, commitRepo *repository.CommitRepo, repoID uint, now time.Time) {
+	commits := []models.Commit{
+		{CommitHash: "c1", Author: "Alice", CommitDate: now.Add(-time.Hour), Files: []models.CommitFile{
+			{Filename: "cmd/main.go", Directory: "cmd", Additions: 10, Deletions: 2},
+			{Filename: "internal/db/db.go", Directory: "internal/db", Additions: 5},
+		}},
+		{CommitHash: "c2", Author: "Bob", CommitDate: now, Files: []models.CommitFile{
+			{Filename: "internal/server/server.go", Directory: "internal/server", Additions: 1, Deletions: 1},
+		}},
+		{CommitHash: "c3", Author: "Bob", CommitDate: now.Add(-48 * time.Hour), Files: []models.CommitFile{
+			{Filename: "README.md", Directory: ".", Additions: 100},
+		}},
+	}
+	if err := commitRepo.SaveCommits(context.Background(), repoID, commits); err != nil {
+		t.Fatalf("failed to save commits: %v", err)
+	}
+}
+
+func TestGetChurnByAuthor(t *testing.T) {
+	db := setupTestDB(t)
+	commitRepo := repository.NewCommitRepo(db)
+
+	r := models.Repository{Name: "churn-repo"}
+	db.Create(&r)
+	now := time.Now().UTC()
+	seedChurn(t, commitRepo, r.ID, now)
+
+	churn, err := commitRepo.GetChurnByAuthor(context.Background(), repository.RepositoryName("churn-repo"), now.Add(-24*time.Hour), time.Time{})
+	if err != nil {
+		t.Fatalf("failed to get churn: %v", err)
+	}
+	if len(churn) != 2 || churn[0].Name != "Alice" || churn[0].Commits != 1 || churn[0].Additions != 15 || churn[0].Deletions != 2 {
+		t.Errorf("unexpected churn: %+v", churn)
+	}
+}
+
+func TestGetChurnByDirectory(t *testing.T) {
+	db := setupTestDB(t)
+	commitRepo := repository.NewCommitRepo(db)
+
+	r := models.Repository{Name: "churn-repo"}
+	db.Create(&r)
+	now := time.Now().UTC()
+	seedChurn(t, commitRepo, r.ID, now)
+
+	churn, err := commitRepo.GetChurnByDirectory(context.Background(), repository.RepositoryName("churn-repo"), now.Add(-24*time.Hour), time.Time{}, 1)
+	if err != nil {
+		t.Fatalf("failed to get churn: %v", err)
+	}
+	if len(churn) != 2 || churn[0].Name != "cmd" || churn[1].Name != "internal" || churn[1].Commits != 2 || churn[1].Additions != 6 {
+		t.Errorf("unexpected churn: %+v", churn)
+	}
+}
+
+func TestGetChurnByRepository(t *testing.T) {
+	db := setupTestDB(t)
+	commitRepo := repository.NewCommitRepo(db)
+
+	r := models.Repository{Name: "churn-repo"}
+	db.Create(&r)
+	seedChurn(t, commitRepo, r.ID, time.Now().UTC())
+
+	churn, err := commitRepo.GetChurnByRepository(context.Background(), time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("failed to get churn: %v", err)
+	}
+	if len(churn) != 1 || churn[0].Commits != 3 || churn[0].Additions != 116 || churn[0].Deletions != 3 {
+		t.Errorf("unexpected churn: %+v", churn)
+	}
+}
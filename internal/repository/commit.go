@@ -2,14 +2,17 @@ package repository
 
 import (
 	"context"
+	"database/sql/driver"
 	"fmt"
 	"gmonitor/internal/models"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
-	"strings"
 	"time"
 )
 
+// hashLookupBatch bounds the number of hashes bound into a single IN clause
+const hashLookupBatch = 500
+
 // CommitRepo provides database operations for commits
 type CommitRepo struct {
 	db *gorm.DB
@@ -24,9 +27,9 @@ func NewCommitRepo(db *gorm.DB) *CommitRepo {
 
 // SaveCommit saves a commit to the database if it doesn't already exist
 func (r *CommitRepo) SaveCommit(ctx context.Context, commit *models.Commit) error {
-	// Attempt to insert, skip if conflict on commit_hash
+	// Attempt to insert, skip if the repository already holds the commit
 	err := r.db.WithContext(ctx).
-		Clauses(clause.OnConflict{DoNothing: true}).
+		Clauses(commitConflict).
 		Create(commit).Error
 
 	if err != nil {
@@ -35,35 +38,244 @@ func (r *CommitRepo) SaveCommit(ctx context.Context, commit *models.Commit) erro
 	return nil
 }
 
-// SaveCommits saves multiple commits to the database
+// commitConflict skips commits the repository already holds. A commit is identified by its repository and hash,
+// so forks sharing history with their upstream each keep their own copy.
+var commitConflict = clause.OnConflict{
+	Columns:   []clause.Column{{Name: "repo_id"}, {Name: "commit_hash"}},
+	DoNothing: true,
+}
+
+// SaveCommits saves multiple commits of a repository. Saving is idempotent: commits the repository already holds,
+// or that appear twice in the batch, are left as stored rather than failing the batch. Every commit gets the ID of
+// its stored row, and the files of a commit are only stored along with the commit itself.
 func (r *CommitRepo) SaveCommits(ctx context.Context, repoID uint, commits []models.Commit) error {
 	if len(commits) == 0 {
 		return nil
 	}
 
-	// Set RepoID for all commits
+	// Set RepoID for all commits and keep the first occurrence of each hash
+	hashes := make([]string, 0, len(commits))
+	unique := make([]models.Commit, 0, len(commits))
+	position := make(map[string]int, len(commits))
 	for i := range commits {
 		commits[i].RepoID = repoID
+		if _, seen := position[commits[i].CommitHash]; !seen {
+			position[commits[i].CommitHash] = len(unique)
+			hashes = append(hashes, commits[i].CommitHash)
+			unique = append(unique, commits[i])
+		}
+	}
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		stored, err := commitIDs(tx, repoID, hashes)
+		if err != nil {
+			return err
+		}
+
+		if err := assignContributors(tx, unique); err != nil {
+			return err
+		}
+
+		// Files are saved separately, once the IDs of the commits are known
+		err = tx.Omit(clause.Associations).
+			Clauses(commitConflict).
+			CreateInBatches(&unique, 100).Error
+		if err != nil {
+			return fmt.Errorf("failed to save commits: %w", err)
+		}
+
+		// IDs set by the insert cannot be trusted for skipped rows, read them back
+		ids, err := commitIDs(tx, repoID, hashes)
+		if err != nil {
+			return err
+		}
+
+		var files []models.CommitFile
+		for _, commit := range unique {
+			if _, found := stored[commit.CommitHash]; found {
+				continue
+			}
+			for _, file := range commit.Files {
+				file.CommitID = ids[commit.CommitHash]
+				files = append(files, file)
+			}
+		}
+		if len(files) > 0 {
+			if err := tx.CreateInBatches(&files, 100).Error; err != nil {
+				return fmt.Errorf("failed to save commit files: %w", err)
+			}
+		}
+
+		for i := range commits {
+			commits[i].ID = ids[commits[i].CommitHash]
+			commits[i].ContributorID = unique[position[commits[i].CommitHash]].ContributorID
+		}
+		return nil
+	})
+}
+
+// commitIDs maps the given hashes to the IDs of the commits a repository holds, in batches
+func commitIDs(tx *gorm.DB, repoID uint, hashes []string) (map[string]uint, error) {
+	ids := make(map[string]uint, len(hashes))
+	for start := 0; start < len(hashes); start += hashLookupBatch {
+		end := min(start+hashLookupBatch, len(hashes))
+
+		var rows []struct {
+			ID         uint
+			CommitHash string
+		}
+		err := tx.Model(&models.Commit{}).
+			Select("id", "commit_hash").
+			Where("repo_id = ? AND commit_hash IN ?", repoID, hashes[start:end]).
+			Scan(&rows).Error
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up commit IDs: %w", err)
+		}
+		for _, row := range rows {
+			ids[row.CommitHash] = row.ID
+		}
 	}
+	return ids, nil
+}
 
-	tx := r.db.WithContext(ctx).Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-			panic(r)
+// FilterNewCommits returns the commits that are not stored for the repository yet
+func (r *CommitRepo) FilterNewCommits(ctx context.Context, repoID uint, commits []models.Commit) ([]models.Commit, error) {
+	if len(commits) == 0 {
+		return commits, nil
+	}
+
+	stored := make(map[string]bool)
+	for start := 0; start < len(commits); start += hashLookupBatch {
+		end := min(start+hashLookupBatch, len(commits))
+		hashes := make([]string, 0, end-start)
+		for _, commit := range commits[start:end] {
+			hashes = append(hashes, commit.CommitHash)
 		}
-	}()
 
-	if err := tx.Create(&commits).Error; err != nil {
-		tx.Rollback()
-		return fmt.Errorf("failed to save commits: %w", err)
+		var existing []string
+		err := r.db.WithContext(ctx).
+			Model(&models.Commit{}).
+			Where("repo_id = ? AND commit_hash IN ?", repoID, hashes).
+			Pluck("commit_hash", &existing).Error
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up existing commits: %w", err)
+		}
+		for _, hash := range existing {
+			stored[hash] = true
+		}
 	}
 
-	return tx.Commit().Error
+	newCommits := make([]models.Commit, 0, len(commits))
+	for _, commit := range commits {
+		if !stored[commit.CommitHash] {
+			stored[commit.CommitHash] = true
+			newCommits = append(newCommits, commit)
+		}
+	}
+	return newCommits, nil
 }
 
-// GetTopCommitAuthors retrieves the top N commit authors by commit count
-func (r *CommitRepo) GetTopCommitAuthors(ctx context.Context, limit int) ([]struct {
+// CompleteCommits fills in the parents, tree, committer and signature verification of stored commits that were saved
+// without them, such as commits of webhook push payloads, from the same commits as listed by a provider.
+// It returns how many stored commits were completed.
+func (r *CommitRepo) CompleteCommits(ctx context.Context, repoID uint, commits []models.Commit) (int, error) {
+	listed := make(map[string]models.Commit, len(commits))
+	for _, commit := range commits {
+		if commit.Parents != nil {
+			listed[commit.CommitHash] = commit
+		}
+	}
+
+	completed := 0
+	var earliest uint
+	for start := 0; start < len(commits); start += hashLookupBatch {
+		end := min(start+hashLookupBatch, len(commits))
+		hashes := make([]string, 0, end-start)
+		for _, commit := range commits[start:end] {
+			if _, found := listed[commit.CommitHash]; found {
+				hashes = append(hashes, commit.CommitHash)
+			}
+		}
+		if len(hashes) == 0 {
+			continue
+		}
+
+		var incomplete []models.Commit
+		err := r.db.WithContext(ctx).
+			Select("id", "commit_hash").
+			Where("repo_id = ? AND commit_hash IN ?", repoID, hashes).
+			Where("parents IS NULL OR parents = ?", "null").
+			Find(&incomplete).Error
+
+		if err != nil {
+			return completed, fmt.Errorf("failed to look up incomplete commits: %w", err)
+		}
+
+		for _, stored := range incomplete {
+			commit := listed[stored.CommitHash]
+			// Updating through the struct applies the JSON serializer of the parents
+			err := r.db.WithContext(ctx).
+				Model(&models.Commit{}).
+				Where("id = ?", stored.ID).
+				Select("parents", "tree_sha", "committer_name", "committer_email", "committer_date", "verified", "verification_reason").
+				Updates(&models.Commit{
+					Parents:            commit.Parents,
+					TreeSHA:            commit.TreeSHA,
+					CommitterName:      commit.CommitterName,
+					CommitterEmail:     commit.CommitterEmail,
+					CommitterDate:      commit.CommitterDate,
+					Verified:           commit.Verified,
+					VerificationReason: commit.VerificationReason,
+				}).Error
+
+			if err != nil {
+				return completed, fmt.Errorf("failed to complete commit %s: %w", stored.CommitHash, err)
+			}
+			completed++
+			if earliest == 0 || stored.ID < earliest {
+				earliest = stored.ID
+			}
+		}
+	}
+	if completed == 0 {
+		return 0, nil
+	}
+
+	// The releases reaching the completed commits now reach their parents too, so they are walked again
+	err := r.db.WithContext(ctx).
+		Model(&models.Repository{}).
+		Where("id = ? AND releases_resolved_up_to >= ?", repoID, earliest).
+		UpdateColumn("releases_resolved_up_to", earliest-1).Error
+
+	if err != nil {
+		return completed, fmt.Errorf("failed to reset release resolution progress: %w", err)
+	}
+	return completed, nil
+}
+
+// contributorGroup groups commits by the contributor of their author,
+// commits not matched to a contributor yet are grouped by author name
+const contributorGroup = "commits.contributor_id, CASE WHEN commits.contributor_id IS NULL THEN commits.author END"
+
+// AuthorFilter narrows down the commits counted by GetTopCommitAuthors. Zero fields leave the commits unfiltered,
+// so an empty Repo counts the commits of every repository.
+type AuthorFilter struct {
+	Repo        RepositoryKey
+	Branch      string
+	Since       time.Time
+	Until       time.Time
+	ExcludeBots bool
+}
+
+// botAuthor matches commits authored by bot accounts, which GitHub and Gitea suffix with [bot].
+// Logins and emails are missing on commits stored before they were recorded.
+const botAuthor = "(LOWER(commits.author) LIKE '%[bot]' OR LOWER(COALESCE(commits.author_login, '')) LIKE '%[bot]' " +
+	"OR LOWER(COALESCE(commits.author_email, '')) LIKE '%[bot]@%')"
+
+// GetTopCommitAuthors retrieves the top N commit authors by commit count, counting each contributor once
+func (r *CommitRepo) GetTopCommitAuthors(ctx context.Context, filter AuthorFilter, limit int) ([]struct {
 	Author string
 	Count  int
 }, error) {
@@ -72,10 +284,33 @@ func (r *CommitRepo) GetTopCommitAuthors(ctx context.Context, limit int) ([]stru
 		Count  int
 	}
 
-	err := r.db.WithContext(ctx).
+	query := r.db.WithContext(ctx).
 		Model(&models.Commit{}).
-		Select("author, COUNT(*) AS count").
-		Group("author").
+		Select("COALESCE(MAX(contributors.name), MAX(commits.author)) AS author, COUNT(*) AS count").
+		Joins("LEFT JOIN contributors ON commits.contributor_id = contributors.id")
+
+	if filter.Repo.Name != "" {
+		query = query.
+			Joins("JOIN repositories ON commits.repo_id = repositories.id").
+			Scopes(inRepository(filter.Repo))
+	}
+	if filter.Branch != "" {
+		query = query.
+			Joins("JOIN commit_branches ON commit_branches.commit_id = commits.id").
+			Where("commit_branches.branch = ? AND commit_branches.deleted_at IS NULL", filter.Branch)
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("commits.commit_date >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query = query.Where("commits.commit_date <= ?", filter.Until)
+	}
+	if filter.ExcludeBots {
+		query = query.Where("NOT " + botAuthor)
+	}
+
+	err := query.
+		Group(contributorGroup).
 		Order("count DESC").
 		Limit(limit).
 		Scan(&results).Error
@@ -87,47 +322,94 @@ func (r *CommitRepo) GetTopCommitAuthors(ctx context.Context, limit int) ([]stru
 	return results, nil
 }
 
-// GetLatestCommitDate retrieves only the most recent commit date
-func (r *CommitRepo) GetLatestCommitDate(ctx context.Context) (time.Time, error) {
-	var latest string
+// GetLatestCommitDate retrieves only the most recent commit date of a repository, zero when it has no commits
+func (r *CommitRepo) GetLatestCommitDate(ctx context.Context, repoID uint) (time.Time, error) {
+	var latest aggregateTime
 
-	// Fetch the latest commit date as a string
 	err := r.db.WithContext(ctx).
 		Model(&models.Commit{}).
 		Select("MAX(commit_date)").
-		Scan(&latest).Error
+		Where("repo_id = ?", repoID).
+		Row().
+		Scan(&latest)
 
 	if err != nil {
 		return time.Time{}, fmt.Errorf("failed to get latest commit date: %w", err)
 	}
 
-	// Replace space with 'T' for correct RFC3339 parsing
-	latest = strings.Replace(latest, " ", "T", 1)
+	return latest.Time, nil
+}
+
+// aggregateTimeLayouts are the layouts drivers return dates as when they do not return a time.Time.
+// SQLite loses the column type on MIN and MAX and returns the stored text, MySQL returns text without parseTime.
+var aggregateTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999-07",
+	"2006-01-02 15:04:05.999999999",
+}
 
-	// Parse the string into time.Time using RFC3339 format
-	latestTime, err := time.Parse(time.RFC3339, latest)
-	if err != nil {
-		return time.Time{}, fmt.Errorf("failed to parse latest commit date: %w", err)
+// aggregateTime scans the result of MIN or MAX over a date column on every driver, NULL scans to the zero time
+type aggregateTime struct {
+	time.Time
+}
+
+// Scan implements sql.Scanner
+func (t *aggregateTime) Scan(value interface{}) error {
+	var text string
+	switch v := value.(type) {
+	case nil:
+		t.Time = time.Time{}
+		return nil
+	case time.Time:
+		t.Time = v
+		return nil
+	case string:
+		text = v
+	case []byte:
+		text = string(v)
+	default:
+		return fmt.Errorf("unsupported date type %T", value)
+	}
+
+	for _, layout := range aggregateTimeLayouts {
+		if parsed, err := time.Parse(layout, text); err == nil {
+			t.Time = parsed
+			return nil
+		}
 	}
+	return fmt.Errorf("failed to parse date %q", text)
+}
 
-	return latestTime, nil
+// Value implements driver.Valuer, which GORM requires along with sql.Scanner on scanned struct fields
+func (t aggregateTime) Value() (driver.Value, error) {
+	return t.Time, nil
 }
 
-// GetCommitsByRepository retrieves paginated commits for a given repository by name
-func (r *CommitRepo) GetCommitsByRepository(ctx context.Context, repoName string, limit, offset int) ([]*models.Commit, error) {
+// GetCommitsByRepository retrieves paginated commits for a given repository by name.
+// A non-empty branch limits the commits to those seen on that branch.
+func (r *CommitRepo) GetCommitsByRepository(ctx context.Context, repo RepositoryKey, branch string, limit, offset int) ([]*models.Commit, error) {
 	var commits []*models.Commit
 
-	err := r.db.WithContext(ctx).
+	query := r.db.WithContext(ctx).
 		Model(&models.Commit{}).
 		Joins("JOIN repositories ON commits.repo_id = repositories.id").
-		Where("repositories.name = ?", repoName).
+		Scopes(inRepository(repo))
+
+	if branch != "" {
+		query = query.
+			Joins("JOIN commit_branches ON commit_branches.commit_id = commits.id").
+			Where("commit_branches.branch = ? AND commit_branches.deleted_at IS NULL", branch)
+	}
+
+	err := query.
 		Limit(limit).
 		Offset(offset).
 		Order("commit_date DESC").
 		Find(&commits).Error
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to get commits for repository %q: %w", repoName, err)
+		return nil, fmt.Errorf("failed to get commits for repository %q: %w", repo, err)
 	}
 
 	return commits, nil
@@ -16,7 +16,11 @@ func setupTestDB(t *testing.T) *gorm.DB {
 		t.Fatalf("failed to connect test db: %v", err)
 	}
 
-	if err := db.AutoMigrate(&models.Repository{}, &models.Commit{}); err != nil {
+	if err := db.AutoMigrate(
+		&models.Repository{}, &models.Commit{}, &models.CommitFile{}, &models.CommitBranch{},
+		&models.Contributor{}, &models.ContributorAlias{},
+		&models.HistoryRewrite{}, &models.Tag{}, &models.Release{}, &models.PullRequest{}, &models.Issue{},
+	); err != nil {
 		t.Fatalf("failed to migrate schema: %v", err)
 	}
 
@@ -72,7 +76,7 @@ func TestGetCommitsByRepository(t *testing.T) {
 	}
 	db.Create(&commits)
 
-	found, err := repo.GetCommitsByRepository(context.Background(), "test-repo", 20, 1)
+	found, err := repo.GetCommitsByRepository(context.Background(), repository.RepositoryName("test-repo"), "", 20, 1)
 	if err != nil {
 		t.Fatalf("failed to get commits: %v", err)
 	}
@@ -105,6 +109,53 @@ func TestSaveCommits(t *testing.T) {
 	}
 }
 
+func TestSaveCommits_SharedWithFork(t *testing.T) {
+	db := setupTestDB(t)
+	repo := repository.NewCommitRepo(db)
+
+	upstream := models.Repository{Name: "upstream"}
+	db.Create(&upstream)
+	fork := models.Repository{Name: "fork"}
+	db.Create(&fork)
+
+	shared := func() []models.Commit {
+		return []models.Commit{
+			{CommitHash: "s1", Author: "A", Message: "m1", CommitDate: time.Now(), Files: []models.CommitFile{{Filename: "a.go", Directory: "."}}},
+			{CommitHash: "s2", Author: "B", Message: "m2", CommitDate: time.Now()},
+		}
+	}
+	if err := repo.SaveCommits(context.Background(), upstream.ID, shared()); err != nil {
+		t.Fatalf("failed to save upstream commits: %v", err)
+	}
+	if err := repo.SaveCommits(context.Background(), fork.ID, shared()); err != nil {
+		t.Fatalf("failed to save fork commits: %v", err)
+	}
+
+	// Saving again, with a duplicate and a new commit in the batch, keeps the stored commits and adds the new one
+	again := append(shared(), models.Commit{CommitHash: "s1", Author: "A", CommitDate: time.Now()},
+		models.Commit{CommitHash: "f1", Author: "C", Message: "m3", CommitDate: time.Now()})
+	if err := repo.SaveCommits(context.Background(), fork.ID, again); err != nil {
+		t.Fatalf("expected saving stored commits to succeed, got %v", err)
+	}
+	if again[0].ID == 0 || again[0].ID != again[2].ID || again[3].ID == 0 {
+		t.Errorf("expected every commit to carry the ID of its stored row, got %d, %d and %d", again[0].ID, again[2].ID, again[3].ID)
+	}
+
+	var count, files int64
+	db.Model(&models.Commit{}).Where("repo_id = ?", fork.ID).Count(&count)
+	if count != 3 {
+		t.Errorf("expected 3 commits in the fork, got %d", count)
+	}
+	db.Model(&models.Commit{}).Where("repo_id = ?", upstream.ID).Count(&count)
+	if count != 2 {
+		t.Errorf("expected 2 commits upstream, got %d", count)
+	}
+	db.Model(&models.CommitFile{}).Count(&files)
+	if files != 2 {
+		t.Errorf("expected the files of each stored commit once, got %d", files)
+	}
+}
+
 func TestGetTopCommitAuthors(t *testing.T) {
 	db := setupTestDB(t)
 	repo := repository.NewCommitRepo(db)
@@ -119,7 +170,7 @@ func TestGetTopCommitAuthors(t *testing.T) {
 	}
 	db.Create(&commits)
 
-	top, err := repo.GetTopCommitAuthors(context.Background(), 2)
+	top, err := repo.GetTopCommitAuthors(context.Background(), repository.AuthorFilter{Repo: repository.RepositoryName("stats-repo")}, 2)
 	if err != nil {
 		t.Fatalf("failed to get top authors: %v", err)
 	}
@@ -128,18 +179,79 @@ func TestGetTopCommitAuthors(t *testing.T) {
 	}
 }
 
+func TestGetTopCommitAuthors_Filters(t *testing.T) {
+	db := setupTestDB(t)
+	repo := repository.NewCommitRepo(db)
+
+	r := models.Repository{Name: "stats-repo"}
+	db.Create(&r)
+	other := models.Repository{Name: "other-repo"}
+	db.Create(&other)
+
+	now := time.Now().UTC()
+	commits := []models.Commit{
+		{CommitHash: "a1", Author: "Dev1", RepoID: r.ID, CommitDate: now.Add(-48 * time.Hour)},
+		{CommitHash: "a2", Author: "Dev2", RepoID: r.ID, CommitDate: now},
+		{CommitHash: "a3", Author: "dependabot[bot]", AuthorLogin: "dependabot[bot]", RepoID: r.ID, CommitDate: now},
+		{CommitHash: "a4", Author: "dependabot[bot]", AuthorLogin: "dependabot[bot]", RepoID: r.ID, CommitDate: now},
+		{CommitHash: "b1", Author: "Dev1", RepoID: other.ID, CommitDate: now},
+		{CommitHash: "b2", Author: "Dev1", RepoID: other.ID, CommitDate: now},
+	}
+	db.Create(&commits)
+	db.Create(&models.CommitBranch{CommitID: commits[1].ID, Branch: "release"})
+
+	cases := []struct {
+		name   string
+		filter repository.AuthorFilter
+		want   map[string]int
+	}{
+		{"repository", repository.AuthorFilter{Repo: repository.RepositoryName("stats-repo")}, map[string]int{"Dev1": 1, "Dev2": 1, "dependabot[bot]": 2}},
+		{"all repositories", repository.AuthorFilter{}, map[string]int{"Dev1": 3, "Dev2": 1, "dependabot[bot]": 2}},
+		{"since", repository.AuthorFilter{Repo: repository.RepositoryName("stats-repo"), Since: now.Add(-time.Hour)}, map[string]int{"Dev2": 1, "dependabot[bot]": 2}},
+		{"until", repository.AuthorFilter{Repo: repository.RepositoryName("stats-repo"), Until: now.Add(-time.Hour)}, map[string]int{"Dev1": 1}},
+		{"branch", repository.AuthorFilter{Repo: repository.RepositoryName("stats-repo"), Branch: "release"}, map[string]int{"Dev2": 1}},
+		{"exclude bots", repository.AuthorFilter{Repo: repository.RepositoryName("stats-repo"), ExcludeBots: true}, map[string]int{"Dev1": 1, "Dev2": 1}},
+	}
+	for _, tc := range cases {
+		top, err := repo.GetTopCommitAuthors(context.Background(), tc.filter, 10)
+		if err != nil {
+			t.Fatalf("%s: failed to get top authors: %v", tc.name, err)
+		}
+		got := make(map[string]int)
+		for _, author := range top {
+			got[author.Author] = author.Count
+		}
+		if len(got) != len(tc.want) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, got)
+			continue
+		}
+		for name, count := range tc.want {
+			if got[name] != count {
+				t.Errorf("%s: expected %v, got %v", tc.name, tc.want, got)
+				break
+			}
+		}
+	}
+}
+
 func TestGetLatestCommitDate(t *testing.T) {
 	db := setupTestDB(t)
 	repo := repository.NewCommitRepo(db)
 
+	r := models.Repository{Name: "latest-repo"}
+	db.Create(&r)
+	other := models.Repository{Name: "busy-repo"}
+	db.Create(&other)
+
 	now := time.Now().UTC()
 	commits := []models.Commit{
-		{CommitHash: "c1", Author: "X", CommitDate: now.Add(-2 * time.Hour)},
-		{CommitHash: "c2", Author: "Y", CommitDate: now},
+		{CommitHash: "c1", Author: "X", RepoID: r.ID, CommitDate: now.Add(-2 * time.Hour)},
+		{CommitHash: "c2", Author: "Y", RepoID: r.ID, CommitDate: now},
+		{CommitHash: "c3", Author: "Z", RepoID: other.ID, CommitDate: now.Add(time.Hour)},
 	}
 	db.Create(&commits)
 
-	latest, err := repo.GetLatestCommitDate(context.Background())
+	latest, err := repo.GetLatestCommitDate(context.Background(), r.ID)
 	if err != nil {
 		t.Fatalf("failed to get latest commit date: %v", err)
 	}
@@ -147,3 +259,76 @@ func TestGetLatestCommitDate(t *testing.T) {
 		t.Errorf("expected latest date %v, got %v", now, latest)
 	}
 }
+
+func TestFilterNewCommits(t *testing.T) {
+	db := setupTestDB(t)
+	commitRepo := repository.NewCommitRepo(db)
+
+	_ = commitRepo.SaveCommits(context.Background(), 1, []models.Commit{
+		{CommitHash: "stored", CommitDate: time.Now()},
+	})
+
+	commits := []models.Commit{
+		{CommitHash: "stored"},
+		{CommitHash: "fresh"},
+		{CommitHash: "fresh"},
+	}
+	newCommits, err := commitRepo.FilterNewCommits(context.Background(), 1, commits)
+	if err != nil {
+		t.Fatalf("failed to filter commits: %v", err)
+	}
+	if len(newCommits) != 1 || newCommits[0].CommitHash != "fresh" {
+		t.Errorf("expected only 'fresh' to be new, got %v", newCommits)
+	}
+}
+
+func TestCompleteCommits(t *testing.T) {
+	db := setupTestDB(t)
+	commitRepo := repository.NewCommitRepo(db)
+	ctx := context.Background()
+
+	_ = commitRepo.SaveCommits(ctx, 1, []models.Commit{
+		{CommitHash: "pushed", CommitDate: time.Now()},
+		{CommitHash: "polled", CommitDate: time.Now(), Parents: []string{"old"}},
+	})
+
+	listed := []models.Commit{
+		{CommitHash: "pushed", Parents: []string{"p1", "p2"}, Verified: true, VerificationReason: "valid"},
+		{CommitHash: "polled", Parents: []string{"other"}},
+	}
+	completed, err := commitRepo.CompleteCommits(ctx, 1, listed)
+	if err != nil {
+		t.Fatalf("failed to complete commits: %v", err)
+	}
+	if completed != 1 {
+		t.Errorf("expected only the commit without parents to be completed, got %d", completed)
+	}
+
+	var pushed, polled models.Commit
+	db.Where("commit_hash = ?", "pushed").First(&pushed)
+	db.Where("commit_hash = ?", "polled").First(&polled)
+	if !pushed.IsMerge() || !pushed.Verified || pushed.VerificationReason != "valid" {
+		t.Errorf("expected the pushed commit to be completed, got %+v", pushed)
+	}
+	if len(polled.Parents) != 1 || polled.Parents[0] != "old" {
+		t.Errorf("expected complete commits to be left alone, got %v", polled.Parents)
+	}
+}
+
+func TestSaveCommits_KeepsParents(t *testing.T) {
+	db := setupTestDB(t)
+	commitRepo := repository.NewCommitRepo(db)
+
+	commits := []models.Commit{
+		{CommitHash: "merge", Author: "dev", CommitDate: time.Now(), Parents: []string{"p1", "p2"}},
+	}
+	if err := commitRepo.SaveCommits(context.Background(), 1, commits); err != nil {
+		t.Fatalf("failed to save commits: %v", err)
+	}
+
+	var stored models.Commit
+	db.Where("commit_hash = ?", "merge").First(&stored)
+	if !stored.IsMerge() || stored.Parents[1] != "p2" {
+		t.Errorf("expected parents to round-trip, got %v", stored.Parents)
+	}
+}
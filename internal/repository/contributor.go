@@ -0,0 +1,385 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"gmonitor/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strings"
+	"time"
+)
+
+// ContributorRepo provides database operations for contributors and their aliases
+type ContributorRepo struct {
+	db *gorm.DB
+}
+
+// NewContributorRepo creates a new repository instance
+func NewContributorRepo(db *gorm.DB) *ContributorRepo {
+	return &ContributorRepo{
+		db: db,
+	}
+}
+
+// ContributorActivity sums up the commits of a contributor to a repository
+type ContributorActivity struct {
+	ContributorID uint
+	Name          string
+	Repository    string
+	Commits       int
+	FirstCommitAt time.Time
+	LastCommitAt  time.Time
+}
+
+// identity is an alias value a commit can be matched to a contributor by
+type identity struct {
+	kind  string
+	value string
+}
+
+// commitIdentities returns the identities of the author of a commit, most reliable first.
+// The author name is only used for commits that carry neither a login nor an email.
+func commitIdentities(commit *models.Commit) []identity {
+	identities := make([]identity, 0, 2)
+	if login := strings.ToLower(strings.TrimSpace(commit.AuthorLogin)); login != "" {
+		identities = append(identities, identity{models.AliasLogin, login})
+	}
+	if email := strings.ToLower(strings.TrimSpace(commit.AuthorEmail)); email != "" {
+		identities = append(identities, identity{models.AliasEmail, email})
+	}
+	if len(identities) == 0 {
+		if name := strings.ToLower(strings.TrimSpace(commit.Author)); name != "" {
+			identities = append(identities, identity{models.AliasName, name})
+		}
+	}
+	return identities
+}
+
+// assignContributors points each commit to the contributor matching its author, creating contributors and
+// aliases for identities seen for the first time. Identities of a commit that match different contributors
+// are left apart, merging them is up to a mailmap or a manual merge.
+func assignContributors(tx *gorm.DB, commits []models.Commit) error {
+	known := make(map[identity]uint)
+
+	values := make(map[string][]string)
+	for i := range commits {
+		for _, id := range commitIdentities(&commits[i]) {
+			values[id.kind] = append(values[id.kind], id.value)
+		}
+	}
+	for kind, batch := range values {
+		for start := 0; start < len(batch); start += hashLookupBatch {
+			var aliases []models.ContributorAlias
+			err := tx.Where("kind = ? AND value IN ?", kind, batch[start:min(start+hashLookupBatch, len(batch))]).
+				Find(&aliases).Error
+
+			if err != nil {
+				return fmt.Errorf("failed to look up contributor aliases: %w", err)
+			}
+			for _, alias := range aliases {
+				known[identity{alias.Kind, alias.Value}] = alias.ContributorID
+			}
+		}
+	}
+
+	for i := range commits {
+		identities := commitIdentities(&commits[i])
+		if len(identities) == 0 {
+			continue
+		}
+
+		var contributorID uint
+		for _, id := range identities {
+			if found, ok := known[id]; ok {
+				contributorID = found
+				break
+			}
+		}
+		if contributorID == 0 {
+			contributor := &models.Contributor{
+				Name:  commits[i].Author,
+				Email: commits[i].AuthorEmail,
+				Login: commits[i].AuthorLogin,
+			}
+			if err := tx.Create(contributor).Error; err != nil {
+				return fmt.Errorf("failed to create contributor: %w", err)
+			}
+			contributorID = contributor.ID
+		}
+
+		for _, id := range identities {
+			if _, ok := known[id]; ok {
+				continue
+			}
+			alias := &models.ContributorAlias{ContributorID: contributorID, Kind: id.kind, Value: id.value}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(alias).Error; err != nil {
+				return fmt.Errorf("failed to create contributor alias: %w", err)
+			}
+			known[id] = contributorID
+		}
+
+		commits[i].ContributorID = &contributorID
+	}
+	return nil
+}
+
+// AssignMissing points the stored commits without a contributor to one, in batches
+func (r *ContributorRepo) AssignMissing(ctx context.Context) (int, error) {
+	assigned := 0
+	for lastID := uint(0); ; {
+		var commits []models.Commit
+		err := r.db.WithContext(ctx).
+			Select("id", "author", "author_email", "author_login").
+			Where("contributor_id IS NULL AND id > ?", lastID).
+			Order("id").
+			Limit(hashLookupBatch).
+			Find(&commits).Error
+
+		if err != nil {
+			return assigned, fmt.Errorf("failed to get commits without contributor: %w", err)
+		}
+		if len(commits) == 0 {
+			return assigned, nil
+		}
+		lastID = commits[len(commits)-1].ID
+
+		err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := assignContributors(tx, commits); err != nil {
+				return err
+			}
+			for _, commit := range commits {
+				if commit.ContributorID == nil {
+					continue
+				}
+				err := tx.Model(&models.Commit{}).
+					Where("id = ?", commit.ID).
+					UpdateColumn("contributor_id", *commit.ContributorID).Error
+
+				if err != nil {
+					return fmt.Errorf("failed to assign contributor: %w", err)
+				}
+				assigned++
+			}
+			return nil
+		})
+		if err != nil {
+			return assigned, err
+		}
+	}
+}
+
+// GetContributors retrieves paginated contributors ordered by name
+func (r *ContributorRepo) GetContributors(ctx context.Context, limit, offset int) ([]models.Contributor, error) {
+	var contributors []models.Contributor
+
+	err := r.db.WithContext(ctx).
+		Order("name").
+		Limit(limit).
+		Offset(offset).
+		Find(&contributors).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get contributors: %w", err)
+	}
+	return contributors, nil
+}
+
+// GetContributor retrieves a contributor along with its aliases
+func (r *ContributorRepo) GetContributor(ctx context.Context, id uint) (*models.Contributor, error) {
+	var contributor models.Contributor
+
+	err := r.db.WithContext(ctx).
+		Preload("Aliases").
+		First(&contributor, id).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, sql.ErrNoRows
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get contributor: %w", err)
+	}
+	return &contributor, nil
+}
+
+// GetActivity retrieves the commit count and the first and last commit of contributors per repository.
+// A non-zero contributorID limits the activity to that contributor, a key with a name to that repository.
+func (r *ContributorRepo) GetActivity(ctx context.Context, contributorID uint, repo RepositoryKey) ([]ContributorActivity, error) {
+	var rows []struct {
+		ContributorID uint
+		Name          string
+		Repository    string
+		Commits       int
+		FirstCommitAt aggregateTime
+		LastCommitAt  aggregateTime
+	}
+
+	query := r.db.WithContext(ctx).
+		Model(&models.Commit{}).
+		Select("contributors.id AS contributor_id, contributors.name AS name, repositories.name AS repository, " +
+			"COUNT(*) AS commits, MIN(commits.commit_date) AS first_commit_at, MAX(commits.commit_date) AS last_commit_at").
+		Joins("JOIN contributors ON commits.contributor_id = contributors.id").
+		Joins("JOIN repositories ON commits.repo_id = repositories.id")
+
+	if contributorID != 0 {
+		query = query.Where("contributors.id = ?", contributorID)
+	}
+	if repo.Name != "" {
+		query = query.Scopes(inRepository(repo))
+	}
+
+	err := query.
+		Group("contributors.id, contributors.name, repositories.id, repositories.name").
+		Order("COUNT(*) DESC").
+		Scan(&rows).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get contributor activity: %w", err)
+	}
+
+	activity := make([]ContributorActivity, 0, len(rows))
+	for _, row := range rows {
+		activity = append(activity, ContributorActivity{
+			ContributorID: row.ContributorID,
+			Name:          row.Name,
+			Repository:    row.Repository,
+			Commits:       row.Commits,
+			FirstCommitAt: row.FirstCommitAt.Time,
+			LastCommitAt:  row.LastCommitAt.Time,
+		})
+	}
+	return activity, nil
+}
+
+// Merge moves the aliases and commits of the source contributors to the target and deletes the sources
+func (r *ContributorRepo) Merge(ctx context.Context, targetID uint, sourceIDs []uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return mergeContributors(tx, targetID, sourceIDs)
+	})
+}
+
+// mergeContributors moves the aliases and commits of the source contributors to the target within a transaction
+func mergeContributors(tx *gorm.DB, targetID uint, sourceIDs []uint) error {
+	sources := make([]uint, 0, len(sourceIDs))
+	for _, id := range sourceIDs {
+		if id != targetID {
+			sources = append(sources, id)
+		}
+	}
+	if len(sources) == 0 {
+		return nil
+	}
+
+	if err := tx.First(&models.Contributor{}, targetID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return sql.ErrNoRows
+		}
+		return fmt.Errorf("failed to get contributor: %w", err)
+	}
+
+	err := tx.Model(&models.ContributorAlias{}).
+		Where("contributor_id IN ?", sources).
+		UpdateColumn("contributor_id", targetID).Error
+
+	if err != nil {
+		return fmt.Errorf("failed to move contributor aliases: %w", err)
+	}
+
+	err = tx.Model(&models.Commit{}).
+		Where("contributor_id IN ?", sources).
+		UpdateColumn("contributor_id", targetID).Error
+
+	if err != nil {
+		return fmt.Errorf("failed to move contributor commits: %w", err)
+	}
+
+	if err := tx.Delete(&models.Contributor{}, sources).Error; err != nil {
+		return fmt.Errorf("failed to delete merged contributors: %w", err)
+	}
+	return nil
+}
+
+// ApplyMailmap maps the commit emails of each mailmap entry to the contributor of its proper email,
+// merging contributors that were created for the commit emails before.
+// Entries naming a commit name are applied to the commit email as a whole.
+func (r *ContributorRepo) ApplyMailmap(ctx context.Context, entries []MailmapEntry) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, entry := range entries {
+			if err := applyMailmapEntry(tx, entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// applyMailmapEntry applies a single mailmap entry within a transaction
+func applyMailmapEntry(tx *gorm.DB, entry MailmapEntry) error {
+	properEmail := entry.ProperEmail
+	if properEmail == "" {
+		properEmail = entry.CommitEmail
+	}
+
+	target, err := contributorByAlias(tx, models.AliasEmail, properEmail)
+	if err != nil {
+		return err
+	}
+	if target == nil {
+		target = &models.Contributor{Name: entry.ProperName, Email: properEmail}
+		if target.Name == "" {
+			target.Name = entry.CommitName
+		}
+		if err := tx.Create(target).Error; err != nil {
+			return fmt.Errorf("failed to create contributor: %w", err)
+		}
+		alias := &models.ContributorAlias{ContributorID: target.ID, Kind: models.AliasEmail, Value: properEmail}
+		if err := tx.Create(alias).Error; err != nil {
+			return fmt.Errorf("failed to create contributor alias: %w", err)
+		}
+	}
+
+	updates := map[string]interface{}{"email": properEmail}
+	if entry.ProperName != "" {
+		updates["name"] = entry.ProperName
+	}
+	if err := tx.Model(target).UpdateColumns(updates).Error; err != nil {
+		return fmt.Errorf("failed to update contributor: %w", err)
+	}
+
+	if entry.CommitEmail == properEmail {
+		return nil
+	}
+	source, err := contributorByAlias(tx, models.AliasEmail, entry.CommitEmail)
+	if err != nil {
+		return err
+	}
+	if source != nil {
+		return mergeContributors(tx, target.ID, []uint{source.ID})
+	}
+
+	alias := &models.ContributorAlias{ContributorID: target.ID, Kind: models.AliasEmail, Value: entry.CommitEmail}
+	if err := tx.Create(alias).Error; err != nil {
+		return fmt.Errorf("failed to create contributor alias: %w", err)
+	}
+	return nil
+}
+
+// contributorByAlias returns the contributor an alias maps to, nil when the alias is unknown
+func contributorByAlias(tx *gorm.DB, kind, value string) (*models.Contributor, error) {
+	var contributor models.Contributor
+
+	err := tx.Joins("JOIN contributor_aliases ON contributor_aliases.contributor_id = contributors.id").
+		Where("contributor_aliases.kind = ? AND contributor_aliases.value = ?", kind, strings.ToLower(value)).
+		Where("contributor_aliases.deleted_at IS NULL").
+		First(&contributor).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up contributor alias: %w", err)
+	}
+	return &contributor, nil
+}
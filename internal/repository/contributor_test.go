@@ -0,0 +1,160 @@
+package repository_test
+
+import (
+	"context"
+	"gmonitor/internal/models"
+	"gmonitor/internal/repository"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSaveCommits_AssignsContributors(t *testing.T) {
+	db := setupTestDB(t)
+	commitRepo := repository.NewCommitRepo(db)
+
+	r := models.Repository{Name: "people"}
+	db.Create(&r)
+
+	now := time.Now().UTC()
+	commits := []models.Commit{
+		{CommitHash: "c1", Author: "Jane Doe", AuthorLogin: "jdoe", AuthorEmail: "jane@work.com", CommitDate: now},
+		{CommitHash: "c2", Author: "jane", AuthorLogin: "JDoe", AuthorEmail: "jane@home.com", CommitDate: now},
+		{CommitHash: "c3", Author: "Jane D.", AuthorEmail: "Jane@Home.com", CommitDate: now},
+		{CommitHash: "c4", Author: "Bob", AuthorEmail: "bob@work.com", CommitDate: now},
+	}
+	if err := commitRepo.SaveCommits(context.Background(), r.ID, commits); err != nil {
+		t.Fatalf("failed to save commits: %v", err)
+	}
+
+	top, err := commitRepo.GetTopCommitAuthors(context.Background(), repository.AuthorFilter{Repo: repository.RepositoryName("people")}, 10)
+	if err != nil {
+		t.Fatalf("failed to get top authors: %v", err)
+	}
+	if len(top) != 2 || top[0].Author != "Jane Doe" || top[0].Count != 3 {
+		t.Errorf("expected Jane's three identities to be counted once, got %+v", top)
+	}
+}
+
+func TestApplyMailmap_MergesContributors(t *testing.T) {
+	db := setupTestDB(t)
+	commitRepo := repository.NewCommitRepo(db)
+	contributorRepo := repository.NewContributorRepo(db)
+
+	r := models.Repository{Name: "people"}
+	db.Create(&r)
+
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	commits := []models.Commit{
+		{CommitHash: "c1", Author: "Jane Doe", AuthorEmail: "jane@work.com", CommitDate: start},
+		{CommitHash: "c2", Author: "jane", AuthorEmail: "jane@old.com", CommitDate: start.AddDate(0, 1, 0)},
+	}
+	if err := commitRepo.SaveCommits(context.Background(), r.ID, commits); err != nil {
+		t.Fatalf("failed to save commits: %v", err)
+	}
+
+	entries, err := repository.ParseMailmap(strings.NewReader("# team\nJane Doe <jane@work.com> <JANE@old.com>\n"))
+	if err != nil {
+		t.Fatalf("failed to parse mailmap: %v", err)
+	}
+	if err := contributorRepo.ApplyMailmap(context.Background(), entries); err != nil {
+		t.Fatalf("failed to apply mailmap: %v", err)
+	}
+	// Applying the same mailmap again changes nothing
+	if err := contributorRepo.ApplyMailmap(context.Background(), entries); err != nil {
+		t.Fatalf("failed to reapply mailmap: %v", err)
+	}
+
+	activity, err := contributorRepo.GetActivity(context.Background(), 0, repository.RepositoryName("people"))
+	if err != nil {
+		t.Fatalf("failed to get activity: %v", err)
+	}
+	if len(activity) != 1 || activity[0].Name != "Jane Doe" || activity[0].Commits != 2 {
+		t.Fatalf("expected a single contributor, got %+v", activity)
+	}
+	if !activity[0].FirstCommitAt.Equal(start) || !activity[0].LastCommitAt.Equal(start.AddDate(0, 1, 0)) {
+		t.Errorf("unexpected first and last commit: %+v", activity[0])
+	}
+}
+
+func TestMergeContributors(t *testing.T) {
+	db := setupTestDB(t)
+	commitRepo := repository.NewCommitRepo(db)
+	contributorRepo := repository.NewContributorRepo(db)
+
+	commits := []models.Commit{
+		{CommitHash: "c1", Author: "Jane", AuthorEmail: "jane@work.com", CommitDate: time.Now()},
+		{CommitHash: "c2", Author: "jdoe", AuthorEmail: "jdoe@users.example.com", CommitDate: time.Now()},
+	}
+	if err := commitRepo.SaveCommits(context.Background(), 1, commits); err != nil {
+		t.Fatalf("failed to save commits: %v", err)
+	}
+
+	if err := contributorRepo.Merge(context.Background(), *commits[0].ContributorID, []uint{*commits[1].ContributorID}); err != nil {
+		t.Fatalf("failed to merge contributors: %v", err)
+	}
+
+	contributors, _ := contributorRepo.GetContributors(context.Background(), 10, 0)
+	if len(contributors) != 1 {
+		t.Fatalf("expected one contributor after the merge, got %+v", contributors)
+	}
+	merged, err := contributorRepo.GetContributor(context.Background(), contributors[0].ID)
+	if err != nil || len(merged.Aliases) != 2 {
+		t.Errorf("expected both emails as aliases, got %+v (%v)", merged, err)
+	}
+
+	// New commits of the merged identity go to the surviving contributor
+	later := []models.Commit{{CommitHash: "c3", Author: "jdoe", AuthorEmail: "jdoe@users.example.com", CommitDate: time.Now()}}
+	_ = commitRepo.SaveCommits(context.Background(), 1, later)
+	if later[0].ContributorID == nil || *later[0].ContributorID != merged.ID {
+		t.Errorf("expected the new commit to be assigned to contributor %d, got %v", merged.ID, later[0].ContributorID)
+	}
+}
+
+func TestAssignMissing(t *testing.T) {
+	db := setupTestDB(t)
+	contributorRepo := repository.NewContributorRepo(db)
+
+	db.Create(&[]models.Commit{
+		{CommitHash: "c1", Author: "Jane", AuthorEmail: "jane@work.com", CommitDate: time.Now()},
+		{CommitHash: "c2", Author: "Jane", AuthorEmail: "jane@work.com", CommitDate: time.Now()},
+	})
+
+	assigned, err := contributorRepo.AssignMissing(context.Background())
+	if err != nil {
+		t.Fatalf("failed to assign contributors: %v", err)
+	}
+	if assigned != 2 {
+		t.Errorf("expected 2 assigned commits, got %d", assigned)
+	}
+
+	var missing int64
+	db.Model(&models.Commit{}).Where("contributor_id IS NULL").Count(&missing)
+	if missing != 0 {
+		t.Errorf("expected every commit to have a contributor, %d left", missing)
+	}
+}
+
+func TestParseMailmap(t *testing.T) {
+	entries, err := repository.ParseMailmap(strings.NewReader(
+		"Jane Doe <jane@work.com>\n" +
+			"<jane@work.com> <jane@old.com>\n" +
+			"Jane Doe <jane@work.com> jane <Jane@Home.com> # laptop\n",
+	))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %+v", entries)
+	}
+	if entries[0].ProperName != "Jane Doe" || entries[0].CommitEmail != "jane@work.com" || entries[0].ProperEmail != "" {
+		t.Errorf("unexpected first entry: %+v", entries[0])
+	}
+	if entries[2].CommitName != "jane" || entries[2].CommitEmail != "jane@home.com" {
+		t.Errorf("unexpected last entry: %+v", entries[2])
+	}
+
+	if _, err := repository.ParseMailmap(strings.NewReader("Jane Doe jane@work.com\n")); err == nil {
+		t.Error("expected an error for a line without email")
+	}
+}
@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"gmonitor/internal/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCursor is returned for page cursors that were not issued by GetCommitsPage
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// CommitPage is a page of commits along with the cursors of its neighbouring pages,
+// a cursor is empty when there is no page in that direction
+type CommitPage struct {
+	Commits    []*models.Commit
+	NextCursor string
+	PrevCursor string
+}
+
+// cursor is the position of a commit in the (commit_date, id) ordering of commit listings.
+// Backward cursors point to the page of newer commits before the position, forward ones to the older commits after it.
+type cursor struct {
+	CommitDate time.Time
+	ID         uint
+	Backward   bool
+}
+
+// encode turns the cursor into an opaque token
+func (c cursor) encode() string {
+	direction := "n"
+	if c.Backward {
+		direction = "p"
+	}
+	raw := fmt.Sprintf("%s|%s|%d", direction, c.CommitDate.Format(time.RFC3339Nano), c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor parses a token produced by cursor.encode
+func decodeCursor(token string) (cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return cursor{}, ErrInvalidCursor
+	}
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 3 || (parts[0] != "n" && parts[0] != "p") {
+		return cursor{}, ErrInvalidCursor
+	}
+	date, err := time.Parse(time.RFC3339Nano, parts[1])
+	if err != nil {
+		return cursor{}, ErrInvalidCursor
+	}
+	id, err := strconv.ParseUint(parts[2], 10, 64)
+	if err != nil {
+		return cursor{}, ErrInvalidCursor
+	}
+	return cursor{CommitDate: date, ID: uint(id), Backward: parts[0] == "p"}, nil
+}
+
+// GetCommitsPage retrieves a page of commits of a repository by name, most recent first, starting at a cursor
+// returned with a previous page. An empty cursor starts at the most recent commit. Unlike offsets, cursors keep
+// pointing to the same commits while new ones are stored, so pages neither skip nor repeat commits.
+// A non-empty branch limits the commits to those seen on that branch.
+func (r *CommitRepo) GetCommitsPage(ctx context.Context, repo RepositoryKey, branch, token string, limit int) (*CommitPage, error) {
+	var position *cursor
+	if token != "" {
+		decoded, err := decodeCursor(token)
+		if err != nil {
+			return nil, err
+		}
+		position = &decoded
+	}
+
+	query := r.db.WithContext(ctx).
+		Model(&models.Commit{}).
+		Joins("JOIN repositories ON commits.repo_id = repositories.id").
+		Scopes(inRepository(repo))
+
+	if branch != "" {
+		query = query.
+			Joins("JOIN commit_branches ON commit_branches.commit_id = commits.id").
+			Where("commit_branches.branch = ? AND commit_branches.deleted_at IS NULL", branch)
+	}
+
+	backward := position != nil && position.Backward
+	switch {
+	case backward:
+		query = query.
+			Where("commits.commit_date > ? OR (commits.commit_date = ? AND commits.id > ?)",
+				position.CommitDate, position.CommitDate, position.ID).
+			Order("commits.commit_date ASC, commits.id ASC")
+	case position != nil:
+		query = query.
+			Where("commits.commit_date < ? OR (commits.commit_date = ? AND commits.id < ?)",
+				position.CommitDate, position.CommitDate, position.ID).
+			Order("commits.commit_date DESC, commits.id DESC")
+	default:
+		query = query.Order("commits.commit_date DESC, commits.id DESC")
+	}
+
+	// One extra commit tells whether there is a further page in the direction of travel
+	var commits []*models.Commit
+	if err := query.Limit(limit + 1).Find(&commits).Error; err != nil {
+		return nil, fmt.Errorf("failed to get commits for repository %q: %w", repo, err)
+	}
+	more := len(commits) > limit
+	if more {
+		commits = commits[:limit]
+	}
+	if backward {
+		for i, j := 0, len(commits)-1; i < j; i, j = i+1, j-1 {
+			commits[i], commits[j] = commits[j], commits[i]
+		}
+	}
+
+	page := &CommitPage{Commits: commits}
+	if len(commits) == 0 {
+		return page, nil
+	}
+
+	first, last := commits[0], commits[len(commits)-1]
+	if more || backward {
+		page.NextCursor = cursor{CommitDate: last.CommitDate, ID: last.ID}.encode()
+	}
+	if (backward && more) || (!backward && position != nil) {
+		page.PrevCursor = cursor{CommitDate: first.CommitDate, ID: first.ID, Backward: true}.encode()
+	}
+	return page, nil
+}
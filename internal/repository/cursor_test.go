@@ -0,0 +1,78 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gmonitor/internal/models"
+	"gmonitor/internal/repository"
+	"testing"
+	"time"
+)
+
+func TestGetCommitsPage(t *testing.T) {
+	db := setupTestDB(t)
+	commitRepo := repository.NewCommitRepo(db)
+
+	r := models.Repository{Name: "paged-repo"}
+	db.Create(&r)
+
+	// Five commits, two of them sharing a commit date
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	var commits []models.Commit
+	for i, offset := range []int{0, 1, 2, 2, 3} {
+		commits = append(commits, models.Commit{
+			CommitHash: fmt.Sprintf("c%d", i), Author: "dev", RepoID: r.ID,
+			CommitDate: base.Add(time.Duration(offset) * time.Hour),
+		})
+	}
+	db.Create(&commits)
+
+	hashes := func(page *repository.CommitPage) string {
+		var out string
+		for _, commit := range page.Commits {
+			out += commit.CommitHash + " "
+		}
+		return out
+	}
+
+	first, err := commitRepo.GetCommitsPage(context.Background(), repository.RepositoryName("paged-repo"), "", "", 2)
+	if err != nil {
+		t.Fatalf("failed to get first page: %v", err)
+	}
+	if hashes(first) != "c4 c3 " || first.NextCursor == "" || first.PrevCursor != "" {
+		t.Fatalf("unexpected first page: %s next=%q prev=%q", hashes(first), first.NextCursor, first.PrevCursor)
+	}
+
+	// A commit stored in between does not shift the following pages
+	db.Create(&models.Commit{CommitHash: "new", Author: "dev", RepoID: r.ID, CommitDate: base.Add(4 * time.Hour)})
+
+	second, _ := commitRepo.GetCommitsPage(context.Background(), repository.RepositoryName("paged-repo"), "", first.NextCursor, 2)
+	if hashes(second) != "c2 c1 " || second.NextCursor == "" || second.PrevCursor == "" {
+		t.Fatalf("unexpected second page: %s", hashes(second))
+	}
+
+	last, _ := commitRepo.GetCommitsPage(context.Background(), repository.RepositoryName("paged-repo"), "", second.NextCursor, 2)
+	if hashes(last) != "c0 " || last.NextCursor != "" {
+		t.Fatalf("unexpected last page: %s next=%q", hashes(last), last.NextCursor)
+	}
+
+	back, _ := commitRepo.GetCommitsPage(context.Background(), repository.RepositoryName("paged-repo"), "", last.PrevCursor, 2)
+	if hashes(back) != "c2 c1 " || back.PrevCursor == "" || back.NextCursor == "" {
+		t.Fatalf("unexpected page going back: %s", hashes(back))
+	}
+
+	top, _ := commitRepo.GetCommitsPage(context.Background(), repository.RepositoryName("paged-repo"), "", second.PrevCursor, 2)
+	if hashes(top) != "c4 c3 " || top.PrevCursor == "" {
+		t.Fatalf("unexpected page going back to the top: %s prev=%q", hashes(top), top.PrevCursor)
+	}
+
+	newest, _ := commitRepo.GetCommitsPage(context.Background(), repository.RepositoryName("paged-repo"), "", top.PrevCursor, 2)
+	if hashes(newest) != "new " || newest.PrevCursor != "" {
+		t.Fatalf("expected the new commit before the first page, got %s prev=%q", hashes(newest), newest.PrevCursor)
+	}
+
+	if _, err := commitRepo.GetCommitsPage(context.Background(), repository.RepositoryName("paged-repo"), "", "garbage", 2); !errors.Is(err, repository.ErrInvalidCursor) {
+		t.Errorf("expected ErrInvalidCursor, got %v", err)
+	}
+}
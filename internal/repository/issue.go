@@ -0,0 +1,280 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gmonitor/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxTrendPoints bounds the number of points of an issue trend
+const maxTrendPoints = 1000
+
+// ErrTooManyPoints is returned for trends that would exceed maxTrendPoints
+var ErrTooManyPoints = errors.New("too many points, use a larger interval")
+
+// Intervals an issue trend can be bucketed by
+const (
+	IntervalDay   = "day"
+	IntervalWeek  = "week"
+	IntervalMonth = "month"
+)
+
+// IssueRepo provides database operations for issues
+type IssueRepo struct {
+	db *gorm.DB
+}
+
+// NewIssueRepo creates a new repository instance
+func NewIssueRepo(db *gorm.DB) *IssueRepo {
+	return &IssueRepo{
+		db: db,
+	}
+}
+
+// IssueTrendPoint counts the issues opened and closed in an interval and the issues still open at its end
+type IssueTrendPoint struct {
+	Date   time.Time
+	Open   int
+	Opened int
+	Closed int
+}
+
+// LabelCount counts the open and closed issues carrying a label
+type LabelCount struct {
+	Label  string
+	Open   int
+	Closed int
+}
+
+// IssueStats summarises the issues of a repository opened in a time range
+type IssueStats struct {
+	Open   int
+	Closed int
+	// MedianTimeToClose is the median time between opening and closing of the closed issues, in hours
+	MedianTimeToClose float64
+	Labels            []LabelCount
+}
+
+// SaveIssues stores the issues of a repository, refreshing the ones already known
+func (r *IssueRepo) SaveIssues(ctx context.Context, repoID uint, issues []models.Issue) error {
+	if len(issues) == 0 {
+		return nil
+	}
+	for i := range issues {
+		issues[i].RepoID = repoID
+	}
+
+	err := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "repo_id"}, {Name: "number"}},
+			DoUpdates: clause.AssignmentColumns([]string{
+				"title", "author", "state", "labels", "assignees", "opened_at", "closed_at", "last_activity_at", "updated_at",
+			}),
+		}).
+		CreateInBatches(&issues, 100).Error
+
+	if err != nil {
+		return fmt.Errorf("failed to save issues: %w", err)
+	}
+	return nil
+}
+
+// MarkIssuesSynced moves the issue cursor of a repository forward to the latest update among the given issues
+func (r *IssueRepo) MarkIssuesSynced(ctx context.Context, repo *models.Repository, issues []models.Issue) error {
+	latest := repo.IssuesUpdatedAt
+	for i := range issues {
+		if latest == nil || issues[i].LastActivityAt.After(*latest) {
+			latest = &issues[i].LastActivityAt
+		}
+	}
+	if latest == nil || latest == repo.IssuesUpdatedAt {
+		return nil
+	}
+
+	err := r.db.WithContext(ctx).
+		Model(&models.Repository{}).
+		Where("id = ?", repo.ID).
+		UpdateColumn("issues_updated_at", *latest).Error
+
+	if err != nil {
+		return fmt.Errorf("failed to record issue sync: %w", err)
+	}
+	return nil
+}
+
+// GetIssues retrieves paginated issues of a repository by name, most recently opened first.
+// A non-empty state or label limits the issues to those in that state or carrying that label.
+func (r *IssueRepo) GetIssues(ctx context.Context, repo RepositoryKey, state, label string, limit, offset int) ([]models.Issue, error) {
+	var issues []models.Issue
+
+	query := r.issueQuery(ctx, repo)
+	if state != "" {
+		query = query.Where("issues.state = ?", state)
+	}
+	if label != "" {
+		// Labels are stored as a JSON array of strings
+		query = query.Where(`issues.labels LIKE ? ESCAPE '!'`, "%"+likeEscaper.Replace(quoteJSON(label))+"%")
+	}
+
+	err := query.
+		Order("issues.opened_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&issues).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get issues for repository %q: %w", repo, err)
+	}
+	return issues, nil
+}
+
+// GetIssueTrend counts the open, opened and closed issues of a repository per interval between since and until.
+// A zero since starts at the first stored issue and a zero until ends now.
+func (r *IssueRepo) GetIssueTrend(ctx context.Context, repo RepositoryKey, since, until time.Time, interval string) ([]IssueTrendPoint, error) {
+	next, err := intervalStep(interval)
+	if err != nil {
+		return nil, err
+	}
+	if until.IsZero() {
+		until = time.Now().UTC()
+	}
+
+	var issues []models.Issue
+	err = r.issueQuery(ctx, repo).
+		Select("issues.opened_at", "issues.closed_at").
+		Where("issues.opened_at < ?", until).
+		Order("issues.opened_at").
+		Find(&issues).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get issue trend for repository %q: %w", repo, err)
+	}
+	if since.IsZero() {
+		if len(issues) == 0 {
+			return []IssueTrendPoint{}, nil
+		}
+		since = issues[0].OpenedAt.Truncate(24 * time.Hour)
+	}
+
+	points := make([]IssueTrendPoint, 0)
+	for start := since; start.Before(until); start = next(start) {
+		if len(points) == maxTrendPoints {
+			return nil, fmt.Errorf("issue trend exceeds %d points: %w", maxTrendPoints, ErrTooManyPoints)
+		}
+		end := next(start)
+
+		point := IssueTrendPoint{Date: start}
+		for _, issue := range issues {
+			if !issue.OpenedAt.Before(start) && issue.OpenedAt.Before(end) {
+				point.Opened++
+			}
+			if issue.ClosedAt != nil && !issue.ClosedAt.Before(start) && issue.ClosedAt.Before(end) {
+				point.Closed++
+			}
+			if issue.OpenedAt.Before(end) && (issue.ClosedAt == nil || !issue.ClosedAt.Before(end)) {
+				point.Open++
+			}
+		}
+		points = append(points, point)
+	}
+	return points, nil
+}
+
+// GetIssueStats counts the issues of a repository opened between since and until by state and label
+// and computes their median time to close. Zero bounds leave the range open.
+func (r *IssueRepo) GetIssueStats(ctx context.Context, repo RepositoryKey, since, until time.Time) (*IssueStats, error) {
+	var issues []models.Issue
+
+	query := r.issueQuery(ctx, repo).Select("issues.state", "issues.labels", "issues.opened_at", "issues.closed_at")
+	if !since.IsZero() {
+		query = query.Where("issues.opened_at >= ?", since)
+	}
+	if !until.IsZero() {
+		query = query.Where("issues.opened_at < ?", until)
+	}
+
+	if err := query.Find(&issues).Error; err != nil {
+		return nil, fmt.Errorf("failed to get issue stats for repository %q: %w", repo, err)
+	}
+
+	stats := &IssueStats{Labels: make([]LabelCount, 0)}
+	labels := make(map[string]*LabelCount)
+	var toClose []float64
+	for _, issue := range issues {
+		closed := issue.State == models.IssueClosed
+		if closed {
+			stats.Closed++
+			if issue.ClosedAt != nil {
+				toClose = append(toClose, issue.ClosedAt.Sub(issue.OpenedAt).Hours())
+			}
+		} else {
+			stats.Open++
+		}
+
+		for _, name := range issue.Labels {
+			count, found := labels[name]
+			if !found {
+				count = &LabelCount{Label: name}
+				labels[name] = count
+			}
+			if closed {
+				count.Closed++
+			} else {
+				count.Open++
+			}
+		}
+	}
+
+	if len(toClose) > 0 {
+		sort.Float64s(toClose)
+		stats.MedianTimeToClose = percentile(toClose, 0.5)
+	}
+	for _, count := range labels {
+		stats.Labels = append(stats.Labels, *count)
+	}
+	sort.Slice(stats.Labels, func(i, j int) bool {
+		a, b := stats.Labels[i], stats.Labels[j]
+		if a.Open+a.Closed != b.Open+b.Closed {
+			return a.Open+a.Closed > b.Open+b.Closed
+		}
+		return a.Label < b.Label
+	})
+	return stats, nil
+}
+
+// issueQuery selects the issues of a repository by name
+func (r *IssueRepo) issueQuery(ctx context.Context, repo RepositoryKey) *gorm.DB {
+	return r.db.WithContext(ctx).
+		Model(&models.Issue{}).
+		Joins("JOIN repositories ON issues.repo_id = repositories.id").
+		Scopes(inRepository(repo))
+}
+
+// likeEscaper escapes the wildcards of a LIKE pattern with '!', as backslashes are special in MySQL string literals
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// quoteJSON encodes a string the way it is stored inside a JSON array
+func quoteJSON(value string) string {
+	quoted, _ := json.Marshal(value)
+	return string(quoted)
+}
+
+// intervalStep returns the function advancing a trend by one interval
+func intervalStep(interval string) (func(time.Time) time.Time, error) {
+	switch interval {
+	case IntervalDay:
+		return func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }, nil
+	case IntervalWeek:
+		return func(t time.Time) time.Time { return t.AddDate(0, 0, 7) }, nil
+	case IntervalMonth:
+		return func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }, nil
+	}
+	return nil, fmt.Errorf("unsupported interval: %s", interval)
+}
@@ -0,0 +1,97 @@
+package repository_test
+
+import (
+	"context"
+	"gmonitor/internal/models"
+	"gmonitor/internal/repository"
+	"testing"
+	"time"
+)
+
+// seedIssues stores two issues opened on January 1st and 8th 2023, the first closed after two days
+func seedIssues(t *testing.T, issueRepo *repository.IssueRepo, repoID uint) time.Time {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	closed := start.Add(48 * time.Hour)
+	issues := []models.Issue{
+		{Number: 1, State: models.IssueClosed, Labels: []string{"bug", "good_first_issue"}, OpenedAt: start, ClosedAt: &closed},
+		{Number: 2, State: models.IssueOpen, Labels: []string{"bug"}, OpenedAt: start.AddDate(0, 0, 7)},
+	}
+	if err := issueRepo.SaveIssues(context.Background(), repoID, issues); err != nil {
+		t.Fatalf("failed to save issues: %v", err)
+	}
+	return start
+}
+
+func TestGetIssues_FiltersByLabel(t *testing.T) {
+	db := setupTestDB(t)
+	issueRepo := repository.NewIssueRepo(db)
+
+	r := models.Repository{Name: "issues"}
+	db.Create(&r)
+	seedIssues(t, issueRepo, r.ID)
+
+	labelled, err := issueRepo.GetIssues(context.Background(), repository.RepositoryName("issues"), "", "good_first_issue", 10, 0)
+	if err != nil {
+		t.Fatalf("failed to get issues: %v", err)
+	}
+	if len(labelled) != 1 || labelled[0].Number != 1 {
+		t.Errorf("unexpected labelled issues: %+v", labelled)
+	}
+
+	// Wildcards in labels match literally
+	if wildcard, _ := issueRepo.GetIssues(context.Background(), repository.RepositoryName("issues"), "", "good%", 10, 0); len(wildcard) != 0 {
+		t.Errorf("expected no issues for a wildcard label, got %+v", wildcard)
+	}
+
+	open, _ := issueRepo.GetIssues(context.Background(), repository.RepositoryName("issues"), models.IssueOpen, "", 10, 0)
+	if len(open) != 1 || open[0].Number != 2 {
+		t.Errorf("unexpected open issues: %+v", open)
+	}
+}
+
+func TestGetIssueTrend(t *testing.T) {
+	db := setupTestDB(t)
+	issueRepo := repository.NewIssueRepo(db)
+
+	r := models.Repository{Name: "issues"}
+	db.Create(&r)
+	start := seedIssues(t, issueRepo, r.ID)
+
+	trend, err := issueRepo.GetIssueTrend(context.Background(), repository.RepositoryName("issues"), start, start.AddDate(0, 0, 14), repository.IntervalWeek)
+	if err != nil {
+		t.Fatalf("failed to get issue trend: %v", err)
+	}
+	if len(trend) != 2 {
+		t.Fatalf("expected 2 weeks, got %+v", trend)
+	}
+	if trend[0].Opened != 1 || trend[0].Closed != 1 || trend[0].Open != 0 {
+		t.Errorf("unexpected first week: %+v", trend[0])
+	}
+	if trend[1].Opened != 1 || trend[1].Closed != 0 || trend[1].Open != 1 {
+		t.Errorf("unexpected second week: %+v", trend[1])
+	}
+
+	if _, err := issueRepo.GetIssueTrend(context.Background(), repository.RepositoryName("issues"), start.AddDate(-10, 0, 0), start, repository.IntervalDay); err == nil {
+		t.Error("expected an error for a trend with too many points")
+	}
+}
+
+func TestGetIssueStats(t *testing.T) {
+	db := setupTestDB(t)
+	issueRepo := repository.NewIssueRepo(db)
+
+	r := models.Repository{Name: "issues"}
+	db.Create(&r)
+	seedIssues(t, issueRepo, r.ID)
+
+	stats, err := issueRepo.GetIssueStats(context.Background(), repository.RepositoryName("issues"), time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("failed to get issue stats: %v", err)
+	}
+	if stats.Open != 1 || stats.Closed != 1 || stats.MedianTimeToClose != 48 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if len(stats.Labels) != 2 || stats.Labels[0].Label != "bug" || stats.Labels[0].Open != 1 || stats.Labels[0].Closed != 1 {
+		t.Errorf("unexpected label breakdown: %+v", stats.Labels)
+	}
+}
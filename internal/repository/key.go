@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"errors"
+	"gmonitor/internal/models"
+	"gorm.io/gorm"
+)
+
+// ErrAmbiguousRepository is returned when a repository name without provider and host matches repositories of
+// several hosts
+var ErrAmbiguousRepository = errors.New("repository name is tracked on several hosts")
+
+// RepositoryKey identifies a repository by the provider and host serving it and its owner/name.
+// Hosts are stored canonical, see fetcher.CanonicalHost, so the default host of a provider is empty.
+// A key with a provider matches its host exactly. Without a provider an empty host matches any, which is enough as
+// long as the name is tracked on a single host.
+type RepositoryKey struct {
+	Provider string
+	Host     string
+	Name     string
+}
+
+// RepositoryName is the key of a repository known by name alone
+func RepositoryName(name string) RepositoryKey {
+	return RepositoryKey{Name: name}
+}
+
+// String formats the key for messages and cache keys, the provider and host are only mentioned when given
+func (k RepositoryKey) String() string {
+	name := k.Name
+	if k.Host != "" {
+		name = k.Host + "/" + name
+	}
+	if k.Provider != "" {
+		name = k.Provider + ":" + name
+	}
+	return name
+}
+
+// where adds the conditions of the key on a query of the repositories table
+func (k RepositoryKey) where(query *gorm.DB, table string) *gorm.DB {
+	query = query.Where(table+".name = ?", k.Name)
+	if k.Provider != "" {
+		query = query.Where(table+".provider = ?", k.Provider)
+	}
+	if k.Provider != "" || k.Host != "" {
+		query = query.Where(table+".host = ?", k.Host)
+	}
+	return query
+}
+
+// inRepository is a scope limiting a query joined with the repositories table to the repository of key.
+// Queries fail with ErrAmbiguousRepository when the key matches repositories of several hosts.
+func inRepository(key RepositoryKey) func(*gorm.DB) *gorm.DB {
+	return func(query *gorm.DB) *gorm.DB {
+		if key.Provider == "" && key.Host == "" {
+			var ids []uint
+			lookup := key.where(query.Session(&gorm.Session{NewDB: true}).Model(&models.Repository{}), "repositories")
+			if err := lookup.Limit(2).Pluck("repositories.id", &ids).Error; err != nil {
+				_ = query.AddError(err)
+				return query
+			}
+			if len(ids) > 1 {
+				_ = query.AddError(ErrAmbiguousRepository)
+				return query
+			}
+		}
+		return key.where(query, "repositories")
+	}
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// MailmapEntry is a line of a .mailmap file mapping a commit email to a proper name and email
+type MailmapEntry struct {
+	ProperName  string
+	ProperEmail string
+	CommitName  string
+	CommitEmail string
+}
+
+// ParseMailmap reads the entries of a .mailmap file. Emails are lower cased, as git matches them case-insensitively.
+//
+// Supported forms are
+//
+//	Proper Name <commit@email>
+//	<proper@email> <commit@email>
+//	Proper Name <proper@email> <commit@email>
+//	Proper Name <proper@email> Commit Name <commit@email>
+func ParseMailmap(r io.Reader) ([]MailmapEntry, error) {
+	entries := make([]MailmapEntry, 0)
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if i := strings.Index(text, "#"); i >= 0 {
+			text = text[:i]
+		}
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+
+		var names, emails []string
+		for rest := text; ; {
+			open := strings.Index(rest, "<")
+			if open < 0 {
+				if strings.TrimSpace(rest) != "" {
+					return nil, fmt.Errorf("mailmap line %d: text after the last email", line)
+				}
+				break
+			}
+			end := strings.Index(rest[open:], ">")
+			if end < 0 {
+				return nil, fmt.Errorf("mailmap line %d: unterminated email", line)
+			}
+			names = append(names, strings.TrimSpace(rest[:open]))
+			emails = append(emails, strings.ToLower(strings.TrimSpace(rest[open+1:open+end])))
+			rest = rest[open+end+1:]
+		}
+
+		var entry MailmapEntry
+		switch len(emails) {
+		case 1:
+			entry = MailmapEntry{ProperName: names[0], CommitEmail: emails[0]}
+		case 2:
+			entry = MailmapEntry{ProperName: names[0], ProperEmail: emails[0], CommitName: names[1], CommitEmail: emails[1]}
+		default:
+			return nil, fmt.Errorf("mailmap line %d: expected one or two emails, got %d", line, len(emails))
+		}
+		if entry.CommitEmail == "" {
+			return nil, fmt.Errorf("mailmap line %d: empty commit email", line)
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read mailmap: %w", err)
+	}
+	return entries, nil
+}
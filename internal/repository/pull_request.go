@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"gmonitor/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"math"
+	"sort"
+	"time"
+)
+
+// PullRequestRepo provides database operations for pull requests
+type PullRequestRepo struct {
+	db *gorm.DB
+}
+
+// NewPullRequestRepo creates a new repository instance
+func NewPullRequestRepo(db *gorm.DB) *PullRequestRepo {
+	return &PullRequestRepo{
+		db: db,
+	}
+}
+
+// Distribution summarises a set of durations, in hours
+type Distribution struct {
+	Count  int
+	Mean   float64
+	Median float64
+	P75    float64
+	P90    float64
+	Max    float64
+}
+
+// PullRequestStats holds the review and merge durations of the pull requests opened in a time range
+type PullRequestStats struct {
+	TimeToFirstReview Distribution
+	TimeToMerge       Distribution
+}
+
+// SavePullRequests stores the pull requests of a repository, refreshing the ones already known
+func (r *PullRequestRepo) SavePullRequests(ctx context.Context, repoID uint, pulls []models.PullRequest) error {
+	if len(pulls) == 0 {
+		return nil
+	}
+	for i := range pulls {
+		pulls[i].RepoID = repoID
+	}
+
+	err := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "repo_id"}, {Name: "number"}},
+			DoUpdates: clause.AssignmentColumns([]string{
+				"title", "author", "state", "base_branch", "head_branch", "merge_commit_sha", "additions", "deletions",
+				"review_count", "opened_at", "first_review_at", "merged_at", "closed_at", "last_activity_at", "updated_at",
+			}),
+		}).
+		CreateInBatches(&pulls, 100).Error
+
+	if err != nil {
+		return fmt.Errorf("failed to save pull requests: %w", err)
+	}
+	return nil
+}
+
+// MarkPullRequestsSynced moves the pull request cursor of a repository forward to the latest update among the given pull requests
+func (r *PullRequestRepo) MarkPullRequestsSynced(ctx context.Context, repo *models.Repository, pulls []models.PullRequest) error {
+	latest := repo.PullRequestsUpdatedAt
+	for i := range pulls {
+		if latest == nil || pulls[i].LastActivityAt.After(*latest) {
+			latest = &pulls[i].LastActivityAt
+		}
+	}
+	if latest == nil || latest == repo.PullRequestsUpdatedAt {
+		return nil
+	}
+
+	err := r.db.WithContext(ctx).
+		Model(&models.Repository{}).
+		Where("id = ?", repo.ID).
+		UpdateColumn("pull_requests_updated_at", *latest).Error
+
+	if err != nil {
+		return fmt.Errorf("failed to record pull request sync: %w", err)
+	}
+	return nil
+}
+
+// GetPullRequests retrieves paginated pull requests of a repository by name, most recently opened first.
+// A non-empty state limits the pull requests to those in that state.
+func (r *PullRequestRepo) GetPullRequests(ctx context.Context, repo RepositoryKey, state string, limit, offset int) ([]models.PullRequest, error) {
+	var pulls []models.PullRequest
+
+	query := r.db.WithContext(ctx).
+		Model(&models.PullRequest{}).
+		Joins("JOIN repositories ON pull_requests.repo_id = repositories.id").
+		Scopes(inRepository(repo))
+
+	if state != "" {
+		query = query.Where("pull_requests.state = ?", state)
+	}
+
+	err := query.
+		Order("pull_requests.opened_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&pulls).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pull requests for repository %q: %w", repo, err)
+	}
+	return pulls, nil
+}
+
+// GetPullRequestStats computes the time to first review and the time to merge of the pull requests
+// of a repository opened between since and until. Zero bounds leave the range open.
+func (r *PullRequestRepo) GetPullRequestStats(ctx context.Context, repo RepositoryKey, since, until time.Time) (*PullRequestStats, error) {
+	var pulls []models.PullRequest
+
+	query := r.db.WithContext(ctx).
+		Model(&models.PullRequest{}).
+		Select("pull_requests.opened_at", "pull_requests.first_review_at", "pull_requests.merged_at").
+		Joins("JOIN repositories ON pull_requests.repo_id = repositories.id").
+		Scopes(inRepository(repo))
+
+	if !since.IsZero() {
+		query = query.Where("pull_requests.opened_at >= ?", since)
+	}
+	if !until.IsZero() {
+		query = query.Where("pull_requests.opened_at < ?", until)
+	}
+
+	if err := query.Find(&pulls).Error; err != nil {
+		return nil, fmt.Errorf("failed to get pull request stats for repository %q: %w", repo, err)
+	}
+
+	var toReview, toMerge []float64
+	for _, pull := range pulls {
+		if pull.FirstReviewAt != nil {
+			toReview = append(toReview, pull.FirstReviewAt.Sub(pull.OpenedAt).Hours())
+		}
+		if pull.MergedAt != nil {
+			toMerge = append(toMerge, pull.MergedAt.Sub(pull.OpenedAt).Hours())
+		}
+	}
+
+	return &PullRequestStats{
+		TimeToFirstReview: distribution(toReview),
+		TimeToMerge:       distribution(toMerge),
+	}, nil
+}
+
+// distribution summarises the given durations in hours
+func distribution(hours []float64) Distribution {
+	if len(hours) == 0 {
+		return Distribution{}
+	}
+	sort.Float64s(hours)
+
+	sum := 0.0
+	for _, h := range hours {
+		sum += h
+	}
+	return Distribution{
+		Count:  len(hours),
+		Mean:   sum / float64(len(hours)),
+		Median: percentile(hours, 0.5),
+		P75:    percentile(hours, 0.75),
+		P90:    percentile(hours, 0.9),
+		Max:    hours[len(hours)-1],
+	}
+}
+
+// percentile interpolates the p-th percentile of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	rank := p * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
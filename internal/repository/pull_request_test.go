@@ -0,0 +1,80 @@
+package repository_test
+
+import (
+	"context"
+	"gmonitor/internal/models"
+	"gmonitor/internal/repository"
+	"testing"
+	"time"
+)
+
+func TestSavePullRequests_UpsertsAndMovesCursor(t *testing.T) {
+	db := setupTestDB(t)
+	pullRepo := repository.NewPullRequestRepo(db)
+	repoStore := repository.NewRepositoryRepo(db)
+
+	r := models.Repository{Name: "pulls"}
+	db.Create(&r)
+
+	opened := time.Now().UTC().Add(-48 * time.Hour)
+	open := []models.PullRequest{{Number: 1, State: models.PullRequestOpen, OpenedAt: opened, LastActivityAt: opened}}
+	if err := pullRepo.SavePullRequests(context.Background(), r.ID, open); err != nil {
+		t.Fatalf("failed to save pull requests: %v", err)
+	}
+
+	merged := opened.Add(24 * time.Hour)
+	updated := []models.PullRequest{{Number: 1, State: models.PullRequestMerged, OpenedAt: opened, MergedAt: &merged, LastActivityAt: merged}}
+	if err := pullRepo.SavePullRequests(context.Background(), r.ID, updated); err != nil {
+		t.Fatalf("failed to update pull requests: %v", err)
+	}
+	if err := pullRepo.MarkPullRequestsSynced(context.Background(), &r, updated); err != nil {
+		t.Fatalf("failed to move pull request cursor: %v", err)
+	}
+
+	pulls, err := pullRepo.GetPullRequests(context.Background(), repository.RepositoryName("pulls"), models.PullRequestMerged, 10, 0)
+	if err != nil {
+		t.Fatalf("failed to get pull requests: %v", err)
+	}
+	if len(pulls) != 1 || pulls[0].MergedAt == nil {
+		t.Errorf("expected the pull request to be merged, got %+v", pulls)
+	}
+
+	synced, _ := repoStore.GetRepository(context.Background(), repository.RepositoryName("pulls"))
+	if synced.PullRequestsUpdatedAt == nil || !synced.PullRequestsUpdatedAt.Equal(merged) {
+		t.Errorf("expected cursor at %v, got %v", merged, synced.PullRequestsUpdatedAt)
+	}
+}
+
+func TestGetPullRequestStats(t *testing.T) {
+	db := setupTestDB(t)
+	pullRepo := repository.NewPullRequestRepo(db)
+
+	r := models.Repository{Name: "pulls"}
+	db.Create(&r)
+
+	opened := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(hours int) *time.Time {
+		ts := opened.Add(time.Duration(hours) * time.Hour)
+		return &ts
+	}
+	pulls := []models.PullRequest{
+		{Number: 1, OpenedAt: opened, FirstReviewAt: at(1), MergedAt: at(10)},
+		{Number: 2, OpenedAt: opened, FirstReviewAt: at(3), MergedAt: at(20)},
+		{Number: 3, OpenedAt: opened, FirstReviewAt: at(5)},
+		{Number: 4, OpenedAt: opened.AddDate(1, 0, 0), FirstReviewAt: &opened},
+	}
+	if err := pullRepo.SavePullRequests(context.Background(), r.ID, pulls); err != nil {
+		t.Fatalf("failed to save pull requests: %v", err)
+	}
+
+	stats, err := pullRepo.GetPullRequestStats(context.Background(), repository.RepositoryName("pulls"), opened, opened.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatalf("failed to get pull request stats: %v", err)
+	}
+	if stats.TimeToFirstReview.Count != 3 || stats.TimeToFirstReview.Median != 3 || stats.TimeToFirstReview.Max != 5 {
+		t.Errorf("unexpected time to first review: %+v", stats.TimeToFirstReview)
+	}
+	if stats.TimeToMerge.Count != 2 || stats.TimeToMerge.Mean != 15 || stats.TimeToMerge.P90 != 19 {
+		t.Errorf("unexpected time to merge: %+v", stats.TimeToMerge)
+	}
+}
@@ -0,0 +1,568 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"gmonitor/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// ReleaseRepo provides database operations for tags and releases
+type ReleaseRepo struct {
+	db *gorm.DB
+}
+
+// NewReleaseRepo creates a new repository instance
+func NewReleaseRepo(db *gorm.DB) *ReleaseRepo {
+	return &ReleaseRepo{
+		db: db,
+	}
+}
+
+// SaveTags stores the tags of a repository, moving tags that point to a new commit
+func (r *ReleaseRepo) SaveTags(ctx context.Context, repoID uint, tags []models.Tag) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	for i := range tags {
+		tags[i].RepoID = repoID
+	}
+
+	err := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "repo_id"}, {Name: "name"}},
+			DoUpdates: clause.AssignmentColumns([]string{"target_sha", "updated_at"}),
+		}).
+		CreateInBatches(&tags, 100).Error
+
+	if err != nil {
+		return fmt.Errorf("failed to save tags: %w", err)
+	}
+	return nil
+}
+
+// DeleteTag removes a tag deleted on the provider, the release published for it is kept
+func (r *ReleaseRepo) DeleteTag(ctx context.Context, repoID uint, name string) error {
+	err := r.db.WithContext(ctx).
+		Unscoped().
+		Where("repo_id = ? AND name = ?", repoID, name).
+		Delete(&models.Tag{}).Error
+
+	if err != nil {
+		return fmt.Errorf("failed to delete tag %q: %w", name, err)
+	}
+	return nil
+}
+
+// SaveReleases stores the releases of a repository, refreshing the ones already known.
+// A release that is new or ships other commits than before has the commits attributed again from scratch.
+func (r *ReleaseRepo) SaveReleases(ctx context.Context, repoID uint, releases []models.Release) error {
+	if len(releases) == 0 {
+		return nil
+	}
+	for i := range releases {
+		releases[i].RepoID = repoID
+	}
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var stored []models.Release
+		err := tx.Select("tag_name", "target_sha", "draft", "prerelease", "published_at").
+			Where("repo_id = ?", repoID).
+			Find(&stored).Error
+
+		if err != nil {
+			return fmt.Errorf("failed to get stored releases: %w", err)
+		}
+		known := make(map[string]models.Release, len(stored))
+		for _, release := range stored {
+			known[release.TagName] = release
+		}
+		changed := false
+		for _, release := range releases {
+			previous, found := known[release.TagName]
+			changed = changed || !found || !shipsSameCommits(&previous, &release)
+		}
+
+		err = tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "repo_id"}, {Name: "tag_name"}},
+			DoUpdates: clause.AssignmentColumns([]string{
+				"name", "target_sha", "draft", "prerelease", "notes", "url", "published_at", "updated_at",
+			}),
+		}).
+			CreateInBatches(&releases, 100).Error
+
+		if err != nil {
+			return fmt.Errorf("failed to save releases: %w", err)
+		}
+		if !changed {
+			return nil
+		}
+
+		err = tx.Model(&models.Repository{}).
+			Where("id = ?", repoID).
+			UpdateColumn("releases_resolved_up_to", 0).Error
+
+		if err != nil {
+			return fmt.Errorf("failed to reset release resolution progress: %w", err)
+		}
+		return nil
+	})
+}
+
+// shipsSameCommits reports whether two versions of a release claim the same commits
+func shipsSameCommits(a, b *models.Release) bool {
+	if a.TargetSHA != b.TargetSHA || a.Draft != b.Draft || a.Prerelease != b.Prerelease {
+		return false
+	}
+	if a.PublishedAt == nil || b.PublishedAt == nil {
+		return a.PublishedAt == b.PublishedAt
+	}
+	return a.PublishedAt.Equal(*b.PublishedAt)
+}
+
+// GetTagTargets maps the tag names of a repository to the commits they point to
+func (r *ReleaseRepo) GetTagTargets(ctx context.Context, repoID uint) (map[string]string, error) {
+	var tags []models.Tag
+
+	err := r.db.WithContext(ctx).
+		Select("name", "target_sha").
+		Where("repo_id = ?", repoID).
+		Find(&tags).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tags: %w", err)
+	}
+
+	targets := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		targets[tag.Name] = tag.TargetSHA
+	}
+	return targets, nil
+}
+
+// ResolveReleaseCommits points the stored commits to the earliest published release whose tag reaches them through
+// the stored parents, prereleases and drafts aside. Attribution follows reachability rather than dates, so releases
+// of maintenance branches and releases whose target commit is stored late are attributed like the others.
+// The whole history is only walked when the releases changed or the target of a release was stored since. Otherwise
+// the commits stored since the last resolution are walked, along with their ancestors down to the commits already
+// shipped in the same or an earlier release. It returns the number of commits whose release changed.
+func (r *ReleaseRepo) ResolveReleaseCommits(ctx context.Context, repoID uint) (int, error) {
+	var repo models.Repository
+	err := r.db.WithContext(ctx).
+		Select("id", "releases_resolved_up_to").
+		First(&repo, repoID).Error
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to get release resolution progress: %w", err)
+	}
+
+	var newest uint
+	err = r.db.WithContext(ctx).
+		Model(&models.Commit{}).
+		Where("repo_id = ?", repoID).
+		Select("COALESCE(MAX(id), 0)").
+		Scan(&newest).Error
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to get newest commit: %w", err)
+	}
+
+	var releases []models.Release
+	err = r.db.WithContext(ctx).
+		Where("repo_id = ? AND draft = ? AND prerelease = ?", repoID, false, false).
+		Where("target_sha <> '' AND published_at IS NOT NULL").
+		Order("published_at, id").
+		Find(&releases).Error
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to get releases: %w", err)
+	}
+
+	// Releases stay unresolved until their target is stored
+	full := repo.ReleasesResolvedUpTo == 0
+	var unresolved []string
+	for _, release := range releases {
+		if !release.CommitsResolved {
+			unresolved = append(unresolved, release.TargetSHA)
+		}
+	}
+	if !full && len(unresolved) > 0 {
+		var stored int64
+		err := r.db.WithContext(ctx).
+			Model(&models.Commit{}).
+			Where("repo_id = ? AND commit_hash IN ?", repoID, unresolved).
+			Count(&stored).Error
+
+		if err != nil {
+			return 0, fmt.Errorf("failed to look up release targets: %w", err)
+		}
+		full = stored > 0
+	}
+	if !full && newest == repo.ReleasesResolvedUpTo {
+		return 0, nil
+	}
+
+	var walk releaseWalk
+	if full {
+		walk, err = r.walkHistory(ctx, repoID, releases)
+	} else {
+		walk, err = r.walkNewCommits(ctx, repoID, repo.ReleasesResolvedUpTo, releases)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	changed := len(walk.unshipped)
+	for _, ids := range walk.moved {
+		changed += len(ids)
+	}
+
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for releaseID, ids := range walk.moved {
+			if err := assignRelease(tx, ids, &releaseID); err != nil {
+				return err
+			}
+		}
+		if err := assignRelease(tx, walk.unshipped, nil); err != nil {
+			return err
+		}
+
+		if len(walk.resolved) > 0 {
+			err := tx.Model(&models.Release{}).
+				Where("id IN ?", walk.resolved).
+				UpdateColumn("commits_resolved", true).Error
+
+			if err != nil {
+				return fmt.Errorf("failed to mark releases resolved: %w", err)
+			}
+		}
+
+		err := tx.Model(&models.Repository{}).
+			Where("id = ?", repoID).
+			UpdateColumn("releases_resolved_up_to", newest).Error
+
+		if err != nil {
+			return fmt.Errorf("failed to record release resolution progress: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return changed, nil
+}
+
+// releaseWalk holds the changes a walk of the history makes to the attribution of commits to releases
+type releaseWalk struct {
+	// moved groups the commits that were shipped in another release or in none by their new release
+	moved map[uint][]uint
+	// unshipped are the commits no release reaches any more
+	unshipped []uint
+	// resolved are the releases whose target was found
+	resolved []uint
+}
+
+// walkHistory attributes every stored commit of a repository to the releases
+func (r *ReleaseRepo) walkHistory(ctx context.Context, repoID uint, releases []models.Release) (releaseWalk, error) {
+	var commits []models.Commit
+	err := r.db.WithContext(ctx).
+		Select("id", "commit_hash", "parents", "first_release_id").
+		Where("repo_id = ?", repoID).
+		Find(&commits).Error
+
+	if err != nil {
+		return releaseWalk{}, fmt.Errorf("failed to get commit history: %w", err)
+	}
+
+	byHash := make(map[string]int, len(commits))
+	for i, commit := range commits {
+		byHash[commit.CommitHash] = i
+	}
+
+	// Walking the releases in publishing order, a commit reached by an earlier release has its ancestors reached too
+	shipped := make(map[int]uint, len(commits))
+	walk := releaseWalk{moved: make(map[uint][]uint), resolved: make([]uint, 0, len(releases))}
+	for _, release := range releases {
+		target, found := byHash[release.TargetSHA]
+		if !found {
+			continue
+		}
+		walk.resolved = append(walk.resolved, release.ID)
+
+		frontier := []int{target}
+		for len(frontier) > 0 {
+			i := frontier[len(frontier)-1]
+			frontier = frontier[:len(frontier)-1]
+			if _, seen := shipped[i]; seen {
+				continue
+			}
+			shipped[i] = release.ID
+			for _, parent := range commits[i].Parents {
+				if j, stored := byHash[parent]; stored {
+					frontier = append(frontier, j)
+				}
+			}
+		}
+	}
+
+	// Only the commits whose release changed are written
+	for i, commit := range commits {
+		releaseID, found := shipped[i]
+		switch {
+		case found && (commit.FirstReleaseID == nil || *commit.FirstReleaseID != releaseID):
+			walk.moved[releaseID] = append(walk.moved[releaseID], commit.ID)
+		case !found && commit.FirstReleaseID != nil:
+			walk.unshipped = append(walk.unshipped, commit.ID)
+		}
+	}
+	return walk, nil
+}
+
+// walkedCommit is a commit reached by the walk of the commits stored since the last resolution
+type walkedCommit struct {
+	id      uint
+	parents []string
+	// rank is the position of the release that shipped the commit in publishing order, len(releases) for none
+	rank     int
+	original int
+}
+
+// releaseSeed carries the release rank a walk hands down to a commit
+type releaseSeed struct {
+	hash string
+	rank int
+}
+
+// parentLookupBatch caps the hashes matched against the parents of the stored commits by a single query
+const parentLookupBatch = 100
+
+// walkNewCommits attributes the commits stored after upTo while the releases are unchanged, so commits only ever
+// move to an earlier release. The new commits take the release of the commits naming them as parents, which is how
+// older history stored late is reached, and hand their release down to their ancestors. The walk stops at the
+// commits already shipped in the same or an earlier release, the stored history is only loaded as far as it goes.
+func (r *ReleaseRepo) walkNewCommits(ctx context.Context, repoID, upTo uint, releases []models.Release) (releaseWalk, error) {
+	walk := releaseWalk{moved: make(map[uint][]uint)}
+	if len(releases) == 0 {
+		return walk, nil
+	}
+
+	unshipped := len(releases)
+	ranks := make(map[uint]int, len(releases))
+	for i, release := range releases {
+		ranks[release.ID] = i
+	}
+	rankOf := func(releaseID *uint) int {
+		if releaseID == nil {
+			return unshipped
+		}
+		if rank, found := ranks[*releaseID]; found {
+			return rank
+		}
+		return unshipped
+	}
+
+	walked := make(map[string]*walkedCommit)
+	remember := func(commit models.Commit) {
+		rank := rankOf(commit.FirstReleaseID)
+		walked[commit.CommitHash] = &walkedCommit{id: commit.ID, parents: commit.Parents, rank: rank, original: rank}
+	}
+
+	var added []models.Commit
+	err := r.db.WithContext(ctx).
+		Select("id", "commit_hash", "parents", "first_release_id").
+		Where("repo_id = ? AND id > ?", repoID, upTo).
+		Find(&added).Error
+
+	if err != nil {
+		return walk, fmt.Errorf("failed to get new commits: %w", err)
+	}
+
+	var seeds []releaseSeed
+	hashes := make([]string, 0, len(added))
+	for _, commit := range added {
+		remember(commit)
+		hashes = append(hashes, commit.CommitHash)
+		// Commits whose parents were only filled in since hand their release down
+		if rank := rankOf(commit.FirstReleaseID); rank < unshipped {
+			for _, parent := range commit.Parents {
+				seeds = append(seeds, releaseSeed{hash: parent, rank: rank})
+			}
+		}
+	}
+
+	// Parents are stored as a JSON array, which a quoted hash only matches as a whole element
+	for start := 0; start < len(hashes); start += parentLookupBatch {
+		end := min(start+parentLookupBatch, len(hashes))
+		named := r.db.Where("parents LIKE ?", `%"`+hashes[start]+`"%`)
+		for _, hash := range hashes[start+1 : end] {
+			named = named.Or("parents LIKE ?", `%"`+hash+`"%`)
+		}
+
+		var children []models.Commit
+		err := r.db.WithContext(ctx).
+			Select("parents", "first_release_id").
+			Where("repo_id = ? AND id <= ? AND first_release_id IS NOT NULL", repoID, upTo).
+			Where(named).
+			Find(&children).Error
+
+		if err != nil {
+			return walk, fmt.Errorf("failed to look up children of new commits: %w", err)
+		}
+		for _, child := range children {
+			for _, parent := range child.Parents {
+				seeds = append(seeds, releaseSeed{hash: parent, rank: rankOf(child.FirstReleaseID)})
+			}
+		}
+	}
+
+	missing := make(map[string]bool)
+	for len(seeds) > 0 {
+		var unknown []string
+		for _, seed := range seeds {
+			if _, found := walked[seed.hash]; !found && !missing[seed.hash] {
+				unknown = append(unknown, seed.hash)
+				missing[seed.hash] = true
+			}
+		}
+		for start := 0; start < len(unknown); start += hashLookupBatch {
+			end := min(start+hashLookupBatch, len(unknown))
+			var ancestors []models.Commit
+			err := r.db.WithContext(ctx).
+				Select("id", "commit_hash", "parents", "first_release_id").
+				Where("repo_id = ? AND commit_hash IN ?", repoID, unknown[start:end]).
+				Find(&ancestors).Error
+
+			if err != nil {
+				return walk, fmt.Errorf("failed to get ancestors of new commits: %w", err)
+			}
+			for _, ancestor := range ancestors {
+				remember(ancestor)
+				delete(missing, ancestor.CommitHash)
+			}
+		}
+
+		var next []releaseSeed
+		for _, seed := range seeds {
+			commit, found := walked[seed.hash]
+			if !found || seed.rank >= commit.rank {
+				continue
+			}
+			commit.rank = seed.rank
+			for _, parent := range commit.parents {
+				next = append(next, releaseSeed{hash: parent, rank: seed.rank})
+			}
+		}
+		seeds = next
+	}
+
+	for _, commit := range walked {
+		if commit.rank < commit.original {
+			releaseID := releases[commit.rank].ID
+			walk.moved[releaseID] = append(walk.moved[releaseID], commit.id)
+		}
+	}
+	return walk, nil
+}
+
+// assignRelease points commits to the release that first shipped them, a nil release marks them unreleased
+func assignRelease(tx *gorm.DB, ids []uint, releaseID *uint) error {
+	for start := 0; start < len(ids); start += hashLookupBatch {
+		end := min(start+hashLookupBatch, len(ids))
+		err := tx.Model(&models.Commit{}).
+			Where("id IN ?", ids[start:end]).
+			UpdateColumn("first_release_id", releaseID).Error
+
+		if err != nil {
+			return fmt.Errorf("failed to assign commits to releases: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetReleases retrieves the most recent releases of a repository by name
+func (r *ReleaseRepo) GetReleases(ctx context.Context, repo RepositoryKey, limit int) ([]models.Release, error) {
+	var releases []models.Release
+
+	err := r.db.WithContext(ctx).
+		Model(&models.Release{}).
+		Joins("JOIN repositories ON releases.repo_id = repositories.id").
+		Scopes(inRepository(repo)).
+		Order("releases.published_at DESC").
+		Limit(limit).
+		Find(&releases).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get releases for repository %q: %w", repo, err)
+	}
+	return releases, nil
+}
+
+// GetRelease retrieves a release of a repository by its tag name
+func (r *ReleaseRepo) GetRelease(ctx context.Context, repo RepositoryKey, tagName string) (*models.Release, error) {
+	var release models.Release
+
+	err := r.db.WithContext(ctx).
+		Model(&models.Release{}).
+		Joins("JOIN repositories ON releases.repo_id = repositories.id").
+		Scopes(inRepository(repo)).
+		Where("releases.tag_name = ?", tagName).
+		First(&release).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, sql.ErrNoRows
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get release: %w", err)
+	}
+
+	return &release, nil
+}
+
+// GetReleaseCommits retrieves the commits first shipped in a release
+func (r *ReleaseRepo) GetReleaseCommits(ctx context.Context, releaseID uint) ([]*models.Commit, error) {
+	var commits []*models.Commit
+
+	err := r.db.WithContext(ctx).
+		Where("first_release_id = ?", releaseID).
+		Order("commit_date DESC").
+		Find(&commits).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get commits of release: %w", err)
+	}
+	return commits, nil
+}
+
+// GetTags retrieves the tags of a repository by name
+func (r *ReleaseRepo) GetTags(ctx context.Context, repo RepositoryKey) ([]models.Tag, error) {
+	var tags []models.Tag
+
+	err := r.db.WithContext(ctx).
+		Model(&models.Tag{}).
+		Joins("JOIN repositories ON tags.repo_id = repositories.id").
+		Scopes(inRepository(repo)).
+		Order("tags.name").
+		Find(&tags).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tags for repository %q: %w", repo, err)
+	}
+	return tags, nil
+}
+
+// MarkReleasesSynced records when the tags and releases of a repository were last fetched
+func (r *ReleaseRepo) MarkReleasesSynced(ctx context.Context, repoID uint, syncedAt time.Time) error {
+	err := r.db.WithContext(ctx).
+		Model(&models.Repository{}).
+		Where("id = ?", repoID).
+		UpdateColumn("releases_synced_at", syncedAt).Error
+
+	if err != nil {
+		return fmt.Errorf("failed to record release sync: %w", err)
+	}
+	return nil
+}
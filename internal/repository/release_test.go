@@ -0,0 +1,208 @@
+package repository_test
+
+import (
+	"context"
+	"gmonitor/internal/models"
+	"gmonitor/internal/repository"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSaveReleases_Upserts(t *testing.T) {
+	db := setupTestDB(t)
+	releaseRepo := repository.NewReleaseRepo(db)
+
+	r := models.Repository{Name: "released"}
+	db.Create(&r)
+
+	if err := releaseRepo.SaveReleases(context.Background(), r.ID, []models.Release{{TagName: "v1.0", Draft: true}}); err != nil {
+		t.Fatalf("failed to save releases: %v", err)
+	}
+	published := time.Now().UTC()
+	releases := []models.Release{{TagName: "v1.0", Name: "One", PublishedAt: &published}}
+	if err := releaseRepo.SaveReleases(context.Background(), r.ID, releases); err != nil {
+		t.Fatalf("failed to update releases: %v", err)
+	}
+
+	stored, err := releaseRepo.GetReleases(context.Background(), repository.RepositoryName("released"), 10)
+	if err != nil {
+		t.Fatalf("failed to get releases: %v", err)
+	}
+	if len(stored) != 1 || stored[0].Draft || stored[0].Name != "One" {
+		t.Errorf("unexpected releases: %+v", stored)
+	}
+}
+
+func TestResolveReleaseCommits(t *testing.T) {
+	db := setupTestDB(t)
+	commitRepo := repository.NewCommitRepo(db)
+	releaseRepo := repository.NewReleaseRepo(db)
+
+	r := models.Repository{Name: "released"}
+	db.Create(&r)
+
+	now := time.Now().UTC()
+	commits := []models.Commit{
+		{CommitHash: "a", CommitDate: now.Add(-4 * time.Hour)},
+		{CommitHash: "b", CommitDate: now.Add(-3 * time.Hour), Parents: []string{"a"}},
+		{CommitHash: "c", CommitDate: now.Add(-2 * time.Hour), Parents: []string{"b"}},
+		{CommitHash: "d", CommitDate: now.Add(-time.Hour), Parents: []string{"c"}},
+	}
+	if err := commitRepo.SaveCommits(context.Background(), r.ID, commits); err != nil {
+		t.Fatalf("failed to save commits: %v", err)
+	}
+
+	first, second, rc := now.Add(-90*time.Minute), now.Add(-30*time.Minute), now.Add(-45*time.Minute)
+	releases := []models.Release{
+		{TagName: "v2.0", TargetSHA: "d", PublishedAt: &second},
+		{TagName: "v2.0-rc1", TargetSHA: "d", PublishedAt: &rc, Prerelease: true},
+		{TagName: "v1.0", TargetSHA: "b", PublishedAt: &first},
+		{TagName: "v3.0", TargetSHA: "unknown", PublishedAt: &second},
+	}
+	if err := releaseRepo.SaveReleases(context.Background(), r.ID, releases); err != nil {
+		t.Fatalf("failed to save releases: %v", err)
+	}
+
+	changed, err := releaseRepo.ResolveReleaseCommits(context.Background(), r.ID)
+	if err != nil {
+		t.Fatalf("failed to resolve release commits: %v", err)
+	}
+	if changed != 4 {
+		t.Errorf("expected 4 attributed commits, got %d", changed)
+	}
+
+	v2, err := releaseRepo.GetRelease(context.Background(), repository.RepositoryName("released"), "v2.0")
+	if err != nil {
+		t.Fatalf("failed to get release: %v", err)
+	}
+	shipped, _ := releaseRepo.GetReleaseCommits(context.Background(), v2.ID)
+	if len(shipped) != 2 || shipped[0].CommitHash != "d" || shipped[1].CommitHash != "c" {
+		t.Errorf("unexpected commits of v2.0: %+v", shipped)
+	}
+
+	// Nothing changes until commits or releases are stored
+	changed, _ = releaseRepo.ResolveReleaseCommits(context.Background(), r.ID)
+	if changed != 0 {
+		t.Errorf("expected no further commits to attribute, got %d", changed)
+	}
+}
+
+func TestResolveReleaseCommits_FollowsReachability(t *testing.T) {
+	db := setupTestDB(t)
+	commitRepo := repository.NewCommitRepo(db)
+	releaseRepo := repository.NewReleaseRepo(db)
+	ctx := context.Background()
+
+	r := models.Repository{Name: "released"}
+	db.Create(&r)
+
+	// main: a - b - c, maintenance branch: b - fix
+	now := time.Now().UTC()
+	commits := []models.Commit{
+		{CommitHash: "b", CommitDate: now.Add(-3 * time.Hour), Parents: []string{"a"}},
+		{CommitHash: "c", CommitDate: now.Add(-2 * time.Hour), Parents: []string{"b"}},
+		{CommitHash: "fix", CommitDate: now.Add(-time.Hour), Parents: []string{"b"}},
+	}
+	if err := commitRepo.SaveCommits(ctx, r.ID, commits); err != nil {
+		t.Fatalf("failed to save commits: %v", err)
+	}
+
+	// The tag of v1.0 is only known later
+	first, second, backport := now.Add(-150*time.Minute), now.Add(-90*time.Minute), now.Add(-30*time.Minute)
+	releases := []models.Release{
+		{TagName: "v1.0", PublishedAt: &first},
+		{TagName: "v2.0", TargetSHA: "c", PublishedAt: &second},
+		{TagName: "v1.1", TargetSHA: "fix", PublishedAt: &backport},
+	}
+	if err := releaseRepo.SaveReleases(ctx, r.ID, releases); err != nil {
+		t.Fatalf("failed to save releases: %v", err)
+	}
+
+	if _, err := releaseRepo.ResolveReleaseCommits(ctx, r.ID); err != nil {
+		t.Fatalf("failed to resolve release commits: %v", err)
+	}
+
+	// Older history is stored after the releases were resolved
+	if err := commitRepo.SaveCommits(ctx, r.ID, []models.Commit{{CommitHash: "a", CommitDate: now.Add(-4 * time.Hour)}}); err != nil {
+		t.Fatalf("failed to save commits: %v", err)
+	}
+	if err := releaseRepo.SaveReleases(ctx, r.ID, []models.Release{{TagName: "v1.0", TargetSHA: "b", PublishedAt: &first}}); err != nil {
+		t.Fatalf("failed to save releases: %v", err)
+	}
+	if _, err := releaseRepo.ResolveReleaseCommits(ctx, r.ID); err != nil {
+		t.Fatalf("failed to resolve release commits: %v", err)
+	}
+
+	for tag, want := range map[string][]string{"v1.0": {"b", "a"}, "v2.0": {"c"}, "v1.1": {"fix"}} {
+		release, err := releaseRepo.GetRelease(ctx, repository.RepositoryName("released"), tag)
+		if err != nil {
+			t.Fatalf("failed to get release %s: %v", tag, err)
+		}
+		shipped, _ := releaseRepo.GetReleaseCommits(ctx, release.ID)
+		if len(shipped) != len(want) {
+			t.Errorf("expected %s to ship %v, got %+v", tag, want, shipped)
+			continue
+		}
+		for i, commit := range shipped {
+			if commit.CommitHash != want[i] {
+				t.Errorf("expected %s to ship %v, got %+v", tag, want, shipped)
+			}
+		}
+	}
+}
+
+func TestResolveReleaseCommits_WalksOnlyNewCommits(t *testing.T) {
+	db := setupTestDB(t)
+	commitRepo := repository.NewCommitRepo(db)
+	releaseRepo := repository.NewReleaseRepo(db)
+	ctx := context.Background()
+
+	r := models.Repository{Name: "released"}
+	db.Create(&r)
+
+	now := time.Now().UTC()
+	commits := []models.Commit{
+		{CommitHash: "b", CommitDate: now.Add(-3 * time.Hour), Parents: []string{"a"}},
+		{CommitHash: "c", CommitDate: now.Add(-2 * time.Hour), Parents: []string{"b"}},
+		{CommitHash: "stray", CommitDate: now.Add(-150 * time.Minute)},
+	}
+	if err := commitRepo.SaveCommits(ctx, r.ID, commits); err != nil {
+		t.Fatalf("failed to save commits: %v", err)
+	}
+	published := now.Add(-90 * time.Minute)
+	if err := releaseRepo.SaveReleases(ctx, r.ID, []models.Release{{TagName: "v1.0", TargetSHA: "c", PublishedAt: &published}}); err != nil {
+		t.Fatalf("failed to save releases: %v", err)
+	}
+	if changed, err := releaseRepo.ResolveReleaseCommits(ctx, r.ID); err != nil || changed != 2 {
+		t.Fatalf("expected 2 attributed commits, got %d (%v)", changed, err)
+	}
+
+	// Only a walk of the whole history would take the release off a commit no release reaches
+	v1, _ := releaseRepo.GetRelease(ctx, repository.RepositoryName("released"), "v1.0")
+	db.Model(&models.Commit{}).Where("commit_hash = ?", "stray").UpdateColumn("first_release_id", v1.ID)
+
+	// Older history and newer commits are stored, an unchanged sync of the releases keeps the progress
+	later := []models.Commit{
+		{CommitHash: "a", CommitDate: now.Add(-4 * time.Hour)},
+		{CommitHash: "d", CommitDate: now.Add(-time.Hour), Parents: []string{"c"}},
+	}
+	if err := commitRepo.SaveCommits(ctx, r.ID, later); err != nil {
+		t.Fatalf("failed to save commits: %v", err)
+	}
+	if err := releaseRepo.SaveReleases(ctx, r.ID, []models.Release{{TagName: "v1.0", TargetSHA: "c", PublishedAt: &published}}); err != nil {
+		t.Fatalf("failed to save releases: %v", err)
+	}
+	if changed, err := releaseRepo.ResolveReleaseCommits(ctx, r.ID); err != nil || changed != 1 {
+		t.Fatalf("expected the older commit to be attributed, got %d (%v)", changed, err)
+	}
+
+	shipped, _ := releaseRepo.GetReleaseCommits(ctx, v1.ID)
+	var hashes []string
+	for _, commit := range shipped {
+		hashes = append(hashes, commit.CommitHash)
+	}
+	if strings.Join(hashes, ",") != "c,stray,b,a" {
+		t.Errorf("expected v1.0 to gain the older commit only, got %v", hashes)
+	}
+}
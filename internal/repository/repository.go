@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"gmonitor/internal/models"
 	"gorm.io/gorm"
+	"time"
 )
 
 // RepositoryRepo provides database operations for repositories
@@ -21,6 +22,14 @@ func NewRepositoryRepo(db *gorm.DB) *RepositoryRepo {
 	}
 }
 
+// InTransaction runs fn with repository and commit stores bound to a single transaction, so that the sync state they
+// record is committed together or not at all
+func (r *RepositoryRepo) InTransaction(ctx context.Context, fn func(repos *RepositoryRepo, commits *CommitRepo) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(NewRepositoryRepo(tx), NewCommitRepo(tx))
+	})
+}
+
 // SaveRepository stores a repository in the database
 func (r *RepositoryRepo) SaveRepository(ctx context.Context, repo *models.Repository) error {
 	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
@@ -31,18 +40,37 @@ func (r *RepositoryRepo) SaveRepository(ctx context.Context, repo *models.Reposi
 	})
 }
 
-// GetRepository retrieves a repository by name
-func (r *RepositoryRepo) GetRepository(ctx context.Context, name string) (*models.Repository, error) {
-	var repo models.Repository
+// GetRepository retrieves a repository by key. A key without provider and host fails with ErrAmbiguousRepository
+// when its name is tracked on several hosts.
+func (r *RepositoryRepo) GetRepository(ctx context.Context, key RepositoryKey) (*models.Repository, error) {
+	var repos []models.Repository
 
-	err := r.db.WithContext(ctx).
-		Where("name = ?", name).
-		First(&repo).Error
+	err := key.where(r.db.WithContext(ctx), "repositories").
+		Order("id").
+		Limit(2).
+		Find(&repos).Error
 
-	if errors.Is(err, gorm.ErrRecordNotFound) {
+	if err != nil {
+		return nil, fmt.Errorf("failed to get repository: %w", err)
+	}
+
+	switch len(repos) {
+	case 0:
 		return nil, sql.ErrNoRows
+	case 1:
+		return &repos[0], nil
 	}
+	return nil, fmt.Errorf("%w: %s", ErrAmbiguousRepository, key)
+}
+
+// GetRepositoryByID retrieves a repository by its ID, which unlike its name does not change with a rename
+func (r *RepositoryRepo) GetRepositoryByID(ctx context.Context, id uint) (*models.Repository, error) {
+	var repo models.Repository
 
+	err := r.db.WithContext(ctx).First(&repo, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, sql.ErrNoRows
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get repository: %w", err)
 	}
@@ -61,3 +89,179 @@ func (r *RepositoryRepo) GetAllRepositories(ctx context.Context) ([]*models.Repo
 
 	return repositories, nil
 }
+
+// MarkSynced records a successful poll and moves the commit cursor forward to the newest of the saved commits
+func (r *RepositoryRepo) MarkSynced(ctx context.Context, repoID uint, commits []models.Commit, syncedAt time.Time) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var repo models.Repository
+		if err := tx.First(&repo, repoID).Error; err != nil {
+			return fmt.Errorf("failed to load repository sync state: %w", err)
+		}
+
+		updates := map[string]interface{}{
+			"last_synced_at":  syncedAt,
+			"last_sync_error": "",
+		}
+
+		// Only ever move the cursor forward
+		for _, commit := range commits {
+			if repo.LastCommitDate != nil && !commit.CommitDate.After(*repo.LastCommitDate) {
+				continue
+			}
+			commitDate := commit.CommitDate
+			repo.LastCommitDate = &commitDate
+			updates["last_commit_sha"] = commit.CommitHash
+			updates["last_commit_date"] = commitDate
+		}
+
+		if err := tx.Model(&models.Repository{}).Where("id = ?", repoID).UpdateColumns(updates).Error; err != nil {
+			return fmt.Errorf("failed to update repository sync state: %w", err)
+		}
+		return nil
+	})
+}
+
+// MarkSyncFailed records the error of a failed poll, leaving the commit cursor untouched
+func (r *RepositoryRepo) MarkSyncFailed(ctx context.Context, repoID uint, syncErr error) error {
+	err := r.db.WithContext(ctx).
+		Model(&models.Repository{}).
+		Where("id = ?", repoID).
+		UpdateColumn("last_sync_error", syncErr.Error()).Error
+
+	if err != nil {
+		return fmt.Errorf("failed to record repository sync error: %w", err)
+	}
+	return nil
+}
+
+// SetInstallationID records the GitHub App installation that owns a repository
+func (r *RepositoryRepo) SetInstallationID(ctx context.Context, repoID uint, installationID int64) error {
+	err := r.db.WithContext(ctx).
+		Model(&models.Repository{}).
+		Where("id = ?", repoID).
+		UpdateColumn("installation_id", installationID).Error
+
+	if err != nil {
+		return fmt.Errorf("failed to set installation of repository: %w", err)
+	}
+	return nil
+}
+
+// MarkWebhookReceived records that a webhook delivery arrived for a repository
+func (r *RepositoryRepo) MarkWebhookReceived(ctx context.Context, repoID uint, receivedAt time.Time) error {
+	err := r.db.WithContext(ctx).
+		Model(&models.Repository{}).
+		Where("id = ?", repoID).
+		UpdateColumn("last_webhook_at", receivedAt).Error
+
+	if err != nil {
+		return fmt.Errorf("failed to record webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// Metrics recorded by repository snapshots
+const (
+	MetricStars      = "stars"
+	MetricForks      = "forks"
+	MetricWatchers   = "watchers"
+	MetricOpenIssues = "open_issues"
+)
+
+// ErrUnknownMetric is returned for a metric that is not recorded by repository snapshots
+var ErrUnknownMetric = errors.New("unknown metric")
+
+// metricColumns maps the metrics to the snapshot column holding them
+var metricColumns = map[string]string{
+	MetricStars:      "stars",
+	MetricForks:      "forks",
+	MetricWatchers:   "watchers",
+	MetricOpenIssues: "open_issues",
+}
+
+// MetricPoint is the value of a metric at a point in time
+type MetricPoint struct {
+	Time  time.Time
+	Value int
+}
+
+// RecordMetadata refreshes the metadata of a repository and appends a snapshot of its counters
+func (r *RepositoryRepo) RecordMetadata(ctx context.Context, repoID uint, repo *models.Repository, recordedAt time.Time) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := NewRepositoryRepo(tx).UpdateMetadata(ctx, repoID, repo); err != nil {
+			return err
+		}
+
+		snapshot := &models.RepositorySnapshot{
+			RepoID:     repoID,
+			RecordedAt: recordedAt,
+			Stars:      repo.StarsCount,
+			Forks:      repo.ForksCount,
+			Watchers:   repo.WatchersCount,
+			OpenIssues: repo.OpenIssuesCount,
+		}
+		if err := tx.Create(snapshot).Error; err != nil {
+			return fmt.Errorf("failed to save repository snapshot: %w", err)
+		}
+
+		err := tx.Model(&models.Repository{}).
+			Where("id = ?", repoID).
+			UpdateColumn("metadata_synced_at", recordedAt).Error
+
+		if err != nil {
+			return fmt.Errorf("failed to record metadata sync: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetMetricHistory retrieves the recorded values of a metric of a repository between from and to, oldest first.
+// Zero bounds leave the range open.
+func (r *RepositoryRepo) GetMetricHistory(ctx context.Context, repo RepositoryKey, metric string, from, to time.Time) ([]MetricPoint, error) {
+	column, found := metricColumns[metric]
+	if !found {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownMetric, metric)
+	}
+
+	query := r.db.WithContext(ctx).
+		Model(&models.RepositorySnapshot{}).
+		Select("repository_snapshots.recorded_at AS time, repository_snapshots." + column + " AS value").
+		Joins("JOIN repositories ON repository_snapshots.repo_id = repositories.id").
+		Scopes(inRepository(repo))
+
+	if !from.IsZero() {
+		query = query.Where("repository_snapshots.recorded_at >= ?", from)
+	}
+	if !to.IsZero() {
+		query = query.Where("repository_snapshots.recorded_at < ?", to)
+	}
+
+	points := make([]MetricPoint, 0)
+	if err := query.Order("repository_snapshots.recorded_at").Scan(&points).Error; err != nil {
+		return nil, fmt.Errorf("failed to get %s history for repository %q: %w", metric, repo, err)
+	}
+	return points, nil
+}
+
+// UpdateMetadata overwrites the descriptive fields and counters of a repository
+func (r *RepositoryRepo) UpdateMetadata(ctx context.Context, repoID uint, repo *models.Repository) error {
+	err := r.db.WithContext(ctx).
+		Model(&models.Repository{}).
+		Where("id = ?", repoID).
+		UpdateColumns(map[string]interface{}{
+			"name":              repo.Name,
+			"description":       repo.Description,
+			"url":               repo.URL,
+			"language":          repo.Language,
+			"forks_count":       repo.ForksCount,
+			"stars_count":       repo.StarsCount,
+			"open_issues_count": repo.OpenIssuesCount,
+			"watchers_count":    repo.WatchersCount,
+			"updated_at":        repo.UpdatedAt,
+		}).Error
+
+	if err != nil {
+		return fmt.Errorf("failed to update repository metadata: %w", err)
+	}
+	return nil
+}
@@ -9,7 +9,7 @@ import (
 	"gmonitor/internal/repository"
 	"gorm.io/gorm"
 	"testing"
-	_ "time"
+	"time"
 )
 
 func setupRepoTestDB(t *testing.T) *gorm.DB {
@@ -18,7 +18,7 @@ func setupRepoTestDB(t *testing.T) *gorm.DB {
 		t.Fatalf("failed to connect to test DB: %v", err)
 	}
 
-	if err := db.AutoMigrate(&models.Repository{}); err != nil {
+	if err := db.AutoMigrate(&models.Repository{}, &models.Branch{}, &models.RepositorySnapshot{}); err != nil {
 		t.Fatalf("failed to migrate schema: %v", err)
 	}
 
@@ -68,7 +68,7 @@ func TestGetRepository_Found(t *testing.T) {
 	}
 	db.Create(expected)
 
-	repo, err := repoStore.GetRepository(context.Background(), "test-repo")
+	repo, err := repoStore.GetRepository(context.Background(), repository.RepositoryName("test-repo"))
 	if err != nil {
 		t.Fatalf("failed to get repository: %v", err)
 	}
@@ -77,16 +77,67 @@ func TestGetRepository_Found(t *testing.T) {
 	}
 }
 
+func TestGetRepository_ByHost(t *testing.T) {
+	db := setupRepoTestDB(t)
+	repoStore := repository.NewRepositoryRepo(db)
+	ctx := context.Background()
+
+	db.Create(&models.Repository{Name: "acme/api", Provider: "github"})
+	db.Create(&models.Repository{Name: "acme/api", Provider: "github", Host: "ghe.example.com"})
+	if err := db.Create(&models.Repository{Name: "acme/api", Provider: "github"}).Error; err == nil {
+		t.Error("expected a repository to be stored once per host")
+	}
+
+	repo, err := repoStore.GetRepository(ctx, repository.RepositoryKey{Provider: "github", Host: "ghe.example.com", Name: "acme/api"})
+	if err != nil || repo.Host != "ghe.example.com" {
+		t.Errorf("expected the enterprise repository, got %+v (%v)", repo, err)
+	}
+	// A provider without host is its default host
+	repo, err = repoStore.GetRepository(ctx, repository.RepositoryKey{Provider: "github", Name: "acme/api"})
+	if err != nil || repo.Host != "" {
+		t.Errorf("expected the github.com repository, got %+v (%v)", repo, err)
+	}
+
+	// A bare name is ambiguous, for lookups as well as for listings
+	if _, err := repoStore.GetRepository(ctx, repository.RepositoryName("acme/api")); !errors.Is(err, repository.ErrAmbiguousRepository) {
+		t.Errorf("expected ErrAmbiguousRepository, got %v", err)
+	}
+	_, err = repoStore.GetMetricHistory(ctx, repository.RepositoryName("acme/api"), repository.MetricStars, time.Time{}, time.Time{})
+	if !errors.Is(err, repository.ErrAmbiguousRepository) {
+		t.Errorf("expected ErrAmbiguousRepository from a listing, got %v", err)
+	}
+}
+
 func TestGetRepository_NotFound(t *testing.T) {
 	db := setupRepoTestDB(t)
 	repoStore := repository.NewRepositoryRepo(db)
 
-	_, err := repoStore.GetRepository(context.Background(), "missing")
+	_, err := repoStore.GetRepository(context.Background(), repository.RepositoryName("missing"))
 	if !errors.Is(err, sql.ErrNoRows) {
 		t.Errorf("expected sql.ErrNoRows, got: %v", err)
 	}
 }
 
+func TestGetRepositoryByID_FollowsRename(t *testing.T) {
+	db := setupRepoTestDB(t)
+	repoStore := repository.NewRepositoryRepo(db)
+	ctx := context.Background()
+
+	repo := &models.Repository{Name: "owner/old"}
+	db.Create(repo)
+	if err := repoStore.RecordMetadata(ctx, repo.ID, &models.Repository{Name: "owner/new"}, time.Now()); err != nil {
+		t.Fatalf("failed to record metadata: %v", err)
+	}
+
+	renamed, err := repoStore.GetRepositoryByID(ctx, repo.ID)
+	if err != nil || renamed.Name != "owner/new" {
+		t.Errorf("expected the renamed repository, got %+v (%v)", renamed, err)
+	}
+	if _, err := repoStore.GetRepositoryByID(ctx, repo.ID+1); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows, got: %v", err)
+	}
+}
+
 func TestGetAllRepositories_WithData(t *testing.T) {
 	db := setupRepoTestDB(t)
 	repoStore := repository.NewRepositoryRepo(db)
@@ -120,3 +171,132 @@ func TestGetAllRepositories_Empty(t *testing.T) {
 		t.Errorf("expected 0 repositories, got %d", len(all))
 	}
 }
+
+func TestMarkSynced_MovesCursorForward(t *testing.T) {
+	db := setupRepoTestDB(t)
+	repoStore := repository.NewRepositoryRepo(db)
+
+	repo := &models.Repository{Name: "cursor-repo"}
+	db.Create(repo)
+
+	now := time.Now().UTC()
+	commits := []models.Commit{
+		{CommitHash: "newer", CommitDate: now},
+		{CommitHash: "older", CommitDate: now.Add(-time.Hour)},
+	}
+	if err := repoStore.MarkSynced(context.Background(), repo.ID, commits, now); err != nil {
+		t.Fatalf("failed to mark repository synced: %v", err)
+	}
+
+	// An older batch must not move the cursor back
+	stale := []models.Commit{{CommitHash: "stale", CommitDate: now.Add(-2 * time.Hour)}}
+	if err := repoStore.MarkSynced(context.Background(), repo.ID, stale, now); err != nil {
+		t.Fatalf("failed to mark repository synced: %v", err)
+	}
+
+	synced, err := repoStore.GetRepository(context.Background(), repository.RepositoryName("cursor-repo"))
+	if err != nil {
+		t.Fatalf("failed to get repository: %v", err)
+	}
+	if synced.LastCommitSHA != "newer" {
+		t.Errorf("expected cursor at 'newer', got %q", synced.LastCommitSHA)
+	}
+	if synced.LastCommitDate == nil || !synced.LastCommitDate.Equal(now) {
+		t.Errorf("expected cursor date %v, got %v", now, synced.LastCommitDate)
+	}
+	if synced.LastSyncedAt == nil {
+		t.Error("expected last synced time to be set")
+	}
+}
+
+func TestMarkSyncFailed_KeepsCursor(t *testing.T) {
+	db := setupRepoTestDB(t)
+	repoStore := repository.NewRepositoryRepo(db)
+
+	repo := &models.Repository{Name: "failing-repo"}
+	db.Create(repo)
+
+	now := time.Now().UTC()
+	_ = repoStore.MarkSynced(context.Background(), repo.ID, []models.Commit{{CommitHash: "abc", CommitDate: now}}, now)
+
+	if err := repoStore.MarkSyncFailed(context.Background(), repo.ID, errors.New("boom")); err != nil {
+		t.Fatalf("failed to record sync error: %v", err)
+	}
+
+	failed, err := repoStore.GetRepository(context.Background(), repository.RepositoryName("failing-repo"))
+	if err != nil {
+		t.Fatalf("failed to get repository: %v", err)
+	}
+	if failed.LastSyncError != "boom" {
+		t.Errorf("expected sync error 'boom', got %q", failed.LastSyncError)
+	}
+	if failed.LastCommitSHA != "abc" {
+		t.Errorf("expected cursor to stay at 'abc', got %q", failed.LastCommitSHA)
+	}
+
+	// The next successful poll clears the error
+	_ = repoStore.MarkSynced(context.Background(), repo.ID, nil, now)
+	cleared, _ := repoStore.GetRepository(context.Background(), repository.RepositoryName("failing-repo"))
+	if cleared.LastSyncError != "" {
+		t.Errorf("expected sync error to be cleared, got %q", cleared.LastSyncError)
+	}
+}
+
+func TestUpdateMetadata(t *testing.T) {
+	db := setupRepoTestDB(t)
+	repoStore := repository.NewRepositoryRepo(db)
+
+	repo := &models.Repository{Name: "old/name", URL: "https://github.com/old/name", StarsCount: 1}
+	db.Create(repo)
+
+	err := repoStore.UpdateMetadata(context.Background(), repo.ID, &models.Repository{
+		Name:       "new/name",
+		URL:        "https://github.com/new/name",
+		StarsCount: 42,
+		UpdatedAt:  time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("failed to update metadata: %v", err)
+	}
+
+	updated, err := repoStore.GetRepository(context.Background(), repository.RepositoryName("new/name"))
+	if err != nil {
+		t.Fatalf("expected repository under its new name: %v", err)
+	}
+	if updated.StarsCount != 42 {
+		t.Errorf("expected 42 stars, got %d", updated.StarsCount)
+	}
+}
+
+func TestRecordMetadata_AppendsSnapshots(t *testing.T) {
+	db := setupRepoTestDB(t)
+	repoStore := repository.NewRepositoryRepo(db)
+
+	repo := &models.Repository{Name: "owner/starred", StarsCount: 1}
+	db.Create(repo)
+
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	for day, stars := range []int{10, 15, 30} {
+		fetched := &models.Repository{Name: "owner/starred", StarsCount: stars, ForksCount: day}
+		if err := repoStore.RecordMetadata(context.Background(), repo.ID, fetched, start.AddDate(0, 0, day)); err != nil {
+			t.Fatalf("failed to record metadata: %v", err)
+		}
+	}
+
+	updated, _ := repoStore.GetRepository(context.Background(), repository.RepositoryName("owner/starred"))
+	if updated.StarsCount != 30 || updated.MetadataSyncedAt == nil {
+		t.Errorf("expected the latest counters to be stored, got %+v", updated)
+	}
+
+	history, err := repoStore.GetMetricHistory(context.Background(), repository.RepositoryName("owner/starred"), repository.MetricStars, start.AddDate(0, 0, 1), time.Time{})
+	if err != nil {
+		t.Fatalf("failed to get history: %v", err)
+	}
+	if len(history) != 2 || history[0].Value != 15 || history[1].Value != 30 || !history[1].Time.Equal(start.AddDate(0, 0, 2)) {
+		t.Errorf("unexpected history: %+v", history)
+	}
+
+	if _, err := repoStore.GetMetricHistory(context.Background(), repository.RepositoryName("owner/starred"), "downloads", time.Time{}, time.Time{}); !errors.Is(err, repository.ErrUnknownMetric) {
+		t.Errorf("expected ErrUnknownMetric, got %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"gmonitor/internal/models"
+	"gorm.io/gorm"
+)
+
+// RecordHistoryRewrite stores a history rewrite and takes the lost commits off the branch.
+// Commits that are no longer seen on any branch are marked orphaned, they are never deleted.
+func (r *CommitRepo) RecordHistoryRewrite(ctx context.Context, rewrite *models.HistoryRewrite) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(rewrite).Error; err != nil {
+			return fmt.Errorf("failed to save history rewrite: %w", err)
+		}
+
+		for start := 0; start < len(rewrite.LostSHAs); start += hashLookupBatch {
+			end := min(start+hashLookupBatch, len(rewrite.LostSHAs))
+
+			var ids []uint
+			err := tx.Model(&models.Commit{}).
+				Where("repo_id = ? AND commit_hash IN ?", rewrite.RepoID, rewrite.LostSHAs[start:end]).
+				Pluck("id", &ids).Error
+
+			if err != nil {
+				return fmt.Errorf("failed to look up lost commits: %w", err)
+			}
+			if len(ids) == 0 {
+				continue
+			}
+
+			err = tx.Unscoped().
+				Where("commit_id IN ? AND branch = ?", ids, rewrite.Branch).
+				Delete(&models.CommitBranch{}).Error
+
+			if err != nil {
+				return fmt.Errorf("failed to remove lost commits from branch: %w", err)
+			}
+
+			err = tx.Model(&models.Commit{}).
+				Where("id IN ?", ids).
+				Where("NOT EXISTS (SELECT 1 FROM commit_branches WHERE commit_branches.commit_id = commits.id)").
+				UpdateColumns(map[string]interface{}{"orphaned": true, "orphaned_at": rewrite.DetectedAt}).Error
+
+			if err != nil {
+				return fmt.Errorf("failed to mark lost commits orphaned: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// GetHistoryRewrites retrieves the most recent history rewrites of a repository by name
+func (r *CommitRepo) GetHistoryRewrites(ctx context.Context, repo RepositoryKey, limit int) ([]models.HistoryRewrite, error) {
+	var rewrites []models.HistoryRewrite
+
+	err := r.db.WithContext(ctx).
+		Model(&models.HistoryRewrite{}).
+		Joins("JOIN repositories ON history_rewrites.repo_id = repositories.id").
+		Scopes(inRepository(repo)).
+		Order("history_rewrites.detected_at DESC").
+		Limit(limit).
+		Find(&rewrites).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get history rewrites for repository %q: %w", repo, err)
+	}
+	return rewrites, nil
+}
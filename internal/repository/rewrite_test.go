@@ -0,0 +1,67 @@
+package repository_test
+
+import (
+	"context"
+	"gmonitor/internal/models"
+	"gmonitor/internal/repository"
+	"testing"
+	"time"
+)
+
+func TestRecordHistoryRewrite_OrphansLostCommits(t *testing.T) {
+	db := setupTestDB(t)
+	commitRepo := repository.NewCommitRepo(db)
+
+	r := models.Repository{Name: "rewritten"}
+	db.Create(&r)
+
+	commits := []models.Commit{
+		{CommitHash: "kept", CommitDate: time.Now()},
+		{CommitHash: "lost", CommitDate: time.Now()},
+		{CommitHash: "shared", CommitDate: time.Now()},
+	}
+	if err := commitRepo.SaveCommits(context.Background(), r.ID, commits); err != nil {
+		t.Fatalf("failed to save commits: %v", err)
+	}
+	_ = commitRepo.RecordBranch(context.Background(), r.ID, "main", commits)
+	_ = commitRepo.RecordBranch(context.Background(), r.ID, "develop", commits[2:])
+
+	rewrite := &models.HistoryRewrite{
+		RepoID:     r.ID,
+		Branch:     "main",
+		OldHeadSHA: "shared",
+		NewHeadSHA: "kept",
+		DetectedAt: time.Now(),
+		LostSHAs:   []string{"lost", "shared"},
+	}
+	if err := commitRepo.RecordHistoryRewrite(context.Background(), rewrite); err != nil {
+		t.Fatalf("failed to record history rewrite: %v", err)
+	}
+
+	var orphaned []string
+	db.Model(&models.Commit{}).Where("orphaned = ?", true).Pluck("commit_hash", &orphaned)
+	if len(orphaned) != 1 || orphaned[0] != "lost" {
+		t.Errorf("expected only 'lost' to be orphaned, got %v", orphaned)
+	}
+
+	onMain, _ := commitRepo.GetCommitsByRepository(context.Background(), repository.RepositoryName("rewritten"), "main", 20, 0)
+	if len(onMain) != 1 || onMain[0].CommitHash != "kept" {
+		t.Errorf("unexpected commits left on main: %+v", onMain)
+	}
+
+	rewrites, err := commitRepo.GetHistoryRewrites(context.Background(), repository.RepositoryName("rewritten"), 10)
+	if err != nil {
+		t.Fatalf("failed to get history rewrites: %v", err)
+	}
+	if len(rewrites) != 1 || len(rewrites[0].LostSHAs) != 2 {
+		t.Errorf("unexpected history rewrites: %+v", rewrites)
+	}
+
+	// Pushing the commit back restores it
+	_ = commitRepo.RecordBranch(context.Background(), r.ID, "main", commits[1:2])
+	var restored models.Commit
+	db.Where("commit_hash = ?", "lost").First(&restored)
+	if restored.Orphaned || restored.OrphanedAt != nil {
+		t.Errorf("expected the commit to be restored, got %+v", restored)
+	}
+}
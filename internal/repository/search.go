@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"gmonitor/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strings"
+	"time"
+)
+
+// Orders commit search results can be sorted by
+const (
+	SortByDate      = "date"
+	SortByRelevance = "relevance"
+)
+
+// CommitSearch holds the criteria of a commit search. Zero fields leave the commits unfiltered,
+// so an empty Repos searches every repository.
+type CommitSearch struct {
+	Repos  []RepositoryKey
+	Author string
+	Branch string
+	Since  time.Time
+	Until  time.Time
+	// Text is matched against the full-text index of commit messages
+	Text string
+	// SHAPrefix matches the commits whose hash starts with it
+	SHAPrefix string
+	// Sort is SortByDate or SortByRelevance, relevance only applies along with Text
+	Sort string
+}
+
+// CommitSearchResult is a commit found by a search along with the name of its repository
+type CommitSearchResult struct {
+	models.Commit
+	Repository string
+}
+
+// SearchCommits retrieves paginated commits matching a search, most recent first unless sorted by relevance
+func (r *CommitRepo) SearchCommits(ctx context.Context, search CommitSearch, limit, offset int) ([]CommitSearchResult, error) {
+	results := make([]CommitSearchResult, 0)
+
+	query := r.db.WithContext(ctx).
+		Model(&models.Commit{}).
+		Select("commits.*, repositories.name AS repository").
+		Joins("JOIN repositories ON commits.repo_id = repositories.id").
+		Where("commits.deleted_at IS NULL")
+
+	if len(search.Repos) > 0 {
+		// Unlike listings, a search covers every repository of the name when no host is given
+		repos := search.Repos[0].where(r.db, "repositories")
+		for _, repo := range search.Repos[1:] {
+			repos = repos.Or(repo.where(r.db, "repositories"))
+		}
+		query = query.Where(repos)
+	}
+	if search.Author != "" {
+		// The author matches by any alias of its contributor as well as by the raw commit author
+		author := strings.ToLower(search.Author)
+		query = query.Where(
+			"LOWER(commits.author) = ? OR commits.contributor_id IN (SELECT contributor_id FROM contributor_aliases WHERE value = ? AND deleted_at IS NULL) "+
+				"OR commits.contributor_id IN (SELECT id FROM contributors WHERE LOWER(name) = ? AND deleted_at IS NULL)",
+			author, author, author,
+		)
+	}
+	if search.Branch != "" {
+		query = query.
+			Joins("JOIN commit_branches ON commit_branches.commit_id = commits.id").
+			Where("commit_branches.branch = ? AND commit_branches.deleted_at IS NULL", search.Branch)
+	}
+	if !search.Since.IsZero() {
+		query = query.Where("commits.commit_date >= ?", search.Since)
+	}
+	if !search.Until.IsZero() {
+		query = query.Where("commits.commit_date <= ?", search.Until)
+	}
+	if search.SHAPrefix != "" {
+		query = query.Where(`commits.commit_hash LIKE ? ESCAPE '!'`, likeEscaper.Replace(strings.ToLower(search.SHAPrefix))+"%")
+	}
+
+	text := strings.Fields(search.Text)
+	if len(text) > 0 && search.Sort == SortByRelevance {
+		var rank clause.Expr
+		query, rank = r.matchMessage(query, text)
+		// An ordering expression replaces any column ordering, so the date tie-break is part of it
+		rank.SQL += ", commits.commit_date DESC"
+		query = query.Order(clause.OrderBy{Expression: rank})
+	} else {
+		if len(text) > 0 {
+			query, _ = r.matchMessage(query, text)
+		}
+		query = query.Order("commits.commit_date DESC")
+	}
+
+	err := query.
+		Limit(limit).
+		Offset(offset).
+		Scan(&results).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to search commits: %w", err)
+	}
+	return results, nil
+}
+
+// matchMessage limits a commit query to the messages containing every word, using the full-text index of the
+// database. It returns the expression ordering the matches by relevance, best first.
+func (r *CommitRepo) matchMessage(query *gorm.DB, words []string) (*gorm.DB, clause.Expr) {
+	switch r.db.Dialector.Name() {
+	case "postgres":
+		text := strings.Join(words, " ")
+		query = query.Where("to_tsvector('simple', commits.message) @@ plainto_tsquery('simple', ?)", text)
+		return query, clause.Expr{
+			SQL:  "ts_rank(to_tsvector('simple', commits.message), plainto_tsquery('simple', ?)) DESC",
+			Vars: []interface{}{text},
+		}
+	case "mysql":
+		// Every word is required, quoting keeps punctuation from being read as boolean operators
+		required := make([]string, len(words))
+		for i, word := range words {
+			required[i] = `+"` + strings.ReplaceAll(word, `"`, "") + `"`
+		}
+		text := strings.Join(required, " ")
+		query = query.Where("MATCH (commits.message) AGAINST (? IN BOOLEAN MODE)", text)
+		return query, clause.Expr{SQL: "MATCH (commits.message) AGAINST (? IN BOOLEAN MODE) DESC", Vars: []interface{}{text}}
+	}
+
+	// SQLite words are quoted so that punctuation such as the dash of "TICKET-1234" is not read as query syntax
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		quoted[i] = `"` + strings.ReplaceAll(word, `"`, `""`) + `"`
+	}
+	query = query.
+		Joins("JOIN commits_fts ON commits_fts.rowid = commits.id").
+		Where("commits_fts MATCH ?", strings.Join(quoted, " "))
+	return query, clause.Expr{SQL: "bm25(commits_fts)"}
+}
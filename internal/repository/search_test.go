@@ -0,0 +1,83 @@
+package repository_test
+
+import (
+	"context"
+	"github.com/glebarez/sqlite"
+	database "gmonitor/internal/db"
+	"gmonitor/internal/models"
+	"gmonitor/internal/repository"
+	"gorm.io/gorm"
+	"testing"
+	"time"
+)
+
+// setupSearchTestDB migrates the full schema, the commit search index included
+func setupSearchTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to connect test db: %v", err)
+	}
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("failed to migrate schema: %v", err)
+	}
+	return db
+}
+
+func TestSearchCommits(t *testing.T) {
+	db := setupSearchTestDB(t)
+	commitRepo := repository.NewCommitRepo(db)
+
+	api := models.Repository{Name: "acme/api"}
+	db.Create(&api)
+	web := models.Repository{Name: "acme/web"}
+	db.Create(&web)
+
+	now := time.Now().UTC()
+	_ = commitRepo.SaveCommits(context.Background(), api.ID, []models.Commit{
+		{CommitHash: "abc1230000", Author: "Jane", AuthorEmail: "jane@acme.com", Message: "Fix login ticket, refs TICKET-1234 and TICKET-1235", CommitDate: now.Add(-2 * time.Hour)},
+		{CommitHash: "def4560000", Author: "Bob", Message: "Bump dependencies", CommitDate: now.Add(-time.Hour)},
+	})
+	_ = commitRepo.SaveCommits(context.Background(), web.ID, []models.Commit{
+		{CommitHash: "abc9990000", Author: "jane doe", AuthorEmail: "JANE@acme.com", Message: "Follow-up of TICKET-1234 with a longer message to rank lower", CommitDate: now},
+	})
+
+	cases := []struct {
+		name   string
+		search repository.CommitSearch
+		want   []string
+	}{
+		{"all repositories", repository.CommitSearch{}, []string{"abc9990000", "def4560000", "abc1230000"}},
+		{"repository", repository.CommitSearch{Repos: []repository.RepositoryKey{repository.RepositoryName("acme/api")}}, []string{"def4560000", "abc1230000"}},
+		{"message", repository.CommitSearch{Text: "ticket-1234"}, []string{"abc9990000", "abc1230000"}},
+		{"message in repository", repository.CommitSearch{Text: "TICKET-1234", Repos: []repository.RepositoryKey{repository.RepositoryName("acme/api")}}, []string{"abc1230000"}},
+		{"author alias", repository.CommitSearch{Author: "jane@acme.com"}, []string{"abc9990000", "abc1230000"}},
+		{"sha prefix", repository.CommitSearch{SHAPrefix: "ABC1"}, []string{"abc1230000"}},
+		{"since", repository.CommitSearch{Since: now.Add(-90 * time.Minute)}, []string{"abc9990000", "def4560000"}},
+		{"relevance", repository.CommitSearch{Text: "ticket", Sort: repository.SortByRelevance}, []string{"abc1230000", "abc9990000"}},
+	}
+	for _, tc := range cases {
+		results, err := commitRepo.SearchCommits(context.Background(), tc.search, 10, 0)
+		if err != nil {
+			t.Fatalf("%s: failed to search commits: %v", tc.name, err)
+		}
+		got := make([]string, 0, len(results))
+		for _, result := range results {
+			got = append(got, result.CommitHash)
+		}
+		if len(got) != len(tc.want) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("%s: expected %v, got %v", tc.name, tc.want, got)
+				break
+			}
+		}
+	}
+
+	results, _ := commitRepo.SearchCommits(context.Background(), repository.CommitSearch{SHAPrefix: "def"}, 10, 0)
+	if len(results) != 1 || results[0].Repository != "acme/api" {
+		t.Errorf("expected the repository name along with the commit, got %+v", results)
+	}
+}
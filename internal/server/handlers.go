@@ -2,14 +2,18 @@ package server
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"gmonitor/internal/fetcher"
+	"gmonitor/internal/models"
 	"gmonitor/internal/repository"
 	"gmonitor/pkg/cache"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -17,29 +21,105 @@ type JSONResponse struct {
 	Success bool        `json:"success"`
 	Message string      `json:"message,omitempty"`
 	Data    interface{} `json:"data,omitempty"`
+	// NextCursor and PrevCursor are set on cursor paginated listings that have a page in that direction
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
 }
 
 func RegisterHandlers(
 	mux *http.ServeMux,
 	repoRepo *repository.RepositoryRepo,
 	commitRepo *repository.CommitRepo,
-	fetcher fetcher.GitHubFetcher,
+	releaseRepo *repository.ReleaseRepo,
+	pullRequestRepo *repository.PullRequestRepo,
+	issueRepo *repository.IssueRepo,
+	contributorRepo *repository.ContributorRepo,
+	providers *fetcher.Registry,
 	ctx context.Context,
-	token string,
 	cache *cache.Cache,
+	webhookSecret string,
 ) {
 	mux.HandleFunc("POST /api/v1/repos", func(w http.ResponseWriter, r *http.Request) {
-		handleAddRepo(w, r, repoRepo, commitRepo, fetcher, ctx, token, cache)
+		handleAddRepo(w, r, repoRepo, commitRepo, providers, ctx, cache)
 	})
 	mux.HandleFunc("GET /api/v1/repos", func(w http.ResponseWriter, r *http.Request) {
 		handleGetRepo(w, r, repoRepo, ctx, cache)
 	})
+	mux.HandleFunc("GET /api/v1/repos/{owner}/{repo}/history", func(w http.ResponseWriter, r *http.Request) {
+		handleGetMetricHistory(w, r, repoRepo, ctx, cache)
+	})
 	mux.HandleFunc("GET /api/v1/repos/commit-authors", func(w http.ResponseWriter, r *http.Request) {
 		handleGetCommitAuthors(w, r, commitRepo, ctx, cache)
 	})
 	mux.HandleFunc("GET /api/v1/repos/commits", func(w http.ResponseWriter, r *http.Request) {
 		handleGetRepoCommit(w, r, commitRepo, ctx, cache)
 	})
+	mux.HandleFunc("GET /api/v1/commits/search", func(w http.ResponseWriter, r *http.Request) {
+		handleSearchCommits(w, r, commitRepo, ctx, cache)
+	})
+	mux.HandleFunc("GET /api/v1/repos/branches", func(w http.ResponseWriter, r *http.Request) {
+		handleGetBranches(w, r, repoRepo, ctx)
+	})
+	mux.HandleFunc("PUT /api/v1/repos/branches", func(w http.ResponseWriter, r *http.Request) {
+		handleSetTrackedBranches(w, r, repoRepo, ctx, cache)
+	})
+	mux.HandleFunc("GET /api/v1/repos/history-rewrites", func(w http.ResponseWriter, r *http.Request) {
+		handleGetHistoryRewrites(w, r, commitRepo, ctx)
+	})
+	mux.HandleFunc("GET /api/v1/repos/releases", func(w http.ResponseWriter, r *http.Request) {
+		handleGetReleases(w, r, releaseRepo, ctx, cache)
+	})
+	mux.HandleFunc("GET /api/v1/repos/releases/commits", func(w http.ResponseWriter, r *http.Request) {
+		handleGetReleaseCommits(w, r, releaseRepo, ctx, cache)
+	})
+	mux.HandleFunc("GET /api/v1/repos/tags", func(w http.ResponseWriter, r *http.Request) {
+		handleGetTags(w, r, releaseRepo, ctx, cache)
+	})
+	mux.HandleFunc("GET /api/v1/repos/pulls", func(w http.ResponseWriter, r *http.Request) {
+		handleGetPullRequests(w, r, pullRequestRepo, ctx, cache)
+	})
+	mux.HandleFunc("GET /api/v1/repos/pulls/stats", func(w http.ResponseWriter, r *http.Request) {
+		handleGetPullRequestStats(w, r, pullRequestRepo, ctx, cache)
+	})
+	mux.HandleFunc("GET /api/v1/repos/issues", func(w http.ResponseWriter, r *http.Request) {
+		handleGetIssues(w, r, issueRepo, ctx, cache)
+	})
+	mux.HandleFunc("GET /api/v1/repos/issues/trend", func(w http.ResponseWriter, r *http.Request) {
+		handleGetIssueTrend(w, r, issueRepo, ctx, cache)
+	})
+	mux.HandleFunc("GET /api/v1/repos/issues/stats", func(w http.ResponseWriter, r *http.Request) {
+		handleGetIssueStats(w, r, issueRepo, ctx, cache)
+	})
+	mux.HandleFunc("GET /api/v1/contributors", func(w http.ResponseWriter, r *http.Request) {
+		handleGetContributors(w, r, contributorRepo, ctx)
+	})
+	mux.HandleFunc("GET /api/v1/contributors/{id}", func(w http.ResponseWriter, r *http.Request) {
+		handleGetContributor(w, r, contributorRepo, ctx)
+	})
+	mux.HandleFunc("POST /api/v1/contributors/merge", func(w http.ResponseWriter, r *http.Request) {
+		handleMergeContributors(w, r, contributorRepo, ctx)
+	})
+	mux.HandleFunc("POST /api/v1/contributors/mailmap", func(w http.ResponseWriter, r *http.Request) {
+		handleApplyMailmap(w, r, contributorRepo, ctx)
+	})
+	mux.HandleFunc("GET /api/v1/repos/contributors", func(w http.ResponseWriter, r *http.Request) {
+		handleGetRepoContributors(w, r, contributorRepo, ctx, cache)
+	})
+	mux.HandleFunc("GET /api/v1/repos/churn", func(w http.ResponseWriter, r *http.Request) {
+		handleGetRepositoryChurn(w, r, commitRepo, ctx, cache)
+	})
+	mux.HandleFunc("GET /api/v1/repos/churn/authors", func(w http.ResponseWriter, r *http.Request) {
+		handleGetAuthorChurn(w, r, commitRepo, ctx, cache)
+	})
+	mux.HandleFunc("GET /api/v1/repos/churn/directories", func(w http.ResponseWriter, r *http.Request) {
+		handleGetDirectoryChurn(w, r, commitRepo, ctx, cache)
+	})
+	mux.HandleFunc("GET /api/v1/rate-limit", func(w http.ResponseWriter, r *http.Request) {
+		handleGetRateLimit(w, r, providers)
+	})
+	mux.HandleFunc("POST /api/v1/webhooks/github", func(w http.ResponseWriter, r *http.Request) {
+		handleGitHubWebhook(w, r, repoRepo, commitRepo, releaseRepo, providers, ctx, webhookSecret)
+	})
 }
 
 func jsonResponse(w http.ResponseWriter, status int, success bool, msg string, data interface{}) {
@@ -48,6 +128,13 @@ func jsonResponse(w http.ResponseWriter, status int, success bool, msg string, d
 	_ = json.NewEncoder(w).Encode(JSONResponse{Success: success, Message: msg, Data: data})
 }
 
+// jsonPageResponse writes a successful response for a page of a cursor paginated listing
+func jsonPageResponse(w http.ResponseWriter, msg string, data interface{}, nextCursor, prevCursor string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(JSONResponse{Success: true, Message: msg, Data: data, NextCursor: nextCursor, PrevCursor: prevCursor})
+}
+
 func getFromCache(cache *cache.Cache, key string) (interface{}, bool) {
 	val, found, err := cache.Get(key)
 	if err != nil {
@@ -62,89 +149,244 @@ func setToCache(cache *cache.Cache, key string, val interface{}) {
 	}
 }
 
+// repositoryKey identifies the repository a request is about by name. The optional provider and host query
+// parameters tell apart repositories of the same name on different hosts.
+func repositoryKey(r *http.Request, name string) repository.RepositoryKey {
+	provider := strings.ToLower(r.URL.Query().Get("provider"))
+	return repository.RepositoryKey{
+		Provider: provider,
+		Host:     fetcher.CanonicalHost(provider, r.URL.Query().Get("host")),
+		Name:     name,
+	}
+}
+
+// repositoryError writes the response of a failed repository query, a name tracked on several hosts is a conflict
+func repositoryError(w http.ResponseWriter, err error, msg string) {
+	if errors.Is(err, repository.ErrAmbiguousRepository) {
+		jsonResponse(w, http.StatusConflict, false, "Repository name is tracked on several hosts, pass its provider and host", nil)
+		return
+	}
+	jsonResponse(w, http.StatusInternalServerError, false, msg, nil)
+}
+
+// repositoryNotFound writes the response of a failed repository lookup
+func repositoryNotFound(w http.ResponseWriter, err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		jsonResponse(w, http.StatusNotFound, false, "Repository not found", nil)
+		return
+	}
+	repositoryError(w, err, "Failed to get repository")
+}
+
 func handleAddRepo(
 	w http.ResponseWriter,
 	r *http.Request,
 	repoRepo *repository.RepositoryRepo,
 	commitRepo *repository.CommitRepo,
-	fetcher fetcher.GitHubFetcher,
+	providers *fetcher.Registry,
 	ctx context.Context,
-	token string,
 	cache *cache.Cache,
 ) {
 	var req struct {
-		Repo  string `json:"repo"`
-		Owner string `json:"owner"`
-		Date  string `json:"date"`
+		Repo     string `json:"repo"`
+		Owner    string `json:"owner"`
+		Date     string `json:"date"`
+		Provider string `json:"provider"`
+		Host     string `json:"host"`
+		// Branches lists the branch names or glob patterns to track besides the default branch
+		Branches []string `json:"branches"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		jsonResponse(w, http.StatusBadRequest, false, "Invalid request payload", nil)
 		return
 	}
 
+	// A missing start date must not silently turn into an import of the whole history
+	since, err := time.Parse(time.RFC3339, req.Date)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, false, "Invalid date, expected an RFC 3339 timestamp", nil)
+		return
+	}
+
+	if req.Provider == "" {
+		req.Provider = fetcher.ProviderGitHub
+	}
+	provider, err := providers.Get(req.Provider, req.Host)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, false, err.Error(), nil)
+		return
+	}
 	repoName := fmt.Sprintf("%s/%s", req.Owner, req.Repo)
+	target := &models.Repository{Name: repoName, Provider: strings.ToLower(req.Provider), Host: fetcher.CanonicalHost(req.Provider, req.Host)}
+	key := repository.RepositoryKey{Provider: target.Provider, Host: target.Host, Name: repoName}
+
+	token, err := providers.Token(target)
+	if err != nil {
+		jsonResponse(w, http.StatusInternalServerError, false, "Failed to get access token", nil)
+		return
+	}
 
-	if cached, found := getFromCache(cache, repoName); found {
+	if cached, found := getFromCache(cache, key.String()); found {
 		jsonResponse(w, http.StatusOK, true, "Repository found in cache", cached)
 		return
 	}
 
-	repo, err := fetcher.FetchRepository(repoName, token)
+	repo, err := provider.FetchRepository(repoName, token)
 	if err != nil {
 		jsonResponse(w, http.StatusInternalServerError, false, "Failed to fetch repository", nil)
 		return
 	}
+	repo.Provider = target.Provider
+	repo.Host = target.Host
+	repo.InstallationID = target.InstallationID
+	repo.TrackedBranches = req.Branches
+
+	repo.SyncFrom = &since
 
-	setToCache(cache, repoName, repo)
+	setToCache(cache, key.String(), repo)
 
 	if err := repoRepo.SaveRepository(ctx, repo); err != nil {
 		jsonResponse(w, http.StatusInternalServerError, false, "Failed to save repository", nil)
 		return
 	}
 
-	since, err := time.Parse(time.RFC3339, req.Date)
-	if err != nil {
-		log.Printf("invalid date format: %v", err)
-	}
+	// Importing a long history takes many requests, so it runs past the response
+	go backfillCommits(ctx, repoRepo, commitRepo, providers, provider, *repo, token, since)
 
-	log.Printf("Pulling commits for %s since %s", repoName, since.Format(time.RFC850))
+	jsonResponse(w, http.StatusAccepted, true, "Repository added, commits are imported in the background", repo)
+}
 
-	commits, err := fetcher.FetchCommits(repoName, token, req.Date)
+// backfillCommits imports the commits of a newly added repository from its start date on
+func backfillCommits(
+	ctx context.Context,
+	repoRepo *repository.RepositoryRepo,
+	commitRepo *repository.CommitRepo,
+	providers *fetcher.Registry,
+	provider fetcher.Provider,
+	repo models.Repository,
+	token string,
+	since time.Time,
+) {
+	log.Printf("Pulling commits for %s since %s", repo.Name, since.Format(time.RFC850))
+
+	commits, err := provider.FetchCommits(repo.Name, token, since.Format(time.RFC3339), "")
+	if err == nil {
+		err = providers.AttachCommitFiles(provider, repo.Name, token, commits)
+	}
 	if err != nil {
 		log.Printf("Failed to fetch commits: %v", err)
-	}
-
-	if err := commitRepo.SaveCommits(ctx, repo.ID, commits); err != nil {
+		if err := repoRepo.MarkSyncFailed(ctx, repo.ID, err); err != nil {
+			log.Printf("Failed to record sync error: %v", err)
+		}
+	} else if err := commitRepo.SaveCommits(ctx, repo.ID, commits); err != nil {
 		log.Printf("Failed to save commits: %v", err)
+	} else if err := repoRepo.MarkSynced(ctx, repo.ID, commits, time.Now()); err != nil {
+		log.Printf("Failed to update sync state: %v", err)
+	} else if err := recordDefaultBranch(ctx, repoRepo, commitRepo, &repo, commits); err != nil {
+		log.Printf("Failed to record branch of commits: %v", err)
+	} else {
+		log.Printf("Imported %d commits of %s", len(commits), repo.Name)
 	}
+}
 
-	jsonResponse(w, http.StatusCreated, true, "Repository added successfully", repo)
+// recordDefaultBranch records the initially imported commits on the default branch, whose cursor they start
+func recordDefaultBranch(
+	ctx context.Context,
+	repoRepo *repository.RepositoryRepo,
+	commitRepo *repository.CommitRepo,
+	repo *models.Repository,
+	commits []models.Commit,
+) error {
+	if repo.DefaultBranch == "" {
+		return nil
+	}
+	if err := commitRepo.RecordBranch(ctx, repo.ID, repo.DefaultBranch, commits); err != nil {
+		return err
+	}
+	return repoRepo.MarkBranchSynced(ctx, repo.ID, repo.DefaultBranch, commits, time.Now())
 }
 
 func handleGetRepo(w http.ResponseWriter, r *http.Request, repoRepo *repository.RepositoryRepo, ctx context.Context, cache *cache.Cache) {
-	repoName := r.URL.Query().Get("repo")
+	key := repositoryKey(r, r.URL.Query().Get("repo"))
 
-	if cached, found := getFromCache(cache, repoName); found {
+	if cached, found := getFromCache(cache, key.String()); found {
 		jsonResponse(w, http.StatusOK, true, "Repository found in cache", cached)
 		return
 	}
 
-	repo, err := repoRepo.GetRepository(ctx, repoName)
+	repo, err := repoRepo.GetRepository(ctx, key)
 	if err != nil {
-		jsonResponse(w, http.StatusNotFound, false, "Repository not found", nil)
+		repositoryNotFound(w, err)
 		return
 	}
 
-	setToCache(cache, repoName, repo)
+	setToCache(cache, key.String(), repo)
 	jsonResponse(w, http.StatusOK, true, "Repository found", repo)
 }
 
+func handleGetMetricHistory(w http.ResponseWriter, r *http.Request, repoRepo *repository.RepositoryRepo, ctx context.Context, cache *cache.Cache) {
+	key := repositoryKey(r, r.PathValue("owner")+"/"+r.PathValue("repo"))
+
+	metric := r.URL.Query().Get("metric")
+	if metric == "" {
+		metric = repository.MetricStars
+	}
+
+	var from, to time.Time
+	var err error
+	if value := r.URL.Query().Get("from"); value != "" {
+		if from, err = time.Parse(time.RFC3339, value); err != nil {
+			jsonResponse(w, http.StatusBadRequest, false, "Invalid from value", nil)
+			return
+		}
+	}
+	if value := r.URL.Query().Get("to"); value != "" {
+		if to, err = time.Parse(time.RFC3339, value); err != nil {
+			jsonResponse(w, http.StatusBadRequest, false, "Invalid to value", nil)
+			return
+		}
+	}
+
+	cacheKey := fmt.Sprintf("%s_history_%s_%s_%s", key, metric, r.URL.Query().Get("from"), r.URL.Query().Get("to"))
+	if cached, found := getFromCache(cache, cacheKey); found {
+		jsonResponse(w, http.StatusOK, true, "History found in cache", cached)
+		return
+	}
+
+	history, err := repoRepo.GetMetricHistory(ctx, key, metric, from, to)
+	if errors.Is(err, repository.ErrUnknownMetric) {
+		jsonResponse(w, http.StatusBadRequest, false, "Invalid metric value", nil)
+		return
+	}
+	if err != nil {
+		repositoryError(w, err, "Failed to fetch history")
+		return
+	}
+
+	setToCache(cache, cacheKey, history)
+	jsonResponse(w, http.StatusOK, true, "History retrieved", history)
+}
+
 func handleGetCommitAuthors(w http.ResponseWriter, r *http.Request, commitRepo *repository.CommitRepo, ctx context.Context, cache *cache.Cache) {
+	// all=true ranks the authors across every repository
+	all := false
+	if value := r.URL.Query().Get("all"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			jsonResponse(w, http.StatusBadRequest, false, "Invalid all value", nil)
+			return
+		}
+		all = parsed
+	}
+
 	repoName := r.URL.Query().Get("repo")
-	if repoName == "" {
+	if repoName == "" && !all {
 		jsonResponse(w, http.StatusBadRequest, false, "Repository name required", nil)
 		return
 	}
+	if all {
+		repoName = ""
+	}
 
 	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
 	if err != nil || limit <= 0 {
@@ -152,15 +394,38 @@ func handleGetCommitAuthors(w http.ResponseWriter, r *http.Request, commitRepo *
 		return
 	}
 
-	cacheKey := fmt.Sprintf("%s_authors_%d", repoName, limit)
+	since, until, err := parseTimeRange(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, false, err.Error(), nil)
+		return
+	}
+
+	excludeBots := false
+	if value := r.URL.Query().Get("exclude_bots"); value != "" {
+		excludeBots, err = strconv.ParseBool(value)
+		if err != nil {
+			jsonResponse(w, http.StatusBadRequest, false, "Invalid exclude_bots value", nil)
+			return
+		}
+	}
+
+	filter := repository.AuthorFilter{
+		Repo:        repositoryKey(r, repoName),
+		Branch:      r.URL.Query().Get("branch"),
+		Since:       since,
+		Until:       until,
+		ExcludeBots: excludeBots,
+	}
+
+	cacheKey := fmt.Sprintf("%s_authors_%d_%s_%d_%d_%t", filter.Repo, limit, filter.Branch, since.Unix(), until.Unix(), excludeBots)
 	if cached, found := getFromCache(cache, cacheKey); found {
 		jsonResponse(w, http.StatusOK, true, "Commit authors found in cache", cached)
 		return
 	}
 
-	authors, err := commitRepo.GetTopCommitAuthors(ctx, limit)
+	authors, err := commitRepo.GetTopCommitAuthors(ctx, filter, limit)
 	if err != nil {
-		jsonResponse(w, http.StatusInternalServerError, false, "Failed to fetch commit authors", nil)
+		repositoryError(w, err, "Failed to fetch commit authors")
 		return
 	}
 
@@ -169,35 +434,704 @@ func handleGetCommitAuthors(w http.ResponseWriter, r *http.Request, commitRepo *
 }
 
 func handleGetRepoCommit(w http.ResponseWriter, r *http.Request, commitRepo *repository.CommitRepo, ctx context.Context, cache *cache.Cache) {
-	repo := r.URL.Query().Get("repo")
-	if repo == "" {
+	repoName := r.URL.Query().Get("repo")
+	if repoName == "" {
 		jsonResponse(w, http.StatusBadRequest, false, "Missing repository name", nil)
 		return
 	}
+	key := repositoryKey(r, repoName)
 
 	size, _ := strconv.Atoi(r.URL.Query().Get("size"))
 	if size <= 0 {
 		size = 20
 	}
+	branch := r.URL.Query().Get("branch")
+
+	// Without a page number commits are paginated by cursor. Cursor pages are not cached,
+	// the first page has to show new commits and the others are pinned by their cursor.
+	if r.URL.Query().Get("page") == "" {
+		commits, err := commitRepo.GetCommitsPage(ctx, key, branch, r.URL.Query().Get("cursor"), size)
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			jsonResponse(w, http.StatusBadRequest, false, "Invalid cursor", nil)
+			return
+		}
+		if err != nil {
+			repositoryError(w, err, "Failed to fetch commits")
+			return
+		}
+		jsonPageResponse(w, "Commits retrieved", commits.Commits, commits.NextCursor, commits.PrevCursor)
+		return
+	}
+
 	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
 	if page <= 0 {
 		page = 1
 	}
 
 	offset := (page - 1) * size
-	cacheKey := fmt.Sprintf("%s_commits_%d_%d", repo, size, page)
+	cacheKey := fmt.Sprintf("%s_commits_%s_%d_%d", key, branch, size, page)
 
 	if cached, found := getFromCache(cache, cacheKey); found {
 		jsonResponse(w, http.StatusOK, true, "Commits found in cache", cached)
 		return
 	}
 
-	commits, err := commitRepo.GetCommitsByRepository(ctx, repo, size, offset)
+	commits, err := commitRepo.GetCommitsByRepository(ctx, key, branch, size, offset)
 	if err != nil {
-		jsonResponse(w, http.StatusInternalServerError, false, "Failed to fetch commits", nil)
+		repositoryError(w, err, "Failed to fetch commits")
 		return
 	}
 
 	setToCache(cache, cacheKey, commits)
 	jsonResponse(w, http.StatusOK, true, "Commits retrieved", commits)
 }
+
+func handleSearchCommits(w http.ResponseWriter, r *http.Request, commitRepo *repository.CommitRepo, ctx context.Context, cache *cache.Cache) {
+	query := r.URL.Query()
+
+	size, _ := strconv.Atoi(query.Get("size"))
+	if size <= 0 {
+		size = 20
+	}
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page <= 0 {
+		page = 1
+	}
+
+	since, until, err := parseTimeRange(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, false, err.Error(), nil)
+		return
+	}
+
+	search := repository.CommitSearch{
+		Author:    query.Get("author"),
+		Branch:    query.Get("branch"),
+		Since:     since,
+		Until:     until,
+		Text:      query.Get("q"),
+		SHAPrefix: query.Get("sha"),
+		Sort:      query.Get("sort"),
+	}
+
+	// Repositories are given as repeated or comma separated repo parameters, none searches them all.
+	// The provider and host parameters apply to every repository given.
+	for _, value := range query["repo"] {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				search.Repos = append(search.Repos, repositoryKey(r, name))
+			}
+		}
+	}
+
+	switch search.Sort {
+	case "":
+		search.Sort = repository.SortByDate
+	case repository.SortByDate, repository.SortByRelevance:
+	default:
+		jsonResponse(w, http.StatusBadRequest, false, "Invalid sort value, use date or relevance", nil)
+		return
+	}
+	if !isHex(search.SHAPrefix) {
+		jsonResponse(w, http.StatusBadRequest, false, "Invalid sha value", nil)
+		return
+	}
+
+	// Encode sorts the parameters, so equivalent searches share a cache entry
+	cacheKey := "commits_search_" + query.Encode()
+	if cached, found := getFromCache(cache, cacheKey); found {
+		jsonResponse(w, http.StatusOK, true, "Commits found in cache", cached)
+		return
+	}
+
+	commits, err := commitRepo.SearchCommits(ctx, search, size, (page-1)*size)
+	if err != nil {
+		jsonResponse(w, http.StatusInternalServerError, false, "Failed to search commits", nil)
+		return
+	}
+
+	setToCache(cache, cacheKey, commits)
+	jsonResponse(w, http.StatusOK, true, "Commits retrieved", commits)
+}
+
+// isHex reports whether a string only holds hexadecimal digits, as commit SHAs do
+func isHex(value string) bool {
+	for _, c := range value {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+	return len(value) <= 40
+}
+
+func handleGetBranches(w http.ResponseWriter, r *http.Request, repoRepo *repository.RepositoryRepo, ctx context.Context) {
+	repoName := r.URL.Query().Get("repo")
+	if repoName == "" {
+		jsonResponse(w, http.StatusBadRequest, false, "Repository name required", nil)
+		return
+	}
+	key := repositoryKey(r, repoName)
+
+	repo, err := repoRepo.GetRepository(ctx, key)
+	if err != nil {
+		repositoryNotFound(w, err)
+		return
+	}
+
+	branches, err := repoRepo.GetBranches(ctx, repo.ID)
+	if err != nil {
+		jsonResponse(w, http.StatusInternalServerError, false, "Failed to fetch branches", nil)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, true, "Branches retrieved", map[string]interface{}{
+		"default_branch":   repo.DefaultBranch,
+		"tracked_branches": repo.TrackedBranches,
+		"branches":         branches,
+	})
+}
+
+func handleSetTrackedBranches(w http.ResponseWriter, r *http.Request, repoRepo *repository.RepositoryRepo, ctx context.Context, cache *cache.Cache) {
+	var req struct {
+		Repo     string   `json:"repo"`
+		Provider string   `json:"provider"`
+		Host     string   `json:"host"`
+		Branches []string `json:"branches"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Repo == "" {
+		jsonResponse(w, http.StatusBadRequest, false, "Invalid request payload", nil)
+		return
+	}
+
+	provider := strings.ToLower(req.Provider)
+	key := repository.RepositoryKey{Provider: provider, Host: fetcher.CanonicalHost(provider, req.Host), Name: req.Repo}
+	repo, err := repoRepo.GetRepository(ctx, key)
+	if err != nil {
+		repositoryNotFound(w, err)
+		return
+	}
+
+	if err := repoRepo.SetTrackedBranches(ctx, repo.ID, req.Branches); err != nil {
+		jsonResponse(w, http.StatusInternalServerError, false, "Failed to update tracked branches", nil)
+		return
+	}
+	repo.TrackedBranches = req.Branches
+
+	setToCache(cache, key.String(), repo)
+	jsonResponse(w, http.StatusOK, true, "Tracked branches updated", repo)
+}
+
+func handleGetHistoryRewrites(w http.ResponseWriter, r *http.Request, commitRepo *repository.CommitRepo, ctx context.Context) {
+	repoName := r.URL.Query().Get("repo")
+	if repoName == "" {
+		jsonResponse(w, http.StatusBadRequest, false, "Repository name required", nil)
+		return
+	}
+	key := repositoryKey(r, repoName)
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 {
+		limit = 20
+	}
+
+	rewrites, err := commitRepo.GetHistoryRewrites(ctx, key, limit)
+	if err != nil {
+		repositoryError(w, err, "Failed to fetch history rewrites")
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, true, "History rewrites retrieved", rewrites)
+}
+
+func handleGetReleases(w http.ResponseWriter, r *http.Request, releaseRepo *repository.ReleaseRepo, ctx context.Context, cache *cache.Cache) {
+	repoName := r.URL.Query().Get("repo")
+	if repoName == "" {
+		jsonResponse(w, http.StatusBadRequest, false, "Repository name required", nil)
+		return
+	}
+	key := repositoryKey(r, repoName)
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 {
+		limit = 20
+	}
+
+	cacheKey := fmt.Sprintf("%s_releases_%d", key, limit)
+	if cached, found := getFromCache(cache, cacheKey); found {
+		jsonResponse(w, http.StatusOK, true, "Releases found in cache", cached)
+		return
+	}
+
+	releases, err := releaseRepo.GetReleases(ctx, key, limit)
+	if err != nil {
+		repositoryError(w, err, "Failed to fetch releases")
+		return
+	}
+
+	setToCache(cache, cacheKey, releases)
+	jsonResponse(w, http.StatusOK, true, "Releases retrieved", releases)
+}
+
+func handleGetReleaseCommits(w http.ResponseWriter, r *http.Request, releaseRepo *repository.ReleaseRepo, ctx context.Context, cache *cache.Cache) {
+	repoName := r.URL.Query().Get("repo")
+	tag := r.URL.Query().Get("tag")
+	if repoName == "" || tag == "" {
+		jsonResponse(w, http.StatusBadRequest, false, "Repository name and tag required", nil)
+		return
+	}
+	key := repositoryKey(r, repoName)
+
+	cacheKey := fmt.Sprintf("%s_release_commits_%s", key, tag)
+	if cached, found := getFromCache(cache, cacheKey); found {
+		jsonResponse(w, http.StatusOK, true, "Release commits found in cache", cached)
+		return
+	}
+
+	release, err := releaseRepo.GetRelease(ctx, key, tag)
+	if errors.Is(err, sql.ErrNoRows) {
+		jsonResponse(w, http.StatusNotFound, false, "Release not found", nil)
+		return
+	}
+	if err != nil {
+		repositoryError(w, err, "Failed to fetch release")
+		return
+	}
+
+	commits, err := releaseRepo.GetReleaseCommits(ctx, release.ID)
+	if err != nil {
+		jsonResponse(w, http.StatusInternalServerError, false, "Failed to fetch release commits", nil)
+		return
+	}
+
+	data := map[string]interface{}{"release": release, "commits": commits}
+	setToCache(cache, cacheKey, data)
+	jsonResponse(w, http.StatusOK, true, "Release commits retrieved", data)
+}
+
+func handleGetTags(w http.ResponseWriter, r *http.Request, releaseRepo *repository.ReleaseRepo, ctx context.Context, cache *cache.Cache) {
+	repoName := r.URL.Query().Get("repo")
+	if repoName == "" {
+		jsonResponse(w, http.StatusBadRequest, false, "Repository name required", nil)
+		return
+	}
+	key := repositoryKey(r, repoName)
+
+	cacheKey := fmt.Sprintf("%s_tags", key)
+	if cached, found := getFromCache(cache, cacheKey); found {
+		jsonResponse(w, http.StatusOK, true, "Tags found in cache", cached)
+		return
+	}
+
+	tags, err := releaseRepo.GetTags(ctx, key)
+	if err != nil {
+		repositoryError(w, err, "Failed to fetch tags")
+		return
+	}
+
+	setToCache(cache, cacheKey, tags)
+	jsonResponse(w, http.StatusOK, true, "Tags retrieved", tags)
+}
+
+func handleGetPullRequests(w http.ResponseWriter, r *http.Request, pullRequestRepo *repository.PullRequestRepo, ctx context.Context, cache *cache.Cache) {
+	repoName := r.URL.Query().Get("repo")
+	if repoName == "" {
+		jsonResponse(w, http.StatusBadRequest, false, "Repository name required", nil)
+		return
+	}
+	key := repositoryKey(r, repoName)
+
+	state := r.URL.Query().Get("state")
+	switch state {
+	case "", models.PullRequestOpen, models.PullRequestClosed, models.PullRequestMerged:
+	default:
+		jsonResponse(w, http.StatusBadRequest, false, "Invalid state value", nil)
+		return
+	}
+
+	size, _ := strconv.Atoi(r.URL.Query().Get("size"))
+	if size <= 0 {
+		size = 20
+	}
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page <= 0 {
+		page = 1
+	}
+
+	cacheKey := fmt.Sprintf("%s_pulls_%s_%d_%d", key, state, size, page)
+	if cached, found := getFromCache(cache, cacheKey); found {
+		jsonResponse(w, http.StatusOK, true, "Pull requests found in cache", cached)
+		return
+	}
+
+	pulls, err := pullRequestRepo.GetPullRequests(ctx, key, state, size, (page-1)*size)
+	if err != nil {
+		repositoryError(w, err, "Failed to fetch pull requests")
+		return
+	}
+
+	setToCache(cache, cacheKey, pulls)
+	jsonResponse(w, http.StatusOK, true, "Pull requests retrieved", pulls)
+}
+
+func handleGetPullRequestStats(w http.ResponseWriter, r *http.Request, pullRequestRepo *repository.PullRequestRepo, ctx context.Context, cache *cache.Cache) {
+	repoName := r.URL.Query().Get("repo")
+	if repoName == "" {
+		jsonResponse(w, http.StatusBadRequest, false, "Repository name required", nil)
+		return
+	}
+	key := repositoryKey(r, repoName)
+
+	since, until, err := parseTimeRange(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, false, err.Error(), nil)
+		return
+	}
+
+	cacheKey := fmt.Sprintf("%s_pull_stats_%s_%s", key, r.URL.Query().Get("since"), r.URL.Query().Get("until"))
+	if cached, found := getFromCache(cache, cacheKey); found {
+		jsonResponse(w, http.StatusOK, true, "Pull request stats found in cache", cached)
+		return
+	}
+
+	stats, err := pullRequestRepo.GetPullRequestStats(ctx, key, since, until)
+	if err != nil {
+		repositoryError(w, err, "Failed to compute pull request stats")
+		return
+	}
+
+	setToCache(cache, cacheKey, stats)
+	jsonResponse(w, http.StatusOK, true, "Pull request stats retrieved", stats)
+}
+
+func handleGetIssues(w http.ResponseWriter, r *http.Request, issueRepo *repository.IssueRepo, ctx context.Context, cache *cache.Cache) {
+	repoName := r.URL.Query().Get("repo")
+	if repoName == "" {
+		jsonResponse(w, http.StatusBadRequest, false, "Repository name required", nil)
+		return
+	}
+	key := repositoryKey(r, repoName)
+
+	state := r.URL.Query().Get("state")
+	if state != "" && state != models.IssueOpen && state != models.IssueClosed {
+		jsonResponse(w, http.StatusBadRequest, false, "Invalid state value", nil)
+		return
+	}
+	label := r.URL.Query().Get("label")
+
+	size, _ := strconv.Atoi(r.URL.Query().Get("size"))
+	if size <= 0 {
+		size = 20
+	}
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page <= 0 {
+		page = 1
+	}
+
+	cacheKey := fmt.Sprintf("%s_issues_%s_%s_%d_%d", key, state, label, size, page)
+	if cached, found := getFromCache(cache, cacheKey); found {
+		jsonResponse(w, http.StatusOK, true, "Issues found in cache", cached)
+		return
+	}
+
+	issues, err := issueRepo.GetIssues(ctx, key, state, label, size, (page-1)*size)
+	if err != nil {
+		repositoryError(w, err, "Failed to fetch issues")
+		return
+	}
+
+	setToCache(cache, cacheKey, issues)
+	jsonResponse(w, http.StatusOK, true, "Issues retrieved", issues)
+}
+
+func handleGetIssueTrend(w http.ResponseWriter, r *http.Request, issueRepo *repository.IssueRepo, ctx context.Context, cache *cache.Cache) {
+	repoName := r.URL.Query().Get("repo")
+	if repoName == "" {
+		jsonResponse(w, http.StatusBadRequest, false, "Repository name required", nil)
+		return
+	}
+	key := repositoryKey(r, repoName)
+
+	since, until, err := parseTimeRange(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, false, err.Error(), nil)
+		return
+	}
+
+	interval := r.URL.Query().Get("interval")
+	switch interval {
+	case "":
+		interval = repository.IntervalWeek
+	case repository.IntervalDay, repository.IntervalWeek, repository.IntervalMonth:
+	default:
+		jsonResponse(w, http.StatusBadRequest, false, "Invalid interval value", nil)
+		return
+	}
+
+	cacheKey := fmt.Sprintf("%s_issue_trend_%s_%s_%s", key, interval, r.URL.Query().Get("since"), r.URL.Query().Get("until"))
+	if cached, found := getFromCache(cache, cacheKey); found {
+		jsonResponse(w, http.StatusOK, true, "Issue trend found in cache", cached)
+		return
+	}
+
+	trend, err := issueRepo.GetIssueTrend(ctx, key, since, until, interval)
+	if errors.Is(err, repository.ErrTooManyPoints) {
+		jsonResponse(w, http.StatusBadRequest, false, "Too many points, use a larger interval", nil)
+		return
+	}
+	if err != nil {
+		repositoryError(w, err, "Failed to compute issue trend")
+		return
+	}
+
+	setToCache(cache, cacheKey, trend)
+	jsonResponse(w, http.StatusOK, true, "Issue trend retrieved", trend)
+}
+
+func handleGetIssueStats(w http.ResponseWriter, r *http.Request, issueRepo *repository.IssueRepo, ctx context.Context, cache *cache.Cache) {
+	repoName := r.URL.Query().Get("repo")
+	if repoName == "" {
+		jsonResponse(w, http.StatusBadRequest, false, "Repository name required", nil)
+		return
+	}
+	key := repositoryKey(r, repoName)
+
+	since, until, err := parseTimeRange(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, false, err.Error(), nil)
+		return
+	}
+
+	cacheKey := fmt.Sprintf("%s_issue_stats_%s_%s", key, r.URL.Query().Get("since"), r.URL.Query().Get("until"))
+	if cached, found := getFromCache(cache, cacheKey); found {
+		jsonResponse(w, http.StatusOK, true, "Issue stats found in cache", cached)
+		return
+	}
+
+	stats, err := issueRepo.GetIssueStats(ctx, key, since, until)
+	if err != nil {
+		repositoryError(w, err, "Failed to compute issue stats")
+		return
+	}
+
+	setToCache(cache, cacheKey, stats)
+	jsonResponse(w, http.StatusOK, true, "Issue stats retrieved", stats)
+}
+
+func handleGetContributors(w http.ResponseWriter, r *http.Request, contributorRepo *repository.ContributorRepo, ctx context.Context) {
+	size, _ := strconv.Atoi(r.URL.Query().Get("size"))
+	if size <= 0 {
+		size = 20
+	}
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page <= 0 {
+		page = 1
+	}
+
+	contributors, err := contributorRepo.GetContributors(ctx, size, (page-1)*size)
+	if err != nil {
+		jsonResponse(w, http.StatusInternalServerError, false, "Failed to fetch contributors", nil)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, true, "Contributors retrieved", contributors)
+}
+
+func handleGetContributor(w http.ResponseWriter, r *http.Request, contributorRepo *repository.ContributorRepo, ctx context.Context) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, false, "Invalid contributor id", nil)
+		return
+	}
+
+	contributor, err := contributorRepo.GetContributor(ctx, uint(id))
+	if errors.Is(err, sql.ErrNoRows) {
+		jsonResponse(w, http.StatusNotFound, false, "Contributor not found", nil)
+		return
+	}
+	if err != nil {
+		jsonResponse(w, http.StatusInternalServerError, false, "Failed to fetch contributor", nil)
+		return
+	}
+
+	activity, err := contributorRepo.GetActivity(ctx, contributor.ID, repository.RepositoryKey{})
+	if err != nil {
+		jsonResponse(w, http.StatusInternalServerError, false, "Failed to fetch contributor activity", nil)
+		return
+	}
+
+	data := map[string]interface{}{"contributor": contributor, "repositories": activity}
+	jsonResponse(w, http.StatusOK, true, "Contributor retrieved", data)
+}
+
+func handleMergeContributors(w http.ResponseWriter, r *http.Request, contributorRepo *repository.ContributorRepo, ctx context.Context) {
+	var req struct {
+		// Into is the contributor the others are merged into
+		Into uint   `json:"into"`
+		From []uint `json:"from"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Into == 0 || len(req.From) == 0 {
+		jsonResponse(w, http.StatusBadRequest, false, "Invalid request body", nil)
+		return
+	}
+
+	err := contributorRepo.Merge(ctx, req.Into, req.From)
+	if errors.Is(err, sql.ErrNoRows) {
+		jsonResponse(w, http.StatusNotFound, false, "Contributor not found", nil)
+		return
+	}
+	if err != nil {
+		jsonResponse(w, http.StatusInternalServerError, false, "Failed to merge contributors", nil)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, true, "Contributors merged", nil)
+}
+
+func handleApplyMailmap(w http.ResponseWriter, r *http.Request, contributorRepo *repository.ContributorRepo, ctx context.Context) {
+	entries, err := repository.ParseMailmap(r.Body)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, false, err.Error(), nil)
+		return
+	}
+
+	if err := contributorRepo.ApplyMailmap(ctx, entries); err != nil {
+		jsonResponse(w, http.StatusInternalServerError, false, "Failed to apply mailmap", nil)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, true, fmt.Sprintf("Applied %d mailmap entries", len(entries)), nil)
+}
+
+func handleGetRepoContributors(w http.ResponseWriter, r *http.Request, contributorRepo *repository.ContributorRepo, ctx context.Context, cache *cache.Cache) {
+	repoName := r.URL.Query().Get("repo")
+	if repoName == "" {
+		jsonResponse(w, http.StatusBadRequest, false, "Repository name required", nil)
+		return
+	}
+	key := repositoryKey(r, repoName)
+
+	cacheKey := fmt.Sprintf("%s_contributors", key)
+	if cached, found := getFromCache(cache, cacheKey); found {
+		jsonResponse(w, http.StatusOK, true, "Contributors found in cache", cached)
+		return
+	}
+
+	activity, err := contributorRepo.GetActivity(ctx, 0, key)
+	if err != nil {
+		repositoryError(w, err, "Failed to fetch contributors")
+		return
+	}
+
+	setToCache(cache, cacheKey, activity)
+	jsonResponse(w, http.StatusOK, true, "Contributors retrieved", activity)
+}
+
+func handleGetRepositoryChurn(w http.ResponseWriter, r *http.Request, commitRepo *repository.CommitRepo, ctx context.Context, cache *cache.Cache) {
+	since, until, err := parseTimeRange(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, false, err.Error(), nil)
+		return
+	}
+
+	cacheKey := fmt.Sprintf("churn_repos_%d_%d", since.Unix(), until.Unix())
+	if cached, found := getFromCache(cache, cacheKey); found {
+		jsonResponse(w, http.StatusOK, true, "Churn found in cache", cached)
+		return
+	}
+
+	churn, err := commitRepo.GetChurnByRepository(ctx, since, until)
+	if err != nil {
+		jsonResponse(w, http.StatusInternalServerError, false, "Failed to fetch churn", nil)
+		return
+	}
+
+	setToCache(cache, cacheKey, churn)
+	jsonResponse(w, http.StatusOK, true, "Churn retrieved", churn)
+}
+
+func handleGetAuthorChurn(w http.ResponseWriter, r *http.Request, commitRepo *repository.CommitRepo, ctx context.Context, cache *cache.Cache) {
+	repoName := r.URL.Query().Get("repo")
+	if repoName == "" {
+		jsonResponse(w, http.StatusBadRequest, false, "Repository name required", nil)
+		return
+	}
+	key := repositoryKey(r, repoName)
+	since, until, err := parseTimeRange(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, false, err.Error(), nil)
+		return
+	}
+
+	cacheKey := fmt.Sprintf("%s_churn_authors_%d_%d", key, since.Unix(), until.Unix())
+	if cached, found := getFromCache(cache, cacheKey); found {
+		jsonResponse(w, http.StatusOK, true, "Churn found in cache", cached)
+		return
+	}
+
+	churn, err := commitRepo.GetChurnByAuthor(ctx, key, since, until)
+	if err != nil {
+		repositoryError(w, err, "Failed to fetch churn")
+		return
+	}
+
+	setToCache(cache, cacheKey, churn)
+	jsonResponse(w, http.StatusOK, true, "Churn retrieved", churn)
+}
+
+func handleGetDirectoryChurn(w http.ResponseWriter, r *http.Request, commitRepo *repository.CommitRepo, ctx context.Context, cache *cache.Cache) {
+	repoName := r.URL.Query().Get("repo")
+	if repoName == "" {
+		jsonResponse(w, http.StatusBadRequest, false, "Repository name required", nil)
+		return
+	}
+	key := repositoryKey(r, repoName)
+	since, until, err := parseTimeRange(r)
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, false, err.Error(), nil)
+		return
+	}
+
+	// A depth of 0 keeps the full directory path
+	depth := 0
+	if value := r.URL.Query().Get("depth"); value != "" {
+		depth, err = strconv.Atoi(value)
+		if err != nil || depth < 0 {
+			jsonResponse(w, http.StatusBadRequest, false, "Invalid depth value", nil)
+			return
+		}
+	}
+
+	cacheKey := fmt.Sprintf("%s_churn_directories_%d_%d_%d", key, since.Unix(), until.Unix(), depth)
+	if cached, found := getFromCache(cache, cacheKey); found {
+		jsonResponse(w, http.StatusOK, true, "Churn found in cache", cached)
+		return
+	}
+
+	churn, err := commitRepo.GetChurnByDirectory(ctx, key, since, until, depth)
+	if err != nil {
+		repositoryError(w, err, "Failed to fetch churn")
+		return
+	}
+
+	setToCache(cache, cacheKey, churn)
+	jsonResponse(w, http.StatusOK, true, "Churn retrieved", churn)
+}
+
+// parseTimeRange reads the optional RFC3339 "since" and "until" query parameters, leaving missing bounds zero
+func parseTimeRange(r *http.Request) (since, until time.Time, err error) {
+	if value := r.URL.Query().Get("since"); value != "" {
+		if since, err = time.Parse(time.RFC3339, value); err != nil {
+			return since, until, errors.New("Invalid since value")
+		}
+	}
+	if value := r.URL.Query().Get("until"); value != "" {
+		if until, err = time.Parse(time.RFC3339, value); err != nil {
+			return since, until, errors.New("Invalid until value")
+		}
+	}
+	return since, until, nil
+}
+
+func handleGetRateLimit(w http.ResponseWriter, r *http.Request, providers *fetcher.Registry) {
+	jsonResponse(w, http.StatusOK, true, "Rate limit retrieved", providers.GitHub.RateLimit())
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"gmonitor/internal/fetcher"
+	"gmonitor/internal/models"
+	"gmonitor/internal/repository"
+	"gmonitor/pkg/cache"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// unreachableCache is a cache whose lookups fail, so handlers fall through to the database
+func unreachableCache() *cache.Cache {
+	return cache.NewCache(context.Background(), "127.0.0.1:1", "")
+}
+
+func addRepo(providers *fetcher.Registry, repos *repository.RepositoryRepo, commits *repository.CommitRepo, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/repos", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	handleAddRepo(rec, req, repos, commits, providers, context.Background(), unreachableCache())
+	return rec
+}
+
+func TestAddRepo_RejectsInvalidDate(t *testing.T) {
+	db := setupServerTestDB(t)
+	requested := false
+	providers := fetcher.NewRegistry(&fetcher.GitHubFetcher{
+		Request: func(url, token string, header http.Header) (*http.Response, error) {
+			requested = true
+			return nil, &fetcher.StatusError{StatusCode: http.StatusNotFound}
+		},
+	})
+
+	for _, date := range []string{"", "2025-01-01", "yesterday"} {
+		body := `{"owner": "octo", "repo": "widgets", "date": "` + date + `"}`
+		rec := addRepo(providers, repository.NewRepositoryRepo(db), repository.NewCommitRepo(db), body)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400 for date %q, got: %d", date, rec.Code)
+		}
+	}
+	if requested {
+		t.Error("expected no provider request for an invalid date")
+	}
+}
+
+func TestAddRepo_ImportsCommitsInBackground(t *testing.T) {
+	db := setupServerTestDB(t)
+	release := make(chan struct{})
+	providers := fetcher.NewRegistry(&fetcher.GitHubFetcher{
+		Request: func(url, token string, header http.Header) (*http.Response, error) {
+			body := `{"full_name": "octo/widgets", "html_url": "https://github.com/octo/widgets", "default_branch": "main"}`
+			if strings.Contains(url, "/commits?") {
+				// The import waits until the response was written
+				<-release
+				if !strings.Contains(url, "since=2025-01-01T00%3A00%3A00Z") {
+					t.Errorf("expected the commits since the start date, got: %s", url)
+				}
+				body = `[{"sha": "aaa", "commit": {"message": "first", "author": {"name": "Jane", "date": "2025-01-02T00:00:00Z"}}}]`
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+		},
+	})
+	repos := repository.NewRepositoryRepo(db)
+
+	rec := addRepo(providers, repos, repository.NewCommitRepo(db), `{"owner": "octo", "repo": "widgets", "date": "2025-01-01T00:00:00Z"}`)
+	close(release)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected status 202, got: %d %s", rec.Code, rec.Body.String())
+	}
+
+	var response JSONResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil || !response.Success {
+		t.Fatalf("expected a successful response, got: %s (%v)", rec.Body.String(), err)
+	}
+
+	// The default branch is recorded last
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		branch, err := repos.GetBranch(context.Background(), 1, "main")
+		if err != nil {
+			t.Fatalf("failed to get branch: %v", err)
+		}
+		if branch.LastSyncedAt != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the background import")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	repo, err := repos.GetRepository(context.Background(), repository.RepositoryName("octo/widgets"))
+	if err != nil {
+		t.Fatalf("failed to get repository: %v", err)
+	}
+	if repo.LastCommitSHA != "aaa" {
+		t.Errorf("expected the imported commit to start the cursor, got: %q", repo.LastCommitSHA)
+	}
+
+	var count int64
+	db.Model(&models.Commit{}).Count(&count)
+	if count != 1 {
+		t.Errorf("expected 1 imported commit, got: %d", count)
+	}
+}
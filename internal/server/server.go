@@ -13,12 +13,22 @@ import (
 )
 
 // StartServer initializes and starts the HTTP server
-func StartServer(ctx context.Context, cfg config.Config, repoRepo *repository.RepositoryRepo, commitRepo *repository.CommitRepo, fetcher fetcher.GitHubFetcher, cache *cache.Cache,
+func StartServer(
+	ctx context.Context,
+	cfg config.Config,
+	repoRepo *repository.RepositoryRepo,
+	commitRepo *repository.CommitRepo,
+	releaseRepo *repository.ReleaseRepo,
+	pullRequestRepo *repository.PullRequestRepo,
+	issueRepo *repository.IssueRepo,
+	contributorRepo *repository.ContributorRepo,
+	providers *fetcher.Registry,
+	cache *cache.Cache,
 ) {
 	mux := http.NewServeMux()
 
 	// Register handlers
-	RegisterHandlers(mux, repoRepo, commitRepo, fetcher, ctx, cfg.GitHubToken, cache)
+	RegisterHandlers(mux, repoRepo, commitRepo, releaseRepo, pullRequestRepo, issueRepo, contributorRepo, providers, ctx, cache, cfg.WebhookSecret)
 
 	server := &http.Server{
 		Addr:    fmt.Sprintf(":%s", cfg.PORT),
@@ -0,0 +1,445 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gmonitor/internal/fetcher"
+	"gmonitor/internal/models"
+	"gmonitor/internal/repository"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// maxWebhookPayload is the largest payload GitHub delivers
+const maxWebhookPayload = 25 << 20
+
+// webhookPayload holds the fields gmonitor uses from push, repository, create/delete and release events
+type webhookPayload struct {
+	Action     string            `json:"action"`
+	Ref        string            `json:"ref"`
+	RefType    string            `json:"ref_type"`
+	Before     string            `json:"before"`
+	After      string            `json:"after"`
+	Forced     bool              `json:"forced"`
+	Commits    []webhookCommit   `json:"commits"`
+	Repository webhookRepository `json:"repository"`
+	Release    struct {
+		TagName     string     `json:"tag_name"`
+		Name        string     `json:"name"`
+		Body        string     `json:"body"`
+		Draft       bool       `json:"draft"`
+		Prerelease  bool       `json:"prerelease"`
+		HTMLURL     string     `json:"html_url"`
+		PublishedAt *time.Time `json:"published_at"`
+	} `json:"release"`
+	Changes struct {
+		Repository struct {
+			Name struct {
+				From string `json:"from"`
+			} `json:"name"`
+		} `json:"repository"`
+	} `json:"changes"`
+}
+
+// webhookRepository is the repository object embedded in every event
+type webhookRepository struct {
+	Name            string    `json:"name"`
+	FullName        string    `json:"full_name"`
+	Description     string    `json:"description"`
+	HTMLURL         string    `json:"html_url"`
+	Language        string    `json:"language"`
+	DefaultBranch   string    `json:"default_branch"`
+	ForksCount      int       `json:"forks_count"`
+	StargazersCount int       `json:"stargazers_count"`
+	WatchersCount   int       `json:"watchers_count"`
+	OpenIssuesCount int       `json:"open_issues_count"`
+	UpdatedAt       time.Time `json:"updated_at"`
+	Owner           struct {
+		Login string `json:"login"`
+	} `json:"owner"`
+}
+
+// webhookCommit is a commit as listed in a push event
+type webhookCommit struct {
+	ID        string    `json:"id"`
+	Message   string    `json:"message"`
+	Timestamp time.Time `json:"timestamp"`
+	URL       string    `json:"url"`
+	TreeID    string    `json:"tree_id"`
+	Author    struct {
+		Name     string `json:"name"`
+		Email    string `json:"email"`
+		Username string `json:"username"`
+	} `json:"author"`
+	Committer struct {
+		Name  string `json:"name"`
+		Email string `json:"email"`
+	} `json:"committer"`
+}
+
+func handleGitHubWebhook(
+	w http.ResponseWriter,
+	r *http.Request,
+	repoRepo *repository.RepositoryRepo,
+	commitRepo *repository.CommitRepo,
+	releaseRepo *repository.ReleaseRepo,
+	providers *fetcher.Registry,
+	ctx context.Context,
+	secret string,
+) {
+	if secret == "" {
+		jsonResponse(w, http.StatusForbidden, false, "Webhook secret not configured", nil)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookPayload))
+	if err != nil {
+		jsonResponse(w, http.StatusBadRequest, false, "Failed to read payload", nil)
+		return
+	}
+
+	if !validWebhookSignature(secret, body, r.Header.Get("X-Hub-Signature-256")) {
+		jsonResponse(w, http.StatusUnauthorized, false, "Invalid signature", nil)
+		return
+	}
+
+	event := r.Header.Get("X-GitHub-Event")
+	if event == "ping" {
+		jsonResponse(w, http.StatusOK, true, "pong", nil)
+		return
+	}
+
+	var payload webhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		jsonResponse(w, http.StatusBadRequest, false, "Invalid request payload", nil)
+		return
+	}
+
+	// Renamed repositories are still stored under their previous name
+	repoName := payload.Repository.FullName
+	if event == "repository" && payload.Action == "renamed" && payload.Changes.Repository.Name.From != "" {
+		repoName = payload.Repository.Owner.Login + "/" + payload.Changes.Repository.Name.From
+	}
+
+	// The host of the repository page tells github.com apart from GitHub Enterprise servers
+	key := repository.RepositoryKey{Provider: fetcher.ProviderGitHub, Name: repoName}
+	if page, err := url.Parse(payload.Repository.HTMLURL); err == nil {
+		key.Host = fetcher.CanonicalHost(fetcher.ProviderGitHub, page.Host)
+	}
+
+	repo, err := repoRepo.GetRepository(ctx, key)
+	if errors.Is(err, sql.ErrNoRows) {
+		jsonResponse(w, http.StatusAccepted, true, "Repository is not monitored", nil)
+		return
+	}
+	if err != nil {
+		jsonResponse(w, http.StatusInternalServerError, false, "Failed to get repository", nil)
+		return
+	}
+
+	var msg string
+	switch event {
+	case "push":
+		msg, err = handlePushEvent(ctx, repoRepo, commitRepo, providers, repo, &payload)
+	case "repository":
+		msg, err = handleRepositoryEvent(ctx, repoRepo, repo, &payload)
+	case "create", "delete":
+		msg, err = handleRefEvent(ctx, repoRepo, releaseRepo, providers, repo, event, &payload)
+	case "release":
+		msg, err = handleReleaseEvent(ctx, releaseRepo, repo, &payload)
+	default:
+		jsonResponse(w, http.StatusAccepted, true, "Event ignored", nil)
+		return
+	}
+
+	if err != nil {
+		log.Printf("Webhook: failed to process %s event for %s: %v", event, repo.Name, err)
+		jsonResponse(w, http.StatusInternalServerError, false, "Failed to process event", nil)
+		return
+	}
+
+	// Only processed pushes prove that deliveries keep the commits current and hold back the commit poll
+	if event == "push" {
+		if err := repoRepo.MarkWebhookReceived(ctx, repo.ID, time.Now()); err != nil {
+			log.Printf("Failed to record webhook delivery for %s: %v", repo.Name, err)
+		}
+	}
+	jsonResponse(w, http.StatusOK, true, msg, nil)
+}
+
+// handlePushEvent stores the commits pushed to the default branch or another tracked branch
+func handlePushEvent(
+	ctx context.Context,
+	repoRepo *repository.RepositoryRepo,
+	commitRepo *repository.CommitRepo,
+	providers *fetcher.Registry,
+	repo *models.Repository,
+	payload *webhookPayload,
+) (string, error) {
+	branch, isBranch := strings.CutPrefix(payload.Ref, "refs/heads/")
+	if !isBranch || !fetcher.TracksBranch(repo.TrackedBranches, payload.Repository.DefaultBranch, branch) {
+		return "Push to an untracked branch ignored", nil
+	}
+
+	commits := make([]models.Commit, 0, len(payload.Commits))
+	for _, commit := range payload.Commits {
+		// Push payloads carry neither parents nor signature verification, the reconciliation poll fills them in
+		commits = append(commits, models.Commit{
+			CommitHash:     commit.ID,
+			Author:         commit.Author.Name,
+			AuthorEmail:    commit.Author.Email,
+			AuthorLogin:    commit.Author.Username,
+			Message:        commit.Message,
+			CommitURL:      commit.URL,
+			CommitDate:     commit.Timestamp,
+			CommitterName:  commit.Committer.Name,
+			CommitterEmail: commit.Committer.Email,
+			TreeSHA:        commit.TreeID,
+		})
+	}
+
+	// The sync cursor is left to the polling reconciliation, so missed deliveries are still picked up
+	newCommits, err := commitRepo.FilterNewCommits(ctx, repo.ID, commits)
+	if err != nil {
+		return "", err
+	}
+	if err := attachCommitFiles(providers, repo, newCommits); err != nil {
+		return "", err
+	}
+	if err := commitRepo.SaveCommits(ctx, repo.ID, newCommits); err != nil {
+		return "", err
+	}
+	if err := recordForcedPush(ctx, commitRepo, providers, repo, branch, payload); err != nil {
+		return "", err
+	}
+
+	// The head moves along with the commits recorded on the branch, deleted branches are pushed with a zero head
+	err = repoRepo.InTransaction(ctx, func(repos *repository.RepositoryRepo, commitRepo *repository.CommitRepo) error {
+		if err := commitRepo.RecordBranch(ctx, repo.ID, branch, commits); err != nil {
+			return err
+		}
+		if isZeroSHA(payload.After) {
+			return nil
+		}
+		return repos.SetBranchHead(ctx, repo.ID, branch, payload.After)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("Added %d new commits", len(newCommits)), nil
+}
+
+// recordForcedPush records the commits a forced push dropped from a branch the way polling detects rewrites,
+// so the next poll does not have to compare the heads again
+func recordForcedPush(
+	ctx context.Context,
+	commitRepo *repository.CommitRepo,
+	providers *fetcher.Registry,
+	repo *models.Repository,
+	branch string,
+	payload *webhookPayload,
+) error {
+	// Deleted branches are pushed with a zero head, created ones with a zero previous head
+	if !payload.Forced || isZeroSHA(payload.After) || isZeroSHA(payload.Before) {
+		return nil
+	}
+
+	provider, err := providers.Get(repo.Provider, repo.Host)
+	if err != nil {
+		return err
+	}
+	history, ok := provider.(fetcher.HistoryFetcher)
+	if !ok {
+		return nil
+	}
+	token, err := providers.Token(repo)
+	if err != nil {
+		return err
+	}
+	lost, err := history.FetchUnreachable(repo.Name, token, payload.Before, payload.After)
+	if err != nil {
+		return fmt.Errorf("failed to compare heads of branch %s: %v", branch, err)
+	}
+	if len(lost) == 0 {
+		return nil
+	}
+
+	rewrite := &models.HistoryRewrite{
+		RepoID:     repo.ID,
+		Branch:     branch,
+		OldHeadSHA: payload.Before,
+		NewHeadSHA: payload.After,
+		DetectedAt: time.Now(),
+		LostSHAs:   lost,
+	}
+	if err := commitRepo.RecordHistoryRewrite(ctx, rewrite); err != nil {
+		return err
+	}
+	log.Printf("Webhook: history of %s rewritten on branch %s, %d commits dropped", repo.Name, branch, len(lost))
+	return nil
+}
+
+// isZeroSHA reports whether a push event SHA stands for a missing ref
+func isZeroSHA(sha string) bool {
+	return strings.Trim(sha, "0") == ""
+}
+
+// attachCommitFiles fetches the changed files of pushed commits when file statistics are enabled
+func attachCommitFiles(providers *fetcher.Registry, repo *models.Repository, commits []models.Commit) error {
+	if !providers.CommitFiles || len(commits) == 0 {
+		return nil
+	}
+
+	provider, err := providers.Get(repo.Provider, repo.Host)
+	if err != nil {
+		return err
+	}
+	token, err := providers.Token(repo)
+	if err != nil {
+		return err
+	}
+	return providers.AttachCommitFiles(provider, repo.Name, token, commits)
+}
+
+// handleRepositoryEvent refreshes the stored metadata of a repository
+func handleRepositoryEvent(ctx context.Context, repoRepo *repository.RepositoryRepo, repo *models.Repository, payload *webhookPayload) (string, error) {
+	if payload.Action == "deleted" {
+		log.Printf("Webhook: repository %s was deleted on GitHub", repo.Name)
+		return "Repository deletion noted", nil
+	}
+
+	updated := &models.Repository{
+		Name:            payload.Repository.FullName,
+		Description:     payload.Repository.Description,
+		URL:             payload.Repository.HTMLURL,
+		Language:        payload.Repository.Language,
+		ForksCount:      payload.Repository.ForksCount,
+		StarsCount:      payload.Repository.StargazersCount,
+		OpenIssuesCount: payload.Repository.OpenIssuesCount,
+		WatchersCount:   payload.Repository.WatchersCount,
+		UpdatedAt:       payload.Repository.UpdatedAt,
+	}
+	if err := repoRepo.UpdateMetadata(ctx, repo.ID, updated); err != nil {
+		return "", err
+	}
+
+	return "Repository metadata updated", nil
+}
+
+// handleRefEvent applies a created or deleted tag and drops the sync state of a deleted branch.
+// Created branches are read by the next poll.
+func handleRefEvent(
+	ctx context.Context,
+	repoRepo *repository.RepositoryRepo,
+	releaseRepo *repository.ReleaseRepo,
+	providers *fetcher.Registry,
+	repo *models.Repository,
+	event string,
+	payload *webhookPayload,
+) (string, error) {
+	log.Printf("Webhook: %s %s %q in %s", event, payload.RefType, payload.Ref, repo.Name)
+
+	switch {
+	case payload.RefType == "branch" && event == "delete":
+		if err := repoRepo.DeleteBranch(ctx, repo.ID, payload.Ref); err != nil {
+			return "", err
+		}
+		return "Branch deleted", nil
+	case payload.RefType == "tag" && event == "delete":
+		if err := releaseRepo.DeleteTag(ctx, repo.ID, payload.Ref); err != nil {
+			return "", err
+		}
+		return "Tag deleted", nil
+	case payload.RefType == "tag":
+		return storeCreatedTag(ctx, releaseRepo, providers, repo, payload.Ref)
+	}
+	return fmt.Sprintf("%s event received", event), nil
+}
+
+// storeCreatedTag stores a created tag, whose commit the create event does not carry
+func storeCreatedTag(
+	ctx context.Context,
+	releaseRepo *repository.ReleaseRepo,
+	providers *fetcher.Registry,
+	repo *models.Repository,
+	name string,
+) (string, error) {
+	provider, err := providers.Get(repo.Provider, repo.Host)
+	if err != nil {
+		return "", err
+	}
+	tags, ok := provider.(fetcher.TagTargetFetcher)
+	if !ok {
+		return "Tag creation noted", nil
+	}
+	token, err := providers.Token(repo)
+	if err != nil {
+		return "", err
+	}
+	target, err := tags.FetchTagTarget(repo.Name, token, name)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve tag %s: %v", name, err)
+	}
+
+	if err := releaseRepo.SaveTags(ctx, repo.ID, []models.Tag{{Name: name, TargetSHA: target}}); err != nil {
+		return "", err
+	}
+	return "Tag stored", nil
+}
+
+// handleReleaseEvent stores a published or edited release, deleted releases are kept
+func handleReleaseEvent(ctx context.Context, releaseRepo *repository.ReleaseRepo, repo *models.Repository, payload *webhookPayload) (string, error) {
+	log.Printf("Webhook: release %q %s in %s", payload.Release.TagName, payload.Action, repo.Name)
+	if payload.Action == "deleted" {
+		return "Release deletion noted", nil
+	}
+
+	// The commit of the tag is only known when the tag was fetched before, otherwise the next release sync fills it in
+	targets, err := releaseRepo.GetTagTargets(ctx, repo.ID)
+	if err != nil {
+		return "", err
+	}
+
+	release := models.Release{
+		TagName:     payload.Release.TagName,
+		Name:        payload.Release.Name,
+		TargetSHA:   targets[payload.Release.TagName],
+		Draft:       payload.Release.Draft,
+		Prerelease:  payload.Release.Prerelease,
+		Notes:       payload.Release.Body,
+		URL:         payload.Release.HTMLURL,
+		PublishedAt: payload.Release.PublishedAt,
+	}
+	if err := releaseRepo.SaveReleases(ctx, repo.ID, []models.Release{release}); err != nil {
+		return "", err
+	}
+
+	return "Release stored", nil
+}
+
+// validWebhookSignature checks an X-Hub-Signature-256 header against the HMAC of the payload
+func validWebhookSignature(secret string, body []byte, signature string) bool {
+	signature, found := strings.CutPrefix(signature, "sha256=")
+	if !found {
+		return false
+	}
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
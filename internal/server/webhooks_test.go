@@ -0,0 +1,392 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/glebarez/sqlite"
+	"gmonitor/internal/fetcher"
+	"gmonitor/internal/models"
+	"gmonitor/internal/monitor"
+	"gmonitor/internal/repository"
+	"gorm.io/gorm"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testWebhookSecret = "s3cret"
+
+// webhookTest holds the stores a webhook delivery is processed against
+type webhookTest struct {
+	db        *gorm.DB
+	repos     *repository.RepositoryRepo
+	commits   *repository.CommitRepo
+	releases  *repository.ReleaseRepo
+	providers *fetcher.Registry
+	repo      models.Repository
+}
+
+// setupServerTestDB opens a file database, which unlike an in-memory one is shared by the connections of background work
+func setupServerTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "gmonitor.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to connect test db: %v", err)
+	}
+	if err := db.AutoMigrate(
+		&models.Repository{}, &models.Commit{}, &models.CommitFile{}, &models.Branch{}, &models.CommitBranch{},
+		&models.Contributor{}, &models.ContributorAlias{}, &models.HistoryRewrite{}, &models.Tag{}, &models.Release{},
+	); err != nil {
+		t.Fatalf("failed to migrate schema: %v", err)
+	}
+	return db
+}
+
+func setupWebhookTest(t *testing.T, github *fetcher.GitHubFetcher) *webhookTest {
+	db := setupServerTestDB(t)
+
+	repo := models.Repository{Name: "octo/widgets", Provider: fetcher.ProviderGitHub, URL: "https://github.com/octo/widgets"}
+	if err := db.Create(&repo).Error; err != nil {
+		t.Fatalf("failed to create repository: %v", err)
+	}
+
+	return &webhookTest{
+		db:        db,
+		repos:     repository.NewRepositoryRepo(db),
+		commits:   repository.NewCommitRepo(db),
+		releases:  repository.NewReleaseRepo(db),
+		providers: fetcher.NewRegistry(github),
+		repo:      repo,
+	}
+}
+
+// deliver posts a webhook event and returns the response along with its message
+func (wt *webhookTest) deliver(t *testing.T, event, body, signature string) (*httptest.ResponseRecorder, string) {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/webhooks/github", strings.NewReader(body))
+	req.Header.Set("X-GitHub-Event", event)
+	if signature != "" {
+		req.Header.Set("X-Hub-Signature-256", signature)
+	}
+
+	rec := httptest.NewRecorder()
+	handleGitHubWebhook(rec, req, wt.repos, wt.commits, wt.releases, wt.providers, context.Background(), testWebhookSecret)
+
+	var response JSONResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode response %q: %v", rec.Body.String(), err)
+	}
+	return rec, response.Message
+}
+
+func sign(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestGitHubWebhook_RejectsUnsignedDeliveries(t *testing.T) {
+	wt := setupWebhookTest(t, &fetcher.GitHubFetcher{})
+	body := `{"zen": "Keep it logically awesome."}`
+
+	tests := []struct {
+		name      string
+		signature string
+	}{
+		{"missing", ""},
+		{"wrong secret", sign("other", body)},
+		{"not hex", "sha256=zz"},
+		{"no algorithm", strings.TrimPrefix(sign(testWebhookSecret, body), "sha256=")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, _ := wt.deliver(t, "ping", body, tt.signature)
+			if rec.Code != http.StatusUnauthorized {
+				t.Errorf("expected status 401, got: %d", rec.Code)
+			}
+		})
+	}
+}
+
+func TestGitHubWebhook_RequiresSecret(t *testing.T) {
+	wt := setupWebhookTest(t, &fetcher.GitHubFetcher{})
+	body := `{}`
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/webhooks/github", strings.NewReader(body))
+	req.Header.Set("X-GitHub-Event", "ping")
+	req.Header.Set("X-Hub-Signature-256", sign("", body))
+	rec := httptest.NewRecorder()
+	handleGitHubWebhook(rec, req, wt.repos, wt.commits, wt.releases, wt.providers, context.Background(), "")
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected status 403, got: %d", rec.Code)
+	}
+}
+
+func TestGitHubWebhook_Ping(t *testing.T) {
+	wt := setupWebhookTest(t, &fetcher.GitHubFetcher{})
+	body := `{"zen": "Keep it logically awesome."}`
+
+	rec, msg := wt.deliver(t, "ping", body, sign(testWebhookSecret, body))
+	if rec.Code != http.StatusOK || msg != "pong" {
+		t.Errorf("expected pong, got: %d %q", rec.Code, msg)
+	}
+}
+
+func TestGitHubWebhook_IgnoresUnmonitoredRepository(t *testing.T) {
+	wt := setupWebhookTest(t, &fetcher.GitHubFetcher{})
+	body := `{"ref": "refs/heads/main", "repository": {"full_name": "octo/other", "html_url": "https://github.com/octo/other"}}`
+
+	rec, msg := wt.deliver(t, "push", body, sign(testWebhookSecret, body))
+	if rec.Code != http.StatusAccepted || msg != "Repository is not monitored" {
+		t.Errorf("expected the delivery to be ignored, got: %d %q", rec.Code, msg)
+	}
+}
+
+func TestGitHubWebhook_PushStoresCommits(t *testing.T) {
+	wt := setupWebhookTest(t, &fetcher.GitHubFetcher{})
+	body := `{
+		"ref": "refs/heads/main",
+		"before": "0000000000000000000000000000000000000000",
+		"after": "bbb",
+		"repository": {"full_name": "octo/widgets", "html_url": "https://github.com/octo/widgets", "default_branch": "main"},
+		"commits": [
+			{"id": "aaa", "message": "first", "timestamp": "2024-01-01T10:00:00Z", "author": {"name": "Jane"}},
+			{"id": "bbb", "message": "second", "timestamp": "2024-01-01T11:00:00Z", "author": {"name": "Jane"}}
+		]
+	}`
+
+	rec, msg := wt.deliver(t, "push", body, sign(testWebhookSecret, body))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got: %d %q", rec.Code, msg)
+	}
+	if msg != "Added 2 new commits" {
+		t.Errorf("unexpected message: %q", msg)
+	}
+
+	// A redelivery stores nothing new
+	if _, msg := wt.deliver(t, "push", body, sign(testWebhookSecret, body)); msg != "Added 0 new commits" {
+		t.Errorf("expected a redelivery to add nothing, got: %q", msg)
+	}
+
+	branch, err := wt.repos.GetBranch(context.Background(), wt.repo.ID, "main")
+	if err != nil {
+		t.Fatalf("failed to get branch: %v", err)
+	}
+	if branch.HeadSHA != "bbb" {
+		t.Errorf("expected the branch head to move to the pushed head, got: %q", branch.HeadSHA)
+	}
+
+	var repo models.Repository
+	wt.db.First(&repo, wt.repo.ID)
+	if repo.LastWebhookAt == nil {
+		t.Error("expected the webhook delivery to be recorded")
+	}
+}
+
+func TestGitHubWebhook_PushToUntrackedBranchIsIgnored(t *testing.T) {
+	wt := setupWebhookTest(t, &fetcher.GitHubFetcher{})
+	body := `{
+		"ref": "refs/heads/feature",
+		"after": "ccc",
+		"repository": {"full_name": "octo/widgets", "html_url": "https://github.com/octo/widgets", "default_branch": "main"},
+		"commits": [{"id": "ccc", "message": "wip", "timestamp": "2024-01-01T10:00:00Z", "author": {"name": "Jane"}}]
+	}`
+
+	_, msg := wt.deliver(t, "push", body, sign(testWebhookSecret, body))
+	if msg != "Push to an untracked branch ignored" {
+		t.Errorf("unexpected message: %q", msg)
+	}
+
+	var count int64
+	wt.db.Model(&models.Commit{}).Count(&count)
+	if count != 0 {
+		t.Errorf("expected no commits, got: %d", count)
+	}
+}
+
+func TestGitHubWebhook_ForcedPushRecordsRewrite(t *testing.T) {
+	github := &fetcher.GitHubFetcher{
+		Request: func(url, token string, header http.Header) (*http.Response, error) {
+			if !strings.Contains(url, "/repos/octo/widgets/compare/new...old") {
+				t.Errorf("unexpected url: %s", url)
+			}
+			body := `{"status": "ahead", "commits": [{"sha": "old"}]}`
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+		},
+	}
+	wt := setupWebhookTest(t, github)
+
+	ctx := context.Background()
+	if err := wt.commits.SaveCommits(ctx, wt.repo.ID, []models.Commit{{CommitHash: "old", Message: "dropped"}}); err != nil {
+		t.Fatalf("failed to save commits: %v", err)
+	}
+	_ = wt.commits.RecordBranch(ctx, wt.repo.ID, "main", []models.Commit{{CommitHash: "old"}})
+
+	body := `{
+		"ref": "refs/heads/main",
+		"before": "old",
+		"after": "new",
+		"forced": true,
+		"repository": {"full_name": "octo/widgets", "html_url": "https://github.com/octo/widgets", "default_branch": "main"},
+		"commits": [{"id": "new", "message": "amended", "timestamp": "2024-01-01T10:00:00Z", "author": {"name": "Jane"}}]
+	}`
+
+	rec, msg := wt.deliver(t, "push", body, sign(testWebhookSecret, body))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got: %d %q", rec.Code, msg)
+	}
+
+	rewrites, err := wt.commits.GetHistoryRewrites(ctx, repository.RepositoryName("octo/widgets"), 10)
+	if err != nil {
+		t.Fatalf("failed to get history rewrites: %v", err)
+	}
+	if len(rewrites) != 1 || rewrites[0].OldHeadSHA != "old" || rewrites[0].NewHeadSHA != "new" {
+		t.Fatalf("expected the forced push to be recorded, got: %+v", rewrites)
+	}
+
+	var dropped models.Commit
+	wt.db.Where("commit_hash = ?", "old").First(&dropped)
+	if !dropped.Orphaned {
+		t.Error("expected the dropped commit to be orphaned")
+	}
+
+	branch, _ := wt.repos.GetBranch(ctx, wt.repo.ID, "main")
+	if branch.HeadSHA != "new" {
+		t.Errorf("expected the branch head to move to the pushed head, got: %q", branch.HeadSHA)
+	}
+}
+
+func TestGitHubWebhook_ReleaseStoresRelease(t *testing.T) {
+	wt := setupWebhookTest(t, &fetcher.GitHubFetcher{})
+	body := `{
+		"action": "published",
+		"repository": {"full_name": "octo/widgets", "html_url": "https://github.com/octo/widgets"},
+		"release": {"tag_name": "v1.0.0", "name": "First", "published_at": "2024-02-01T00:00:00Z"}
+	}`
+
+	rec, msg := wt.deliver(t, "release", body, sign(testWebhookSecret, body))
+	if rec.Code != http.StatusOK || msg != "Release stored" {
+		t.Fatalf("expected the release to be stored, got: %d %q", rec.Code, msg)
+	}
+
+	releases, err := wt.releases.GetReleases(context.Background(), repository.RepositoryName("octo/widgets"), 10)
+	if err != nil {
+		t.Fatalf("failed to get releases: %v", err)
+	}
+	if len(releases) != 1 || releases[0].TagName != "v1.0.0" || releases[0].PublishedAt == nil {
+		t.Errorf("unexpected releases: %+v", releases)
+	}
+
+	var repo models.Repository
+	wt.db.First(&repo, wt.repo.ID)
+	if repo.LastWebhookAt != nil {
+		t.Error("expected only push deliveries to hold back the commit poll")
+	}
+}
+
+func TestGitHubWebhook_PollCompletesPushedCommits(t *testing.T) {
+	github := &fetcher.GitHubFetcher{
+		Request: func(url, token string, header http.Header) (*http.Response, error) {
+			body := `[{"sha": "bbb", "parents": [{"sha": "aaa"}],
+				"commit": {"message": "second", "author": {"name": "Jane", "date": "2024-01-01T11:00:00Z"}}}]`
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+		},
+	}
+	wt := setupWebhookTest(t, github)
+	ctx := context.Background()
+
+	first := []models.Commit{{CommitHash: "aaa", Message: "first", CommitDate: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)}}
+	if err := wt.commits.SaveCommits(ctx, wt.repo.ID, first); err != nil {
+		t.Fatalf("failed to save commits: %v", err)
+	}
+
+	body := `{
+		"ref": "refs/heads/main",
+		"after": "bbb",
+		"repository": {"full_name": "octo/widgets", "html_url": "https://github.com/octo/widgets", "default_branch": "main"},
+		"commits": [{"id": "bbb", "message": "second", "timestamp": "2024-01-01T11:00:00Z", "author": {"name": "Jane"}}]
+	}`
+	if rec, msg := wt.deliver(t, "push", body, sign(testWebhookSecret, body)); rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got: %d %q", rec.Code, msg)
+	}
+
+	// The pushed commit is released before the reconciliation poll fills in what the push payload lacked
+	published := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	if err := wt.releases.SaveReleases(ctx, wt.repo.ID, []models.Release{{TagName: "v1.0", TargetSHA: "bbb", PublishedAt: &published}}); err != nil {
+		t.Fatalf("failed to save releases: %v", err)
+	}
+	if _, err := wt.releases.ResolveReleaseCommits(ctx, wt.repo.ID); err != nil {
+		t.Fatalf("failed to resolve release commits: %v", err)
+	}
+
+	mon := monitor.NewMonitor(wt.db, time.Minute, *wt.repos, *wt.commits, *wt.releases,
+		*repository.NewPullRequestRepo(wt.db), *repository.NewIssueRepo(wt.db), wt.providers)
+	if err := mon.FetchNewCommits(wt.repo.ID, ctx); err != nil {
+		t.Fatalf("poll failed: %v", err)
+	}
+	if _, err := wt.releases.ResolveReleaseCommits(ctx, wt.repo.ID); err != nil {
+		t.Fatalf("failed to resolve release commits: %v", err)
+	}
+
+	release, err := wt.releases.GetRelease(ctx, repository.RepositoryName("octo/widgets"), "v1.0")
+	if err != nil {
+		t.Fatalf("failed to get release: %v", err)
+	}
+	shipped, _ := wt.releases.GetReleaseCommits(ctx, release.ID)
+	if len(shipped) != 2 {
+		t.Errorf("expected the release walk to pass the pushed commit, got: %+v", shipped)
+	}
+}
+
+func TestGitHubWebhook_RefEventsApplyBranchesAndTags(t *testing.T) {
+	github := &fetcher.GitHubFetcher{
+		Request: func(url, token string, header http.Header) (*http.Response, error) {
+			if !strings.HasSuffix(url, "/repos/octo/widgets/commits/v2.0") {
+				t.Errorf("unexpected url: %s", url)
+			}
+			body := `{"sha": "bbb"}`
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+		},
+	}
+	wt := setupWebhookTest(t, github)
+	ctx := context.Background()
+
+	if err := wt.repos.SetBranchHead(ctx, wt.repo.ID, "feature", "ccc"); err != nil {
+		t.Fatalf("failed to set branch head: %v", err)
+	}
+	if err := wt.releases.SaveTags(ctx, wt.repo.ID, []models.Tag{{Name: "v1.0", TargetSHA: "aaa"}}); err != nil {
+		t.Fatalf("failed to save tags: %v", err)
+	}
+
+	deliveries := []struct {
+		event, refType, ref, expected string
+	}{
+		{"delete", "branch", "feature", "Branch deleted"},
+		{"create", "tag", "v2.0", "Tag stored"},
+		{"delete", "tag", "v1.0", "Tag deleted"},
+	}
+	for _, d := range deliveries {
+		body := fmt.Sprintf(`{"ref": %q, "ref_type": %q, "repository": {"full_name": "octo/widgets", "html_url": "https://github.com/octo/widgets"}}`,
+			d.ref, d.refType)
+		rec, msg := wt.deliver(t, d.event, body, sign(testWebhookSecret, body))
+		if rec.Code != http.StatusOK || msg != d.expected {
+			t.Errorf("%s %s: expected %q, got: %d %q", d.event, d.refType, d.expected, rec.Code, msg)
+		}
+	}
+
+	branches, _ := wt.repos.GetBranches(ctx, wt.repo.ID)
+	if len(branches) != 0 {
+		t.Errorf("expected the deleted branch to be removed, got: %+v", branches)
+	}
+	targets, _ := wt.releases.GetTagTargets(ctx, wt.repo.ID)
+	if len(targets) != 1 || targets["v2.0"] != "bbb" {
+		t.Errorf("expected only the created tag, got: %v", targets)
+	}
+}